@@ -15,6 +15,9 @@ func main() {
 	// Set defaults
 	cli.ServerConfig.Host = "127.0.0.1"
 	cli.ServerConfig.Port = config.GetInt("api.port", 1323)
+	cli.ServerConfig.Token = config.GetString(config.CLI_TOKEN, "")
+	cli.ServerConfig.TLS = config.GetBool(config.CLI_TLS_ENABLED, false)
+	cli.ServerConfig.CA = config.GetString(config.CLI_TLS_CA, "")
 
 	// Check for environment variables
 	if envHost, ok := os.LookupEnv("SERVERLEDGE_HOST"); ok {
@@ -24,9 +27,13 @@ func main() {
 		if iPort, err := strconv.Atoi(envPort); err == nil {
 			cli.ServerConfig.Port = iPort
 		} else {
-			fmt.Errorf("Invalid port number: %s\n", envPort)
+			fmt.Printf("Invalid port number: %s\n", envPort)
 		}
 	}
 
+	if envToken, ok := os.LookupEnv("SERVERLEDGE_TOKEN"); ok {
+		cli.ServerConfig.Token = envToken
+	}
+
 	cli.Init()
 }
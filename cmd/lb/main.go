@@ -47,7 +47,7 @@ func main() {
 	// TODO: split Area in Region + Type (e.g., cloud/lb/edge)
 	region := config.GetString(config.REGISTRY_AREA, "ROME")
 	registry := &registration.Registry{Area: "lb/" + region}
-	hostport := fmt.Sprintf("http://%s:%d", utils.GetIpAddress().String(), config.GetInt(config.API_PORT, 1323))
+	hostport := utils.NodeUrl(utils.GetIpAddress().String(), config.GetInt(config.API_PORT, 1323))
 	if _, err := registry.RegisterToEtcd(hostport); err != nil {
 		log.Printf("Could not register to Etcd: %v", err)
 	}
@@ -3,46 +3,118 @@ package main
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"time"
 
 	"github.com/grussorusso/serverledge/internal/node"
+	"github.com/grussorusso/serverledge/internal/quota"
 
 	"golang.org/x/net/context"
 
 	"github.com/grussorusso/serverledge/internal/api"
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/grussorusso/serverledge/internal/billing"
 	"github.com/grussorusso/serverledge/internal/cache"
+	"github.com/grussorusso/serverledge/internal/codesign"
 	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/logging"
 	"github.com/grussorusso/serverledge/internal/metrics"
 	"github.com/grussorusso/serverledge/internal/registration"
 	"github.com/grussorusso/serverledge/internal/scheduling"
+	"github.com/grussorusso/serverledge/internal/secrets"
+	"github.com/grussorusso/serverledge/internal/telemetry"
 	"github.com/grussorusso/serverledge/utils"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func startAPIServer(e *echo.Echo) {
 	e.Use(middleware.Recover())
+	e.Use(auth.Middleware())
 
 	// Routes
 	e.POST("/invoke/:fun", api.InvokeFunction)
+	e.POST("/invoke/:ns/:fun", api.InvokeFunction)
 	e.POST("/create", api.CreateFunction)
+	e.POST("/update", api.UpdateFunction)
 	e.POST("/delete", api.DeleteFunction)
 	e.GET("/function", api.GetFunctions)
 	e.GET("/poll/:reqId", api.PollAsyncResult)
+	e.GET("/poll/:ns/:reqId", api.PollAsyncResult)
 	e.GET("/status", api.GetServerStatus)
+	e.GET("/usage", api.GetUsage)
+	e.GET("/quota/:kind/:name", api.GetQuota)
+	e.GET("/quota/:kind/:ns/:name", api.GetQuota)
+	e.GET("/secrets", api.ListSecrets)
+	e.PUT("/secrets/:name", api.SetSecret)
+	e.PUT("/secrets/:ns/:name", api.SetSecret)
+	e.DELETE("/secrets/:name", api.DeleteSecret)
+	e.DELETE("/secrets/:ns/:name", api.DeleteSecret)
+	e.GET("/loglevel", api.GetLogLevel)
+	e.PUT("/loglevel", api.SetLogLevel, auth.RequireAdmin)
+
+	// Admin routes
+	e.POST("/auth/keys", api.IssueKey, auth.RequireAdmin)
+	e.GET("/auth/keys", api.ListKeys, auth.RequireAdmin)
+	e.DELETE("/auth/keys/:id", api.RevokeKey, auth.RequireAdmin)
+	e.PUT("/quota/:kind/:name", api.SetQuota, auth.RequireAdmin)
+	e.PUT("/quota/:kind/:ns/:name", api.SetQuota, auth.RequireAdmin)
+	e.DELETE("/quota/:kind/:name", api.DeleteQuota, auth.RequireAdmin)
+	e.DELETE("/quota/:kind/:ns/:name", api.DeleteQuota, auth.RequireAdmin)
 
 	// Start server
 	portNumber := config.GetInt(config.API_PORT, 1323)
 	e.HideBanner = true
 
-	if err := e.Start(fmt.Sprintf(":%d", portNumber)); err != nil && err != http.ErrServerClosed {
+	var err error
+	if utils.TLSEnabled() {
+		e.TLSServer.Addr = fmt.Sprintf(":%d", portNumber)
+		if e.TLSServer.TLSConfig, err = utils.ServerTLSConfig(); err != nil {
+			log.Fatalf("Invalid TLS configuration: %v", err)
+		}
+		err = e.StartServer(e.TLSServer)
+	} else {
+		err = e.Start(fmt.Sprintf(":%d", portNumber))
+	}
+	if err != nil && err != http.ErrServerClosed {
 		e.Logger.Fatal("shutting down the server")
 	}
 }
 
+func startGRPCServer() *grpc.Server {
+	portNumber := config.GetInt(config.API_GRPC_PORT, 50051)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", portNumber))
+	if err != nil {
+		log.Fatalf("could not start the gRPC server: %v", err)
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(auth.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(auth.StreamServerInterceptor()),
+	}
+	if utils.TLSEnabled() {
+		tlsConfig, err := utils.ServerTLSConfig()
+		if err != nil {
+			log.Fatalf("Invalid TLS configuration: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	s := api.NewGRPCServer(opts...)
+	go func() {
+		log.Printf("gRPC server listening on port %d", portNumber)
+		if err := s.Serve(listener); err != nil {
+			log.Printf("gRPC server stopped: %v", err)
+		}
+	}()
+	return s
+}
+
 func cacheSetup() {
 	//todo fix default values
 
@@ -63,7 +135,7 @@ func cacheSetup() {
 	cache.GetCacheInstance()
 }
 
-func registerTerminationHandler(r *registration.Registry, e *echo.Echo) {
+func registerTerminationHandler(r *registration.Registry, e *echo.Echo, grpcServer *grpc.Server, shutdownTracing func(context.Context) error) {
 	c := make(chan os.Signal)
 	signal.Notify(c, os.Interrupt)
 
@@ -82,12 +154,21 @@ func registerTerminationHandler(r *registration.Registry, e *echo.Echo) {
 			//stop container janitor
 			node.StopJanitor()
 
+			if grpcServer != nil {
+				grpcServer.GracefulStop()
+			}
+
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
 			if err := e.Shutdown(ctx); err != nil {
 				e.Logger.Fatal(err)
 			}
 
+			// flush pending spans
+			if err := shutdownTracing(ctx); err != nil {
+				log.Printf("Could not export pending spans: %v", err)
+			}
+
 			os.Exit(0)
 		}
 	}()
@@ -100,6 +181,10 @@ func main() {
 	}
 	config.ReadConfiguration(configFileName)
 
+	if err := logging.Init(); err != nil {
+		log.Fatalf("Could not initialize logging: %v", err)
+	}
+
 	//setting up cache parameters
 	cacheSetup()
 
@@ -119,7 +204,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	url := fmt.Sprintf("http://%s:%d", utils.GetIpAddress().String(), config.GetInt(config.API_PORT, 1323))
+	url := utils.NodeUrl(utils.GetIpAddress().String(), config.GetInt(config.API_PORT, 1323))
 	myKey, err := registry.RegisterToEtcd(url)
 	if err != nil {
 		log.Fatal(err)
@@ -129,13 +214,44 @@ func main() {
 
 	go metrics.Init()
 
-	e := echo.New()
+	if err := auth.Init(); err != nil {
+		log.Fatalf("Could not initialize authentication: %v", err)
+	}
 
-	// Register a signal handler to cleanup things on termination
-	registerTerminationHandler(registry, e)
+	if err := quota.Init(); err != nil {
+		log.Fatalf("Could not initialize quotas: %v", err)
+	}
+
+	if err := secrets.Init(); err != nil {
+		log.Fatalf("Could not initialize secrets: %v", err)
+	}
+
+	if err := codesign.Init(); err != nil {
+		log.Fatalf("Could not initialize code verification: %v", err)
+	}
+
+	if err := billing.Init(); err != nil {
+		log.Fatalf("Could not initialize billing: %v", err)
+	}
 
+	shutdownTracing, err := telemetry.Init(myKey)
+	if err != nil {
+		log.Fatalf("Could not initialize tracing: %v", err)
+	}
+
+	// the scheduler must be ready before the APIs accept requests
 	schedulingPolicy := createSchedulingPolicy()
-	go scheduling.Run(schedulingPolicy)
+	scheduling.Run(schedulingPolicy)
+
+	e := echo.New()
+
+	var grpcServer *grpc.Server
+	if config.GetBool(config.API_GRPC_ENABLED, false) {
+		grpcServer = startGRPCServer()
+	}
+
+	// Register a signal handler to cleanup things on termination
+	registerTerminationHandler(registry, e, grpcServer, shutdownTracing)
 
 	if !isInCloud {
 		err = registration.InitEdgeMonitoring(registry)
@@ -152,15 +268,5 @@ func main() {
 func createSchedulingPolicy() scheduling.Policy {
 	policyConf := config.GetString(config.SCHEDULING_POLICY, "default")
 	log.Printf("Configured policy: %s\n", policyConf)
-	if policyConf == "cloudonly" {
-		return &scheduling.CloudOnlyPolicy{}
-	} else if policyConf == "edgecloud" {
-		return &scheduling.CloudEdgePolicy{}
-	} else if policyConf == "edgeonly" {
-		return &scheduling.EdgePolicy{}
-	} else if policyConf == "custom1" {
-		return &scheduling.Custom1Policy{}
-	} else {
-		return &scheduling.DefaultLocalPolicy{}
-	}
+	return scheduling.CreatePolicy(policyConf)
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/grussorusso/serverledge/internal/sim"
+)
+
+func main() {
+	conf := sim.DefaultConfig()
+	flag.StringVar(&conf.Policy, "policy", conf.Policy, "scheduling policy (default, cloudonly, edgecloud, edgeonly, custom1)")
+	flag.Int64Var(&conf.MemoryMB, "memory", conf.MemoryMB, "memory of the node (MB)")
+	flag.Float64Var(&conf.CPUs, "cpus", conf.CPUs, "CPUs of the node")
+	flag.Int64Var(&conf.FunctionMemoryMB, "function-memory", conf.FunctionMemoryMB, "default function memory (MB)")
+	flag.Float64Var(&conf.FunctionCPUDemand, "function-cpu", conf.FunctionCPUDemand, "default function CPU demand")
+	flag.Float64Var(&conf.Duration, "duration", conf.Duration, "default execution time (s)")
+	flag.Float64Var(&conf.ColdStart, "cold-start", conf.ColdStart, "cold start time (s)")
+	flag.IntVar(&conf.ContainerExpiration, "expiration", conf.ContainerExpiration, "expiration time of idle containers (s)")
+	flag.IntVar(&conf.QueueCapacity, "queue", conf.QueueCapacity, "scheduler queue capacity")
+	flag.Float64Var(&conf.CloudLatency, "cloud-latency", conf.CloudLatency, "round-trip latency to the cloud (s); negative for no cloud")
+	flag.Float64Var(&conf.EdgeLatency, "edge-latency", conf.EdgeLatency, "round-trip latency to the edge neighbor (s); negative for no neighbor")
+	flag.Int64Var(&conf.EdgeMemoryMB, "edge-memory", conf.EdgeMemoryMB, "memory advertised by the edge neighbor (MB)")
+	flag.Float64Var(&conf.EdgeCPUs, "edge-cpus", conf.EdgeCPUs, "CPUs advertised by the edge neighbor")
+	jsonOutput := flag.Bool("json", false, "print the report as JSON")
+	verbose := flag.Bool("verbose", false, "print the scheduler logs")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <trace>\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Replays a trace (JSON Lines, or Azure Functions CSV) through the Serverledge scheduler.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	arrivals, err := sim.ReadTrace(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Could not read trace: %v", err)
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	results, err := sim.Run(conf, arrivals)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Simulation failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := sim.NewReport(results)
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		report.Print(os.Stdout)
+	}
+}
@@ -0,0 +1,16 @@
+import sys
+import json
+
+# Expensive initialization is performed only once
+greeting = "Hello!"
+
+# Each line on stdin is a JSON-encoded invocation request; the result must be
+# written as a JSON object on a single line
+for line in sys.stdin:
+    request = json.loads(line)
+    params = request.get("Params") or {}
+
+    result = {"Params": params, "Message": greeting}
+
+    response = {"Success": True, "Result": json.dumps(result)}
+    print(json.dumps(response), flush=True)
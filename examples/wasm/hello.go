@@ -0,0 +1,14 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var params map[string]interface{}
+	json.NewDecoder(os.Stdin).Decode(&params)
+	out, _ := json.Marshal(fmt.Sprintf("Hello, %v", params["name"]))
+	os.Stdout.Write(out)
+}
@@ -1,7 +1,34 @@
 let path = require('path');
 var http = require('http');
 
-http.createServer(async (request, response) => {
+// When running outside a container, paths (e.g., /app) are relative to this directory
+var executorRoot = process.env.EXECUTOR_ROOT
+
+// handlers are loaded once and reused across invocations
+var handlers = {}
+
+// variables exported by the last invocation
+var invocationEnv = {}
+
+function setInvocationEnv(env) {
+	for (let k in invocationEnv) {
+		if (!(k in env)) {
+			delete process.env[k]
+		}
+	}
+	Object.assign(process.env, env)
+	invocationEnv = env
+}
+
+function getHandler(handler_dir, handler) {
+	let handler_path = path.join(handler_dir, handler)
+	if (!(handler_path in handlers)) {
+		handlers[handler_path] = require(handler_path)
+	}
+	return handlers[handler_path]
+}
+
+var server = http.createServer(async (request, response) => {
 
 	if (request.method !== 'POST') {
 		response.writeHead(404);
@@ -21,19 +48,42 @@ http.createServer(async (request, response) => {
 
 			var handler = reqbody["Handler"]	
 			var handler_dir = reqbody["HandlerDir"]
+			if (executorRoot) {
+				handler_dir = path.join(executorRoot, handler_dir)
+			}
 			var params = reqbody["Params"]
 
 			var context = {}
-			if (process.env.CONTEXT !== "undefined") {
-				context = process.env.CONTEXT
+			if (process.env.CONTEXT !== undefined) {
+				context = JSON.parse(process.env.CONTEXT)
+			}
+
+			// Raw (non-JSON) input, if any
+			if (reqbody["Payload"] != null) {
+				context["payload"] = Buffer.from(reqbody["Payload"], 'base64')
+				context["contentType"] = reqbody["ContentType"]
 			}
 
-			let h = require(path.join(handler_dir, handler))
+			// Per-invocation environment (e.g., trace context): it is exported
+			// into the process environment, which is shared by concurrent
+			// invocations, and is also available (per invocation) in the context
+			let env = Object.assign({}, reqbody["Env"])
+			setInvocationEnv(env)
+			context["env"] = env
 
-			result = h(params, context)
+			let h = getHandler(handler_dir, handler)
+
+			// async handlers allow the container to serve concurrent invocations
+			result = await h(params, context)
 
 			resp = {}
-			resp["Result"] = JSON.stringify(result);
+			if (Buffer.isBuffer(result)) {
+				// Binary output: the function may choose its content type
+				resp["RawResult"] = result.toString('base64')
+				resp["ContentType"] = context["resultContentType"] || 'application/octet-stream'
+			} else {
+				resp["Result"] = JSON.stringify(result);
+			}
 			resp["Success"] = true
 
 			response.writeHead(200, { 'Content-Type': contentType });
@@ -46,7 +96,17 @@ http.createServer(async (request, response) => {
 		}
 	}
 
-}).listen(8080);
+});
+
+// keep connections with the node alive across invocations
+server.keepAliveTimeout = 5 * 60 * 1000;
+if (process.env.EXECUTOR_LISTEN_FD) {
+	// listening socket inherited from the node
+	server.listen({ fd: parseInt(process.env.EXECUTOR_LISTEN_FD) });
+} else {
+	// in containers, the Executor must be reachable from the node
+	server.listen(process.env.EXECUTOR_PORT || 8080, process.env.EXECUTOR_HOST || '0.0.0.0');
+}
 console.log('Server running');
 
 
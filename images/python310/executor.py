@@ -1,18 +1,42 @@
 # Python 3 server example
-from http.server import BaseHTTPRequestHandler, HTTPServer
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
 import time
 import os
 import sys
 import importlib
 import json
-
-hostName = "0.0.0.0"
-serverPort = 8080
+import base64
+import socket
+import threading
+
+# In containers, the Executor must be reachable from the node
+hostName = os.environ.get("EXECUTOR_HOST", "0.0.0.0")
+serverPort = int(os.environ.get("EXECUTOR_PORT", 8080))
+# Listening socket inherited from the node, if any
+listenFd = os.environ.get("EXECUTOR_LISTEN_FD")
+# When running outside a container, paths (e.g., /app) are relative to this directory
+executorRoot = os.environ.get("EXECUTOR_ROOT", "")
 
 executed_modules = {}
 added_dirs = {}
 
+# Variables exported by the last invocation
+invocation_env = {}
+invocation_env_lock = threading.Lock()
+
+def set_invocation_env(env):
+    with invocation_env_lock:
+        for k in invocation_env:
+            if k not in env:
+                os.environ.pop(k, None)
+        os.environ.update(env)
+        invocation_env.clear()
+        invocation_env.update(env)
+
 class Executor(BaseHTTPRequestHandler):
+    # HTTP/1.1 keeps the connection with the node alive across invocations
+    protocol_version = "HTTP/1.1"
+
     def do_POST(self):
         content_length = int(self.headers['Content-Length']) 
         post_data = self.rfile.read(content_length) 
@@ -20,11 +44,14 @@ class Executor(BaseHTTPRequestHandler):
 
         if not "invoke" in self.path:
             self.send_response(404)
+            self.send_header("Content-Length", "0")
             self.end_headers()
             return
 
         handler = request["Handler"] 
         handler_dir = request["HandlerDir"]
+        if executorRoot:
+            handler_dir = os.path.join(executorRoot, handler_dir.lstrip("/"))
 
         try:
             params = request["Params"]
@@ -36,6 +63,19 @@ class Executor(BaseHTTPRequestHandler):
         else:
             context = {}
 
+        # Raw (non-JSON) input, if any
+        payload = request.get("Payload")
+        if payload is not None:
+            context["payload"] = base64.b64decode(payload)
+            context["content_type"] = request.get("ContentType", "")
+
+        # Per-invocation environment (e.g., trace context): it is exported
+        # into the process environment, which is shared by concurrent
+        # invocations, and is also available (per invocation) in the context
+        env = dict(request.get("Env") or {})
+        set_invocation_env(env)
+        context["env"] = env
+
         if not handler_dir in added_dirs:
             sys.path.insert(1, handler_dir)
             added_dirs[handler_dir] = True
@@ -56,22 +96,36 @@ class Executor(BaseHTTPRequestHandler):
             mod = importlib.import_module(module)
             result = getattr(mod, func_name)(params, context)
 
-            response["Result"] = json.dumps(result)
+            if isinstance(result, (bytes, bytearray)):
+                # Binary output: the function may choose its content type
+                response["RawResult"] = base64.b64encode(result).decode("ascii")
+                response["ContentType"] = context.get("result_content_type", "application/octet-stream")
+            else:
+                response["Result"] = json.dumps(result)
             response["Success"] = True
         except Exception as e:
             print(e, file=sys.stderr)
             response["Success"] = False
 
+        body = bytes(json.dumps(response), "utf-8")
         self.send_response(200)
         self.send_header("Content-type", "application/json")
+        self.send_header("Content-Length", str(len(body)))
         self.end_headers()
-        self.wfile.write(bytes(json.dumps(response), "utf-8"))
+        self.wfile.write(body)
 
 
 
 if __name__ == "__main__":        
-    webServer = HTTPServer((hostName, serverPort), Executor)
-    print("Server started http://%s:%s" % (hostName, serverPort))
+    # requests are served in separate threads, so that a container can serve
+    # concurrent invocations
+    if listenFd:
+        webServer = ThreadingHTTPServer((hostName, serverPort), Executor, bind_and_activate=False)
+        webServer.socket.close()
+        webServer.socket = socket.socket(fileno=int(listenFd))
+    else:
+        webServer = ThreadingHTTPServer((hostName, serverPort), Executor)
+    print("Server started http://%s:%s" % webServer.socket.getsockname()[:2])
 
     try:
         webServer.serve_forever()
@@ -6,112 +6,256 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/grussorusso/serverledge/internal/billing"
 	"github.com/grussorusso/serverledge/internal/client"
+	"github.com/grussorusso/serverledge/internal/codesign"
 	"github.com/grussorusso/serverledge/internal/config"
 	"github.com/grussorusso/serverledge/internal/container"
 	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/logging"
+	"github.com/grussorusso/serverledge/internal/metrics"
 	"github.com/grussorusso/serverledge/internal/node"
+	"github.com/grussorusso/serverledge/internal/quota"
 	"github.com/grussorusso/serverledge/internal/registration"
+	"github.com/grussorusso/serverledge/internal/secrets"
 	"github.com/grussorusso/serverledge/utils"
 
 	"github.com/grussorusso/serverledge/internal/scheduling"
+	"github.com/grussorusso/serverledge/internal/telemetry"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var UnknownFunctionErr = errors.New("unknown function")
+var FunctionExistsErr = errors.New("function already exists")
+var InvalidRuntimeErr = errors.New("invalid runtime")
+var AsyncResultNotFoundErr = errors.New("async result not found")
+
 var requestsPool = sync.Pool{
 	New: func() any {
 		return new(function.Request)
 	},
 }
 
+// nameParam returns the name in the request path, qualified by the
+// namespace if present (i.e., "<ns>/<name>").
+func nameParam(c echo.Context, param string) string {
+	return function.QualifiedName(c.Param("ns"), c.Param(param))
+}
+
 // GetFunctions handles a request to list the function available in the system.
 func GetFunctions(c echo.Context) error {
-	list, err := function.GetAll()
+	list, err := listFunctions(c.QueryParam("namespace"), auth.GetPrincipal(c))
 	if err != nil {
 		return c.String(http.StatusServiceUnavailable, "")
 	}
 	return c.JSON(http.StatusOK, list)
 }
 
+// listFunctions returns the functions in a namespace (or in any namespace, if
+// empty) that are visible to the principal.
+func listFunctions(namespace string, p *auth.Principal) ([]string, error) {
+	all, err := function.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(all))
+	for _, name := range all {
+		ns, _ := function.SplitName(name)
+		if namespace != "" && ns != namespace {
+			continue
+		}
+		if auth.Authorize(p, ns, auth.ACTION_STATUS) == nil {
+			list = append(list, name)
+		}
+	}
+	return list, nil
+}
+
+// getFunction retrieves a function on behalf of a principal, which must be
+// allowed to perform an action in the namespace of the function.
+func getFunction(name string, p *auth.Principal, action auth.Action) (*function.Function, error) {
+	if err := function.ValidateName(name); err != nil {
+		return nil, err
+	}
+	namespace, _ := function.SplitName(name)
+	if err := auth.Authorize(p, namespace, action); err != nil {
+		return nil, err
+	}
+
+	fun, ok := function.GetFunction(name)
+	if !ok {
+		return nil, UnknownFunctionErr
+	}
+	return fun, nil
+}
+
 // InvokeFunction handles a function invocation request.
 func InvokeFunction(c echo.Context) error {
-	funcName := c.Param("fun")
-	fun, ok := function.GetFunction(funcName)
-	if !ok {
-		log.Printf("Dropping request for unknown fun '%s'", funcName)
+	funcName := nameParam(c, "fun")
+	// the request context is not used, as async requests outlive it
+	ctx := telemetry.ExtractHTTP(context.Background(), c.Request().Header)
+	ctx, span := telemetry.StartSpan(ctx, "invoke", attribute.String("serverledge.function", funcName))
+	defer span.End()
+
+	fun, err := getFunction(funcName, auth.GetPrincipal(c), auth.ACTION_INVOKE)
+	if errors.Is(err, UnknownFunctionErr) {
+		slog.Warn("Dropping request for unknown function", "function", funcName)
 		return c.JSON(http.StatusNotFound, "")
+	} else if err != nil {
+		return errorResponse(c, err)
 	}
 
 	var invocationRequest client.InvocationRequest
-	err := json.NewDecoder(c.Request().Body).Decode(&invocationRequest)
-	if err != nil && err != io.EOF {
-		log.Printf("Could not parse request: %v", err)
+	err = parseInvocationRequest(c, &invocationRequest)
+	if err != nil {
+		slog.Warn("Could not parse request", "function", funcName, "err", err)
 		return fmt.Errorf("could not parse request: %v", err)
 	}
 
+	if invocationRequest.Offloaded {
+		if err := auth.AuthorizeOffloaded(auth.GetPrincipal(c)); err != nil {
+			return errorResponse(c, err)
+		}
+	}
+	if err := admit(fun, &invocationRequest, auth.GetPrincipal(c)); errors.Is(err, quota.QuotaExceededErr) {
+		span.SetAttributes(attribute.Bool("serverledge.dropped", true))
+		c.Response().Header().Set("Retry-After", "1")
+		return c.String(http.StatusTooManyRequests, err.Error())
+	} else if err != nil {
+		return c.String(http.StatusServiceUnavailable, "")
+	}
+
 	r := requestsPool.Get().(*function.Request)
+	err = invoke(ctx, fun, &invocationRequest, r)
+
+	if r.Async {
+		// r is still used by the scheduler, so it is not put back in the pool
+		return c.JSON(http.StatusOK, function.AsyncResponse{ReqId: r.ReqId})
+	}
 	defer requestsPool.Put(r)
+
+	if errors.Is(err, node.OutOfResourcesErr) {
+		span.SetAttributes(attribute.Bool("serverledge.dropped", true))
+		return c.String(http.StatusTooManyRequests, "")
+	} else if err != nil {
+		r.Logger().Error("Invocation failed", "err", err)
+		telemetry.RecordError(span, err)
+		return c.String(http.StatusInternalServerError, "")
+	} else if r.ExecReport.ContentType != "" && acceptsRawResult(c) {
+		return writeRawResult(c, &r.ExecReport)
+	} else {
+		return c.JSON(http.StatusOK, function.Response{Success: true, ExecutionReport: r.ExecReport})
+	}
+}
+
+// admit enforces the rate limits of a function. Requests offloaded by other
+// nodes have already been admitted by the node that received them, but the
+// Offloaded flag is only trusted if the sender has been authenticated as a
+// node.
+func admit(fun *function.Function, invocationRequest *client.InvocationRequest, p *auth.Principal) error {
+	if invocationRequest.Offloaded && auth.IsNode(p) {
+		return nil
+	}
+
+	err := quota.AllowRequest(fun)
+	if errors.Is(err, quota.QuotaExceededErr) && metrics.Enabled {
+		metrics.AddDroppedRequest(fun.Name, scheduling.DROP_REASON_RATE_LIMIT)
+	}
+	return err
+}
+
+// invoke initializes r as an invocation of fun and submits it for scheduling.
+// Asynchronous requests are submitted in background.
+func invoke(ctx context.Context, fun *function.Function, invocationRequest *client.InvocationRequest, r *function.Request) error {
+	r.Ctx = ctx
 	r.Fun = fun
 	r.Params = invocationRequest.Params
+	r.Payload = invocationRequest.Payload
+	r.ContentType = invocationRequest.ContentType
 	r.Arrival = time.Now()
 	r.Class = function.ServiceClass(invocationRequest.QoSClass)
 	r.MaxRespT = invocationRequest.QoSMaxRespT
 	r.CanDoOffloading = invocationRequest.CanDoOffloading
 	r.Async = invocationRequest.Async
-	r.ReqId = fmt.Sprintf("%s-%s%d", fun, node.NodeIdentifier[len(node.NodeIdentifier)-5:], r.Arrival.Nanosecond())
+	r.Offloaded = invocationRequest.Offloaded
+	// request IDs are qualified by the namespace, like function names
+	namespace, baseName := function.SplitName(fun.Name)
+	r.ReqId = function.QualifiedName(namespace, fmt.Sprintf("%s-%s%d", baseName, node.NodeIdentifier[len(node.NodeIdentifier)-5:], r.Arrival.Nanosecond()))
+	r.SetLogger()
 	// init fields if possibly not overwritten later
 	r.ExecReport.SchedAction = ""
 	r.ExecReport.OffloadLatency = 0.0
+	r.ExecReport.RawResult = nil
+	r.ExecReport.ContentType = ""
+	r.ExecReport.CPUSeconds = 0.0
+	r.ExecReport.PeakMemoryMB = 0.0
+	r.ExecReport.GBSeconds = 0.0
+	r.Logger().Debug("New invocation request", "async", r.Async, "class", r.Class.String())
 
 	if r.Async {
 		go scheduling.SubmitAsyncRequest(r)
-		return c.JSON(http.StatusOK, function.AsyncResponse{ReqId: r.ReqId})
+		return nil
 	}
 
-	err = scheduling.SubmitRequest(r)
-
-	if errors.Is(err, node.OutOfResourcesErr) {
-		return c.String(http.StatusTooManyRequests, "")
-	} else if err != nil {
-		log.Printf("Invocation failed: %v", err)
-		return c.String(http.StatusInternalServerError, "")
-	} else {
-		return c.JSON(http.StatusOK, function.Response{Success: true, ExecutionReport: r.ExecReport})
-	}
+	return scheduling.SubmitRequest(r)
 }
 
 // PollAsyncResult checks for the result of an asynchronous invocation.
 func PollAsyncResult(c echo.Context) error {
-	reqId := c.Param("reqId")
+	reqId := nameParam(c, "reqId")
 	if len(reqId) < 0 {
 		return c.JSON(http.StatusNotFound, "")
 	}
 
-	etcdClient, err := utils.GetEtcdClient()
-	if err != nil {
-		log.Println("Could not connect to Etcd")
+	payload, err := getAsyncResult(reqId, auth.GetPrincipal(c))
+	if errors.Is(err, AsyncResultNotFoundErr) {
+		return c.JSON(http.StatusNotFound, "")
+	} else if errors.Is(err, auth.ForbiddenErr) || errors.Is(err, function.InvalidNameErr) {
+		return errorResponse(c, err)
+	} else if err != nil {
+		slog.Error("Could not retrieve async result", "reqId", reqId, "err", err)
 		return c.JSON(http.StatusInternalServerError, "")
 	}
 
+	return c.JSONBlob(http.StatusOK, payload)
+}
+
+// getAsyncResult retrieves the JSON-encoded result of an asynchronous
+// invocation. Results are only visible within the namespace of the function.
+func getAsyncResult(reqId string, p *auth.Principal) ([]byte, error) {
+	if err := function.ValidateName(reqId); err != nil {
+		return nil, err
+	}
+	namespace, _ := function.SplitName(reqId)
+	if err := auth.Authorize(p, namespace, auth.ACTION_STATUS); err != nil {
+		return nil, err
+	}
+
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to Etcd: %v", err)
+	}
+
 	ctx := context.Background()
 
 	key := fmt.Sprintf("async/%s", reqId)
-	res, err := etcdClient.Get(ctx, key)
+	value, found, err := store.Get(ctx, key)
 	if err != nil {
-		log.Println(err)
-		return c.JSON(http.StatusInternalServerError, "")
+		return nil, err
 	}
 
-	if len(res.Kvs) == 1 {
-		payload := res.Kvs[0].Value
-		return c.JSONBlob(http.StatusOK, payload)
+	if found {
+		return value, nil
 	} else {
-		return c.JSON(http.StatusNotFound, "")
+		return nil, AsyncResultNotFoundErr
 	}
 }
 
@@ -120,62 +264,293 @@ func CreateFunction(c echo.Context) error {
 	var f function.Function
 	err := json.NewDecoder(c.Request().Body).Decode(&f)
 	if err != nil && err != io.EOF {
-		log.Printf("Could not parse request: %v", err)
+		slog.Warn("Could not parse request", "err", err)
+		return err
+	}
+
+	err = createFunction(&f, auth.GetPrincipal(c))
+	if errors.Is(err, FunctionExistsErr) {
+		return c.JSON(http.StatusConflict, "")
+	} else if errors.Is(err, InvalidRuntimeErr) {
+		return c.JSON(http.StatusNotFound, "Invalid runtime.")
+	} else if isInvalidFunctionErr(err) {
+		return errorResponse(c, err)
+	} else if errors.Is(err, quota.QuotaExceededErr) {
+		return c.String(http.StatusTooManyRequests, err.Error())
+	} else if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, "")
+	}
+
+	response := struct{ Created string }{f.Name}
+	return c.JSON(http.StatusOK, response)
+}
+
+// createFunction validates and registers a new function. Functions are owned
+// by the principal creating them (if any), unless a different owner is set
+// by a principal managing the namespace.
+func createFunction(f *function.Function, creator *auth.Principal) error {
+	if err := function.ValidateName(f.Name); err != nil {
+		return err
+	}
+	f.Name = function.CanonicalName(f.Name)
+	if err := auth.Authorize(creator, f.Namespace(), auth.ACTION_CREATE); err != nil {
 		return err
 	}
 
 	_, ok := function.GetFunction(f.Name) // TODO: we would need a system-wide lock here...
 	if ok {
-		log.Printf("Dropping request for already existing function '%s'", f.Name)
-		return c.JSON(http.StatusConflict, "")
+		slog.Warn("Dropping request for already existing function", "function", f.Name)
+		return FunctionExistsErr
+	}
+	if err := quota.CheckFunctionCount(f.Namespace()); err != nil {
+		slog.Warn("Dropping request exceeding the function quota", "function", f.Name, "err", err)
+		return err
 	}
 
-	log.Printf("New request: creation of %s", f.Name)
+	if err := authorizeOwner(f.Owner, f, creator); err != nil {
+		return err
+	}
+	if f.Owner == "" && creator != nil {
+		f.Owner = creator.Name
+	}
+	f.Version = 0
+	slog.Info("Creating function", "function", f.Name, "runtime", f.Runtime, "owner", f.Owner)
+
+	if err := validateFunction(f); err != nil {
+		return err
+	}
+	if err := authorizeIsolation(f, creator); err != nil {
+		return err
+	}
+
+	err := f.SaveToEtcd()
+	if err != nil {
+		slog.Error("Failed creation", "function", f.Name, "err", err)
+		return err
+	}
+	return nil
+}
 
+// validateFunction checks the runtime, the environment, the isolation
+// settings, the code and the secrets of a function.
+func validateFunction(f *function.Function) error {
 	// Check that the selected runtime exists
 	if f.Runtime != container.CUSTOM_RUNTIME {
 		_, ok := container.RuntimeToInfo[f.Runtime]
 		if !ok {
-			return c.JSON(http.StatusNotFound, "Invalid runtime.")
+			return InvalidRuntimeErr
 		}
 	}
 
-	err = f.SaveToEtcd()
-	if err != nil {
-		log.Printf("Failed creation: %v", err)
+	if err := f.ValidateEnv(); err != nil {
+		return err
+	}
+	if f.Isolation != nil {
+		if err := f.Isolation.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := codesign.Verify(f); err != nil {
+		return err
+	}
+	return secrets.Validate(f)
+}
+
+// authorizeOwner checks that the principal is allowed to assign a function
+// to an owner, i.e., to the user or team charged for its invocations.
+// Principals can own functions themselves, while assigning them to others
+// requires managing the namespace of the function.
+func authorizeOwner(owner string, f *function.Function, p *auth.Principal) error {
+	if owner == "" || (p != nil && owner == p.Name) {
+		return nil
+	}
+	return auth.Authorize(p, f.Namespace(), auth.ACTION_MANAGE)
+}
+
+// authorizeIsolation checks that the principal is allowed to relax the
+// hardening of the containers of a function, which requires managing the
+// namespace of the function.
+func authorizeIsolation(f *function.Function, p *auth.Principal) error {
+	if f.Isolation == nil {
+		return nil
+	}
+	relaxes := f.Isolation.Relaxes()
+	if h := container.DefaultHardening(); h != nil && h.PidsLimit > 0 && f.Isolation.PidsLimit > h.PidsLimit {
+		relaxes = true
+	}
+	if f.Isolation.Network != "" && container.IsNetworkRelaxed(f.Isolation.Network) {
+		relaxes = true
+	}
+	if !relaxes {
+		return nil
+	}
+	return auth.Authorize(p, f.Namespace(), auth.ACTION_MANAGE)
+}
+
+// UpdateFunction handles a request to update an existing function.
+func UpdateFunction(c echo.Context) error {
+	var f function.Function
+	err := json.NewDecoder(c.Request().Body).Decode(&f)
+	if err != nil && err != io.EOF {
+		slog.Warn("Could not parse request", "err", err)
+		return err
+	}
+
+	updated, err := updateFunction(&f, auth.GetPrincipal(c))
+	if errors.Is(err, UnknownFunctionErr) {
+		return c.JSON(http.StatusNotFound, "")
+	} else if errors.Is(err, InvalidRuntimeErr) {
+		return c.JSON(http.StatusNotFound, "Invalid runtime.")
+	} else if isInvalidFunctionErr(err) {
+		return errorResponse(c, err)
+	} else if err != nil {
 		return c.JSON(http.StatusServiceUnavailable, "")
 	}
-	response := struct{ Created string }{f.Name}
+
+	response := struct {
+		Updated string
+		Version int64
+	}{updated.Name, updated.Version}
 	return c.JSON(http.StatusOK, response)
 }
 
+// updateFunction updates the definition of a function. Fields with zero
+// values in the request (nil, for Env, Secrets and Isolation) are left
+// unchanged.
+// Containers created for the previous version are not used anymore: other
+// nodes notice the update when their cached definition of the function
+// expires.
+func updateFunction(req *function.Function, p *auth.Principal) (*function.Function, error) {
+	if _, err := getFunction(req.Name, p, auth.ACTION_CREATE); err != nil {
+		return nil, err
+	}
+	old, ok := function.GetLatestFunction(req.Name) // TODO: we would need a system-wide lock here...
+	if !ok {
+		return nil, UnknownFunctionErr
+	}
+
+	f := *old
+	if req.Runtime != "" {
+		f.Runtime = req.Runtime
+	}
+	if req.Handler != "" {
+		f.Handler = req.Handler
+	}
+	if req.TarFunctionCode != "" {
+		f.TarFunctionCode = req.TarFunctionCode
+		f.CodeDigest = req.CodeDigest
+		f.CodeSignature = req.CodeSignature
+	} else if req.CodeSignature != "" {
+		f.CodeSignature = req.CodeSignature
+	}
+	if req.CustomImage != "" {
+		f.CustomImage = req.CustomImage
+	}
+	if req.MemoryMB > 0 {
+		f.MemoryMB = req.MemoryMB
+	}
+	if req.CPUDemand > 0.0 {
+		f.CPUDemand = req.CPUDemand
+	}
+	if req.MaxConcurrencyPerInstance > 0 {
+		f.MaxConcurrencyPerInstance = req.MaxConcurrencyPerInstance
+	}
+	if req.Owner != "" {
+		if err := authorizeOwner(req.Owner, &f, p); err != nil {
+			return nil, err
+		}
+		f.Owner = req.Owner
+	}
+	if req.Env != nil {
+		f.Env = req.Env
+	}
+	if req.Secrets != nil {
+		f.Secrets = req.Secrets
+	}
+	if req.Isolation != nil {
+		f.Isolation = req.Isolation
+	}
+	f.Version = old.Version + 1
+	slog.Info("Updating function", "function", f.Name, "version", f.Version)
+
+	if err := validateFunction(&f); err != nil {
+		return nil, err
+	}
+	if req.Isolation != nil {
+		if err := authorizeIsolation(&f, p); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.SaveToEtcd(); err != nil {
+		slog.Error("Failed update", "function", f.Name, "err", err)
+		return nil, err
+	}
+
+	// Delete local warm containers of the previous version
+	node.ShutdownWarmContainersFor(old)
+	container.DeleteSnapshot(node.SnapshotKey(old))
+	return &f, nil
+}
+
+// isInvalidFunctionErr returns true for errors due to invalid function
+// definitions or unauthorized requests.
+func isInvalidFunctionErr(err error) bool {
+	return errors.Is(err, auth.ForbiddenErr) || errors.Is(err, function.InvalidNameErr) ||
+		errors.Is(err, function.InvalidEnvErr) || errors.Is(err, function.InvalidIsolationErr) ||
+		errors.Is(err, codesign.InvalidCodeErr) || isInvalidSecretErr(err)
+}
+
 // DeleteFunction handles a function deletion request.
 func DeleteFunction(c echo.Context) error {
 	var f function.Function
 	err := json.NewDecoder(c.Request().Body).Decode(&f)
 	if err != nil && err != io.EOF {
-		log.Printf("Could not parse request: %v", err)
+		slog.Warn("Could not parse request", "err", err)
 		return err
 	}
 
-	_, ok := function.GetFunction(f.Name) // TODO: we would need a system-wide lock here...
-	if !ok {
-		log.Printf("Dropping request for non existing function '%s'", f.Name)
+	err = deleteFunction(f.Name, auth.GetPrincipal(c))
+	if errors.Is(err, UnknownFunctionErr) {
 		return c.JSON(http.StatusNotFound, "")
+	} else if errors.Is(err, auth.ForbiddenErr) || errors.Is(err, function.InvalidNameErr) {
+		return errorResponse(c, err)
+	} else if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, "")
 	}
 
-	log.Printf("New request: deleting %s", f.Name)
+	response := struct{ Deleted string }{f.Name}
+	return c.JSON(http.StatusOK, response)
+}
+
+// deleteFunction removes a function and its local warm containers.
+func deleteFunction(name string, p *auth.Principal) error {
+	f, err := getFunction(name, p, auth.ACTION_DELETE) // TODO: we would need a system-wide lock here...
+	if errors.Is(err, UnknownFunctionErr) {
+		slog.Warn("Dropping request for non existing function", "function", name)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	slog.Info("Deleting function", "function", f.Name)
 	err = f.Delete()
 	if err != nil {
-		log.Printf("Failed deletion: %v", err)
-		return c.JSON(http.StatusServiceUnavailable, "")
+		slog.Error("Failed deletion", "function", f.Name, "err", err)
+		return err
 	}
 
 	// Delete local warm containers
-	node.ShutdownWarmContainersFor(&f)
+	node.ShutdownWarmContainersFor(f)
+	container.DeleteSnapshot(node.SnapshotKey(f))
+	return nil
+}
 
-	response := struct{ Deleted string }{f.Name}
-	return c.JSON(http.StatusOK, response)
+// errorResponse replies to requests that are invalid or not authorized.
+func errorResponse(c echo.Context, err error) error {
+	if errors.Is(err, auth.ForbiddenErr) {
+		return c.String(http.StatusForbidden, err.Error())
+	}
+	return c.String(http.StatusBadRequest, err.Error())
 }
 
 func DecodeServiceClass(serviceClass string) (p function.ServiceClass) {
@@ -192,17 +567,82 @@ func DecodeServiceClass(serviceClass string) (p function.ServiceClass) {
 
 // GetServerStatus simple api to check the current server status
 func GetServerStatus(c echo.Context) error {
+	return c.JSON(http.StatusOK, getServerStatus())
+}
+
+func getServerStatus() registration.StatusInformation {
 	node.Resources.RLock()
 	defer node.Resources.RUnlock()
 	portNumber := config.GetInt("api.port", 1323)
-	url := fmt.Sprintf("http://%s:%d", utils.GetIpAddress().String(), portNumber)
-	response := registration.StatusInformation{
+	url := utils.NodeUrl(utils.GetIpAddress().String(), portNumber)
+	return registration.StatusInformation{
 		Url:            url,
 		AvailableMemMB: node.Resources.AvailableMemMB,
 		AvailableCPUs:  node.Resources.AvailableCPUs,
 		DropCount:      node.Resources.DropCount,
 		Coordinates:    *registration.Reg.Client.GetCoordinate(),
 	}
+}
 
-	return c.JSON(http.StatusOK, response)
+// GetUsage aggregates the billing records matching the query parameters
+// (namespace, owner, function, since, until), grouped by namespace, owner,
+// function or node (groupby).
+func GetUsage(c echo.Context) error {
+	filter := billing.Filter{
+		Namespace: c.QueryParam("namespace"),
+		Owner:     c.QueryParam("owner"),
+		Function:  c.QueryParam("function"),
+	}
+	if filter.Function != "" {
+		filter.Function = function.CanonicalName(filter.Function)
+		filter.Namespace, _ = function.SplitName(filter.Function)
+	}
+	// usage across all namespaces is only visible to admins
+	if err := auth.Authorize(auth.GetPrincipal(c), filter.Namespace, auth.ACTION_MANAGE); err != nil {
+		return errorResponse(c, err)
+	}
+
+	var err error
+	if since := c.QueryParam("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return c.String(http.StatusBadRequest, "invalid 'since' time (RFC3339 expected)")
+		}
+	}
+	if until := c.QueryParam("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return c.String(http.StatusBadRequest, "invalid 'until' time (RFC3339 expected)")
+		}
+	}
+
+	if !billing.Enabled {
+		return c.String(http.StatusNotFound, "billing is not enabled")
+	}
+	usage, err := billing.Query(filter, c.QueryParam("groupby"))
+	if err != nil {
+		slog.Error("Could not compute usage", "err", err)
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, usage)
+}
+
+type logLevel struct {
+	Level string
+}
+
+// GetLogLevel returns the minimum level of logged messages.
+func GetLogLevel(c echo.Context) error {
+	return c.JSON(http.StatusOK, logLevel{Level: logging.GetLevel()})
+}
+
+// SetLogLevel changes the minimum level of logged messages at runtime.
+func SetLogLevel(c echo.Context) error {
+	var l logLevel
+	if err := json.NewDecoder(c.Request().Body).Decode(&l); err != nil {
+		return c.String(http.StatusBadRequest, "could not parse request")
+	}
+
+	if err := logging.SetLevel(l.Level); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, logLevel{Level: logging.GetLevel()})
 }
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/grussorusso/serverledge/internal/client"
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/quota"
+	"github.com/grussorusso/serverledge/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+// principalOf returns the principal identified by the authentication
+// middleware for a request with the given token.
+func principalOf(t *testing.T, token string) *auth.Principal {
+	var p *auth.Principal
+	handler := auth.Middleware()(func(c echo.Context) error {
+		p = auth.GetPrincipal(c)
+		return nil
+	})
+	req := httptest.NewRequest(http.MethodPost, "/invoke/f", nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	if err := handler(echo.New().NewContext(req, httptest.NewRecorder())); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOffloadedRequestsRateLimited(t *testing.T) {
+	utils.SetKVStore(utils.NewMemoryKVStore())
+	viper.Set(config.AUTH_NODE_TOKEN, "node-secret")
+	defer viper.Set(config.AUTH_NODE_TOKEN, nil)
+	if err := auth.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer auth.Init()
+	quota.Enabled = true
+	defer func() { quota.Enabled = false }()
+
+	fun := &function.Function{Name: "offloaded"}
+	if err := quota.Set(quota.KIND_FUNCTION, fun.Name, &quota.Quota{RequestsPerSecond: 1.0, Burst: 1}); err != nil {
+		t.Fatal(err)
+	}
+	req := &client.InvocationRequest{Offloaded: true}
+
+	// with authentication disabled, anyone can claim that a request has
+	// been offloaded, thus the flag is ignored
+	anonymous := principalOf(t, "")
+	if err := admit(fun, req, anonymous); err != nil {
+		t.Fatalf("request within the rate limit rejected: %v", err)
+	}
+	if err := admit(fun, req, anonymous); !errors.Is(err, quota.QuotaExceededErr) {
+		t.Errorf("unauthenticated offloaded request not rate limited: %v", err)
+	}
+
+	// nodes are still identified through the node token
+	node := principalOf(t, "node-secret")
+	if !auth.IsNode(node) {
+		t.Fatalf("node token not recognized: %+v", node)
+	}
+	if err := admit(fun, req, node); err != nil {
+		t.Errorf("request offloaded by a node rate limited: %v", err)
+	}
+}
+
+func TestFunctionOwner(t *testing.T) {
+	utils.SetKVStore(utils.NewMemoryKVStore())
+	auth.Enabled = true
+	defer func() { auth.Enabled = false }()
+
+	alice := &auth.Principal{Name: "alice", Roles: map[string]auth.Role{"team-a": auth.ROLE_DEVELOPER}}
+	admin := &auth.Principal{Name: "carol", Roles: map[string]auth.Role{"team-a": auth.ROLE_ADMIN}}
+	newFunction := func(name, owner string) *function.Function {
+		return &function.Function{Name: name, Runtime: "python310", Handler: "f.handler", MemoryMB: 128, Owner: owner}
+	}
+
+	if err := createFunction(newFunction("team-a/charged", "bob"), alice); !errors.Is(err, auth.ForbiddenErr) {
+		t.Errorf("developer allowed to charge another owner: %v", err)
+	}
+	f := newFunction("team-a/own", "")
+	if err := createFunction(f, alice); err != nil || f.Owner != "alice" {
+		t.Errorf("unexpected owner '%s': %v", f.Owner, err)
+	}
+	if _, err := updateFunction(&function.Function{Name: f.Name, Owner: "bob"}, alice); !errors.Is(err, auth.ForbiddenErr) {
+		t.Errorf("developer allowed to change the owner: %v", err)
+	}
+
+	f = newFunction("team-a/team", "team-a")
+	if err := createFunction(f, admin); err != nil || f.Owner != "team-a" {
+		t.Errorf("namespace admin not allowed to set the owner ('%s'): %v", f.Owner, err)
+	}
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/node"
+	"github.com/grussorusso/serverledge/internal/quota"
+	"github.com/grussorusso/serverledge/internal/rpc"
+	"github.com/grussorusso/serverledge/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer implements the gRPC API, with the same semantics as the REST
+// API handlers.
+type grpcServer struct {
+	rpc.UnimplementedServerledgeServer
+}
+
+// NewGRPCServer creates a gRPC server exposing the Serverledge API.
+func NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	rpc.RegisterServerledgeServer(s, &grpcServer{})
+	return s
+}
+
+func (s *grpcServer) Invoke(ctx context.Context, in *rpc.InvocationRequest) (*rpc.InvocationResponse, error) {
+	ctx, span := telemetry.StartSpan(telemetry.ExtractGRPC(ctx), "invoke", attribute.String("serverledge.function", in.Function))
+	defer span.End()
+
+	fun, err := getFunction(in.Function, auth.PrincipalFromContext(ctx), auth.ACTION_INVOKE)
+	if errors.Is(err, UnknownFunctionErr) {
+		slog.Warn("Dropping request for unknown function", "function", in.Function)
+		return nil, status.Errorf(codes.NotFound, "unknown function '%s'", in.Function)
+	} else if err != nil {
+		return nil, grpcError(err)
+	}
+
+	invocationRequest, err := in.ToClientRequest()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "could not parse request: %v", err)
+	}
+
+	if invocationRequest.Offloaded {
+		if err := auth.AuthorizeOffloaded(auth.PrincipalFromContext(ctx)); err != nil {
+			return nil, grpcError(err)
+		}
+	}
+	if err := admit(fun, &invocationRequest, auth.PrincipalFromContext(ctx)); errors.Is(err, quota.QuotaExceededErr) {
+		span.SetAttributes(attribute.Bool("serverledge.dropped", true))
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	r := requestsPool.Get().(*function.Request)
+	// the RPC context is cancelled when the RPC returns, while async
+	// requests outlive it
+	err = invoke(context.WithoutCancel(ctx), fun, &invocationRequest, r)
+
+	if r.Async {
+		// r is still used by the scheduler, so it is not put back in the pool
+		return &rpc.InvocationResponse{Success: true, ReqId: r.ReqId, Id: in.Id}, nil
+	}
+	defer requestsPool.Put(r)
+
+	if errors.Is(err, node.OutOfResourcesErr) {
+		span.SetAttributes(attribute.Bool("serverledge.dropped", true))
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	} else if err != nil {
+		r.Logger().Error("Invocation failed", "err", err)
+		telemetry.RecordError(span, err)
+		return nil, status.Errorf(codes.Internal, "invocation failed: %v", err)
+	}
+
+	return &rpc.InvocationResponse{
+		Success: true,
+		Report:  rpc.FromExecutionReport(&r.ExecReport),
+		ReqId:   r.ReqId,
+		Id:      in.Id,
+	}, nil
+}
+
+func (s *grpcServer) InvokeStream(stream rpc.Serverledge_InvokeStreamServer) error {
+	var wg sync.WaitGroup
+	var sendMutex sync.Mutex
+	defer wg.Wait()
+
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// Requests are served concurrently; responses carry the
+		// client-chosen Id to be matched with requests
+		wg.Add(1)
+		go func(in *rpc.InvocationRequest) {
+			defer wg.Done()
+
+			resp, err := s.Invoke(stream.Context(), in)
+			if err != nil {
+				resp = &rpc.InvocationResponse{Success: false, Id: in.Id, Error: err.Error()}
+			}
+
+			sendMutex.Lock()
+			defer sendMutex.Unlock()
+			if err := stream.Send(resp); err != nil {
+				slog.Warn("Could not send response", "err", err)
+			}
+		}(in)
+	}
+}
+
+func (s *grpcServer) PollAsyncResult(ctx context.Context, in *rpc.PollRequest) (*rpc.InvocationResponse, error) {
+	payload, err := getAsyncResult(in.ReqId, auth.PrincipalFromContext(ctx))
+	if errors.Is(err, AsyncResultNotFoundErr) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if errors.Is(err, auth.ForbiddenErr) || errors.Is(err, function.InvalidNameErr) {
+		return nil, grpcError(err)
+	} else if err != nil {
+		slog.Error("Could not retrieve async result", "reqId", in.ReqId, "err", err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	var response function.Response
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &rpc.InvocationResponse{
+		Success: response.Success,
+		Report:  rpc.FromExecutionReport(&response.ExecutionReport),
+		ReqId:   in.ReqId,
+	}, nil
+}
+
+func (s *grpcServer) CreateFunction(ctx context.Context, in *rpc.Function) (*rpc.CreateResponse, error) {
+	f := in.ToFunction()
+	err := createFunction(f, auth.PrincipalFromContext(ctx))
+	if errors.Is(err, FunctionExistsErr) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	} else if errors.Is(err, InvalidRuntimeErr) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if isInvalidFunctionErr(err) {
+		return nil, grpcError(err)
+	} else if errors.Is(err, quota.QuotaExceededErr) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	return &rpc.CreateResponse{Created: f.Name}, nil
+}
+
+func (s *grpcServer) UpdateFunction(ctx context.Context, in *rpc.Function) (*rpc.UpdateResponse, error) {
+	f, err := updateFunction(in.ToFunction(), auth.PrincipalFromContext(ctx))
+	if errors.Is(err, UnknownFunctionErr) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if errors.Is(err, InvalidRuntimeErr) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if isInvalidFunctionErr(err) {
+		return nil, grpcError(err)
+	} else if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	return &rpc.UpdateResponse{Updated: f.Name, Version: f.Version}, nil
+}
+
+func (s *grpcServer) DeleteFunction(ctx context.Context, in *rpc.DeleteRequest) (*rpc.DeleteResponse, error) {
+	err := deleteFunction(in.Name, auth.PrincipalFromContext(ctx))
+	if errors.Is(err, UnknownFunctionErr) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if errors.Is(err, auth.ForbiddenErr) || errors.Is(err, function.InvalidNameErr) {
+		return nil, grpcError(err)
+	} else if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	return &rpc.DeleteResponse{Deleted: in.Name}, nil
+}
+
+func (s *grpcServer) ListFunctions(ctx context.Context, in *rpc.ListRequest) (*rpc.FunctionList, error) {
+	list, err := listFunctions(in.Namespace, auth.PrincipalFromContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &rpc.FunctionList{Functions: list}, nil
+}
+
+func (s *grpcServer) GetStatus(ctx context.Context, in *rpc.StatusRequest) (*rpc.StatusInformation, error) {
+	info := getServerStatus()
+
+	warmContainers := make(map[string]int32, len(info.AvailableWarmContainers))
+	for f, n := range info.AvailableWarmContainers {
+		warmContainers[f] = int32(n)
+	}
+
+	return &rpc.StatusInformation{
+		Url:                     info.Url,
+		AvailableWarmContainers: warmContainers,
+		AvailableMemMb:          info.AvailableMemMB,
+		AvailableCpus:           info.AvailableCPUs,
+		DropCount:               info.DropCount,
+		Coordinates: &rpc.Coordinate{
+			Vec:        info.Coordinates.Vec,
+			Error:      info.Coordinates.Error,
+			Adjustment: info.Coordinates.Adjustment,
+			Height:     info.Coordinates.Height,
+		},
+	}, nil
+}
+
+// grpcError converts the errors of requests that are invalid or not
+// authorized into gRPC status errors.
+func grpcError(err error) error {
+	if errors.Is(err, auth.ForbiddenErr) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/container"
+	"github.com/grussorusso/serverledge/internal/node"
+	"github.com/grussorusso/serverledge/internal/rpc"
+	"github.com/grussorusso/serverledge/internal/scheduling"
+	"github.com/grussorusso/serverledge/utils"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startGRPCTestServer starts the scheduler, with a fake container factory
+// and the given policy, and a gRPC server on an in-memory connection,
+// returning a client. Interceptors are run before authentication.
+func startGRPCTestServer(t *testing.T, policy string, interceptors ...grpc.UnaryServerInterceptor) rpc.ServerledgeClient {
+	utils.SetKVStore(utils.NewMemoryKVStore())
+	container.SetFactory(container.NewFakeFactory())
+	node.NodeIdentifier = "test-node"
+	node.Resources.Lock()
+	node.Resources.AvailableMemMB = 1024
+	node.Resources.AvailableCPUs = 4.0
+	node.Resources.ContainerPools = make(map[string]*node.ContainerPool)
+	node.Resources.Unlock()
+	scheduling.Start(scheduling.CreatePolicy(policy))
+
+	listener := bufconn.Listen(1 << 20)
+	s := NewGRPCServer(grpc.ChainUnaryInterceptor(append(interceptors, auth.UnaryServerInterceptor())...))
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return rpc.NewServerledgeClient(conn)
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	cli := startGRPCTestServer(t, "default")
+	ctx := context.Background()
+
+	fun := &rpc.Function{Name: "grpc-func", Runtime: "python310", Handler: "f.handler", MemoryMb: 128, CpuDemand: 0.5}
+	if resp, err := cli.CreateFunction(ctx, fun); err != nil || resp.Created != fun.Name {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+	if _, err := cli.CreateFunction(ctx, fun); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("duplicate function created: %v", err)
+	}
+
+	resp, err := cli.Invoke(ctx, &rpc.InvocationRequest{Function: fun.Name, Params: []byte(`{"name": "gRPC"}`), Id: "1"})
+	if err != nil {
+		t.Fatalf("Invoke failed: %v", err)
+	}
+	if !resp.Success || resp.Id != "1" || resp.Report.Result != `"OK"` {
+		t.Errorf("unexpected response: %v", resp)
+	}
+
+	if resp, err := cli.DeleteFunction(ctx, &rpc.DeleteRequest{Name: fun.Name}); err != nil || resp.Deleted != fun.Name {
+		t.Fatalf("DeleteFunction failed: %v", err)
+	}
+	if _, err := cli.Invoke(ctx, &rpc.InvocationRequest{Function: fun.Name}); status.Code(err) != codes.NotFound {
+		t.Errorf("deleted function invoked: %v", err)
+	}
+	if _, err := cli.DeleteFunction(ctx, &rpc.DeleteRequest{Name: fun.Name}); status.Code(err) != codes.NotFound {
+		t.Errorf("deleted function deleted again: %v", err)
+	}
+}
+
+func TestGRPCAsyncOffloading(t *testing.T) {
+	// offloaded requests are only served by the cloud after the local RPC
+	// has returned, and counted if they have not been cancelled meanwhile
+	returned := make(chan struct{})
+	var offloaded atomic.Int32
+	cloudInterceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if in, ok := req.(*rpc.InvocationRequest); ok && in.Offloaded {
+			<-returned
+			time.Sleep(100 * time.Millisecond)
+			if ctx.Err() == nil {
+				offloaded.Add(1)
+			}
+		}
+		return handler(ctx, req)
+	}
+	localInterceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if _, ok := req.(*rpc.InvocationRequest); ok {
+			defer close(returned)
+		}
+		return resp, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloud := NewGRPCServer(grpc.ChainUnaryInterceptor(cloudInterceptor, auth.UnaryServerInterceptor()))
+	go cloud.Serve(listener)
+	t.Cleanup(cloud.Stop)
+
+	viper.Set(config.SCHEDULER_OFFLOADING_TRANSPORT, scheduling.OFFLOADING_TRANSPORT_GRPC)
+	viper.Set(config.API_GRPC_PORT, listener.Addr().(*net.TCPAddr).Port)
+	viper.Set(config.CLOUD_URL, fmt.Sprintf("http://%s", listener.Addr()))
+	defer viper.Set(config.SCHEDULER_OFFLOADING_TRANSPORT, nil)
+	defer viper.Set(config.API_GRPC_PORT, nil)
+	defer viper.Set(config.CLOUD_URL, nil)
+
+	cli := startGRPCTestServer(t, "cloudonly", localInterceptor)
+	ctx := context.Background()
+	fun := &rpc.Function{Name: "grpc-async", Runtime: "python310", Handler: "f.handler", MemoryMb: 128}
+	if _, err := cli.CreateFunction(ctx, fun); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	resp, err := cli.Invoke(ctx, &rpc.InvocationRequest{Function: fun.Name, Async: true, CanDoOffloading: true})
+	if err != nil || !resp.Success {
+		t.Fatalf("Invoke failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for offloaded.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if offloaded.Load() != 1 {
+		t.Fatalf("async request not offloaded")
+	}
+
+	// a failed offloading would publish a failure for the request
+	time.Sleep(50 * time.Millisecond)
+	store, _ := utils.GetKVStore()
+	if _, found, _ := store.Get(ctx, "async/"+resp.ReqId); found {
+		t.Errorf("failure published for offloaded request %s", resp.ReqId)
+	}
+}
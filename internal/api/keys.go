@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// KeyRequest is a request to issue an API key.
+type KeyRequest struct {
+	Name  string
+	Admin bool
+	Roles map[string]auth.Role // namespace -> role
+	TTL   int64                // validity (in seconds); no expiration if zero
+}
+
+// KeyResponse carries a newly issued API key.
+type KeyResponse struct {
+	Key string // the only copy of the secret key
+	auth.APIKey
+}
+
+// IssueKey handles a request to issue a new API key.
+func IssueKey(c echo.Context) error {
+	var req KeyRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil || req.Name == "" {
+		return c.String(http.StatusBadRequest, "a name is required for the key")
+	}
+	if err := auth.ValidateRoles(req.Roles); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	secret, key, err := auth.IssueKey(req.Name, req.Admin, req.Roles, time.Duration(req.TTL)*time.Second)
+	if err != nil {
+		slog.Error("Could not issue API key", "name", req.Name, "err", err)
+		return c.String(http.StatusServiceUnavailable, "")
+	}
+	slog.Info("Issued API key", "id", key.ID, "name", key.Name, "admin", key.Admin, "roles", key.Roles)
+
+	key.Hash = ""
+	return c.JSON(http.StatusOK, KeyResponse{Key: secret, APIKey: *key})
+}
+
+// ListKeys handles a request to list the issued API keys.
+func ListKeys(c echo.Context) error {
+	keys, err := auth.ListKeys()
+	if err != nil {
+		return c.String(http.StatusServiceUnavailable, "")
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// RevokeKey handles a request to revoke an API key.
+func RevokeKey(c echo.Context) error {
+	id := c.Param("id")
+	found, err := auth.RevokeKey(id)
+	if err != nil {
+		return c.String(http.StatusServiceUnavailable, "")
+	} else if !found {
+		return c.JSON(http.StatusNotFound, "")
+	}
+	slog.Info("Revoked API key", "id", id)
+
+	response := struct{ Revoked string }{id}
+	return c.JSON(http.StatusOK, response)
+}
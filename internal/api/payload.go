@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grussorusso/serverledge/internal/client"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/labstack/echo/v4"
+)
+
+// ExecutionReportHeader carries the JSON-encoded execution report when the
+// function result is returned as a raw body.
+const ExecutionReportHeader = "Serverledge-Execution-Report"
+
+// parseInvocationRequest decodes the body of an invocation request.
+// JSON bodies are decoded as a client.InvocationRequest. Multipart bodies may
+// contain a JSON-encoded "request" field and a "payload" file. Any other
+// content type is passed to the function as a raw payload, and invocation
+// options are read from the query string.
+func parseInvocationRequest(c echo.Context, invocationRequest *client.InvocationRequest) error {
+	req := c.Request()
+	contentType := req.Header.Get(echo.HeaderContentType)
+	if contentType == "" {
+		return decodeJSONInvocationRequest(req.Body, invocationRequest)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type '%s': %v", contentType, err)
+	}
+
+	switch mediaType {
+	case echo.MIMEApplicationJSON:
+		return decodeJSONInvocationRequest(req.Body, invocationRequest)
+	case echo.MIMEMultipartForm:
+		return parseMultipartInvocationRequest(c, invocationRequest)
+	default:
+		payload, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		invocationRequest.Payload = payload
+		invocationRequest.ContentType = contentType
+		return parseInvocationOptions(c, invocationRequest)
+	}
+}
+
+func decodeJSONInvocationRequest(body io.Reader, invocationRequest *client.InvocationRequest) error {
+	err := json.NewDecoder(body).Decode(invocationRequest)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func parseMultipartInvocationRequest(c echo.Context, invocationRequest *client.InvocationRequest) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return err
+	}
+
+	if values := form.Value["request"]; len(values) > 0 {
+		if err := json.Unmarshal([]byte(values[0]), invocationRequest); err != nil {
+			return err
+		}
+	} else if err := parseInvocationOptions(c, invocationRequest); err != nil {
+		return err
+	}
+
+	files := form.File["payload"]
+	if len(files) == 0 {
+		return nil
+	}
+
+	file, err := files[0].Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	payload, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	invocationRequest.Payload = payload
+	invocationRequest.ContentType = files[0].Header.Get(echo.HeaderContentType)
+	if invocationRequest.ContentType == "" {
+		invocationRequest.ContentType = echo.MIMEOctetStream
+	}
+	return nil
+}
+
+// parseInvocationOptions reads invocation options from the query string
+// (e.g., /invoke/myfunc?async=true&class=performance).
+func parseInvocationOptions(c echo.Context, invocationRequest *client.InvocationRequest) error {
+	var err error
+
+	invocationRequest.QoSClass = int64(DecodeServiceClass(c.QueryParam("class")))
+	invocationRequest.QoSMaxRespT = -1.0
+	if v := c.QueryParam("resptime"); v != "" {
+		if invocationRequest.QoSMaxRespT, err = strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("invalid resptime: %v", err)
+		}
+	}
+	invocationRequest.CanDoOffloading = true
+	if v := c.QueryParam("offload"); v != "" {
+		if invocationRequest.CanDoOffloading, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid offload: %v", err)
+		}
+	}
+	if v := c.QueryParam("async"); v != "" {
+		if invocationRequest.Async, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid async: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// acceptsRawResult returns true unless the client explicitly asked for a
+// JSON-encoded response.
+func acceptsRawResult(c echo.Context) bool {
+	accept := c.Request().Header.Get(echo.HeaderAccept)
+	return !strings.HasPrefix(accept, echo.MIMEApplicationJSON)
+}
+
+// writeRawResult replies with the binary result of a function, using the
+// content type chosen by the function. The rest of the execution report is
+// sent in the ExecutionReportHeader header.
+func writeRawResult(c echo.Context, report *function.ExecutionReport) error {
+	reportCopy := *report
+	reportCopy.RawResult = nil
+	encodedReport, err := json.Marshal(reportCopy)
+	if err == nil {
+		c.Response().Header().Set(ExecutionReportHeader, string(encodedReport))
+	}
+
+	return c.Blob(http.StatusOK, report.ContentType, report.RawResult)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/quota"
+	"github.com/labstack/echo/v4"
+)
+
+// quotaTarget returns the kind and the name of the namespace or function
+// whose quota is addressed by a request, and the namespace it belongs to.
+func quotaTarget(c echo.Context) (kind string, name string, namespace string, ok bool) {
+	switch kind = c.Param("kind"); kind {
+	case quota.KIND_NAMESPACE:
+		name = c.Param("name")
+		return kind, name, name, name != ""
+	case quota.KIND_FUNCTION:
+		name = nameParam(c, "name")
+		if function.ValidateName(name) != nil {
+			return "", "", "", false
+		}
+		name = function.CanonicalName(name)
+		namespace, _ = function.SplitName(name)
+		return kind, name, namespace, true
+	default:
+		return "", "", "", false
+	}
+}
+
+// GetQuota handles a request to retrieve the quota of a namespace or a
+// function.
+func GetQuota(c echo.Context) error {
+	kind, name, namespace, ok := quotaTarget(c)
+	if !ok {
+		return c.String(http.StatusBadRequest, "invalid namespace or function")
+	}
+	if err := auth.Authorize(auth.GetPrincipal(c), namespace, auth.ACTION_STATUS); err != nil {
+		return errorResponse(c, err)
+	}
+
+	q, err := quota.Get(kind, name)
+	if err != nil {
+		return c.String(http.StatusServiceUnavailable, "")
+	} else if q == nil {
+		return c.JSON(http.StatusNotFound, "")
+	}
+	return c.JSON(http.StatusOK, q)
+}
+
+// SetQuota handles a request to set the quota of a namespace or a function.
+func SetQuota(c echo.Context) error {
+	kind, name, _, ok := quotaTarget(c)
+	if !ok {
+		return c.String(http.StatusBadRequest, "invalid namespace or function")
+	}
+
+	var q quota.Quota
+	if err := json.NewDecoder(c.Request().Body).Decode(&q); err != nil {
+		return c.String(http.StatusBadRequest, "could not parse request")
+	}
+	if err := q.Validate(); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := quota.Set(kind, name, &q); err != nil {
+		slog.Error("Could not set quota", kind, name, "err", err)
+		return c.String(http.StatusServiceUnavailable, "")
+	}
+	slog.Info("Quota set", kind, name, "quota", q)
+	return c.JSON(http.StatusOK, q)
+}
+
+// DeleteQuota handles a request to remove the quota of a namespace or a
+// function.
+func DeleteQuota(c echo.Context) error {
+	kind, name, _, ok := quotaTarget(c)
+	if !ok {
+		return c.String(http.StatusBadRequest, "invalid namespace or function")
+	}
+
+	found, err := quota.Delete(kind, name)
+	if err != nil {
+		return c.String(http.StatusServiceUnavailable, "")
+	} else if !found {
+		return c.JSON(http.StatusNotFound, "")
+	}
+	slog.Info("Quota removed", kind, name)
+
+	response := struct{ Deleted string }{name}
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/secrets"
+	"github.com/labstack/echo/v4"
+)
+
+// maxSecretSize is the max. size of the value of a secret
+const maxSecretSize = 64 * 1024
+
+// isInvalidSecretErr returns true if a function references secrets that are
+// invalid or do not exist.
+func isInvalidSecretErr(err error) bool {
+	return errors.Is(err, function.InvalidSecretErr) || errors.Is(err, secrets.UnknownSecretErr)
+}
+
+// secretParam returns the namespace and the name of the secret addressed by
+// a request, checking that the principal may perform an action on it.
+func secretParam(c echo.Context, action auth.Action) (namespace string, name string, err error) {
+	namespace, name = function.SplitName(nameParam(c, "name"))
+	if err := function.ValidateSecretName(name); err != nil {
+		return "", "", err
+	}
+	if err := auth.Authorize(auth.GetPrincipal(c), namespace, action); err != nil {
+		return "", "", err
+	}
+	return namespace, name, nil
+}
+
+// ListSecrets handles a request to list the secrets of a namespace. Values
+// are never returned.
+func ListSecrets(c echo.Context) error {
+	namespace := c.QueryParam("namespace")
+	if namespace == "" {
+		namespace = function.DEFAULT_NAMESPACE
+	}
+	if err := auth.Authorize(auth.GetPrincipal(c), namespace, auth.ACTION_CREATE); err != nil {
+		return errorResponse(c, err)
+	}
+
+	list, err := secrets.List(namespace)
+	if err != nil {
+		return c.String(http.StatusServiceUnavailable, "")
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+// SetSecret handles a request to create or update a secret.
+func SetSecret(c echo.Context) error {
+	namespace, name, err := secretParam(c, auth.ACTION_CREATE)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	var request struct{ Value string }
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, 2*maxSecretSize)
+	if err := json.NewDecoder(body).Decode(&request); err != nil {
+		return c.String(http.StatusBadRequest, "could not parse request")
+	}
+	if len(request.Value) > maxSecretSize {
+		return c.String(http.StatusBadRequest, "secret too large")
+	}
+
+	err = secrets.Set(namespace, name, []byte(request.Value))
+	if errors.Is(err, secrets.NotConfiguredErr) {
+		return c.String(http.StatusServiceUnavailable, err.Error())
+	} else if err != nil {
+		slog.Error("Could not set secret", "namespace", namespace, "secret", name, "err", err)
+		return c.String(http.StatusServiceUnavailable, "")
+	}
+	slog.Info("Secret set", "namespace", namespace, "secret", name)
+
+	response := struct{ Updated string }{function.QualifiedName(namespace, name)}
+	return c.JSON(http.StatusOK, response)
+}
+
+// DeleteSecret handles a request to remove a secret. Functions referencing
+// the secret fail to start new containers until it is set again.
+func DeleteSecret(c echo.Context) error {
+	namespace, name, err := secretParam(c, auth.ACTION_DELETE)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	found, err := secrets.Delete(namespace, name)
+	if err != nil {
+		return c.String(http.StatusServiceUnavailable, "")
+	} else if !found {
+		return c.JSON(http.StatusNotFound, "")
+	}
+	slog.Info("Secret removed", "namespace", namespace, "secret", name)
+
+	response := struct{ Deleted string }{function.QualifiedName(namespace, name)}
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grussorusso/serverledge/utils"
+	"github.com/lithammer/shortuuid"
+)
+
+// An APIKey is a long-lived credential issued to a user or a team. Only a
+// hash of the secret part of the key is stored.
+type APIKey struct {
+	ID      string
+	Name    string // user or team the key was issued to
+	Admin   bool
+	Roles   map[string]Role `json:",omitempty"` // namespace -> role
+	Hash    string          `json:",omitempty"`
+	Created time.Time
+	Expires time.Time `json:",omitempty"` // no expiration if zero
+}
+
+// API keys have the form "sl_<id>_<secret>", where the ID is a short UUID
+// and the secret is made of 32 random bytes (base64-encoded). Short UUIDs
+// are not padded, so IDs may have less than 22 characters.
+const apiKeyPrefix = "sl_"
+
+var validAPIKey = regexp.MustCompile(`^sl_[2-9A-HJ-NP-Za-km-z]{1,22}_[A-Za-z0-9_-]{43}$`)
+
+// Keys are cached to avoid hitting Etcd on every request: revoked keys may
+// still be accepted by other nodes for up to keyCacheTTL. Unknown keys are
+// not cached, and at most maxCachedKeys keys are.
+const keyCacheTTL = 30 * time.Second
+const maxCachedKeys = 1024
+
+type cachedKey struct {
+	key     *APIKey
+	fetched time.Time
+}
+
+var keyCache = make(map[string]cachedKey)
+var keyCacheMutex sync.Mutex
+
+func getEtcdKey(id string) string {
+	return fmt.Sprintf("/auth/keys/%s", id)
+}
+
+func hashSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// IssueKey creates a new API key. The returned key is the only copy of the
+// secret: it cannot be retrieved later.
+func IssueKey(name string, admin bool, roles map[string]Role, ttl time.Duration) (string, *APIKey, error) {
+	if name == "" {
+		return "", nil, fmt.Errorf("missing key name")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+
+	key := &APIKey{
+		ID:      shortuuid.New(),
+		Name:    name,
+		Admin:   admin,
+		Roles:   roles,
+		Hash:    hashSecret(secret),
+		Created: time.Now(),
+	}
+	if ttl > 0 {
+		key.Expires = key.Created.Add(ttl)
+	}
+
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return "", nil, err
+	}
+	payload, err := json.Marshal(key)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := store.Put(context.Background(), getEtcdKey(key.ID), payload, ttl); err != nil {
+		return "", nil, err
+	}
+
+	return apiKeyPrefix + key.ID + "_" + secret, key, nil
+}
+
+// RevokeKey deletes an API key, returning false if it did not exist.
+func RevokeKey(id string) (bool, error) {
+	keyCacheMutex.Lock()
+	delete(keyCache, id)
+	keyCacheMutex.Unlock()
+
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return false, err
+	}
+	return store.Delete(context.Background(), getEtcdKey(id))
+}
+
+// ListKeys returns the issued API keys, without their hashes.
+func ListKeys() ([]*APIKey, error) {
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return nil, err
+	}
+	values, err := store.GetWithPrefix(context.Background(), "/auth/keys/")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*APIKey, 0, len(values))
+	for _, value := range values {
+		var key APIKey
+		if err := json.Unmarshal(value, &key); err != nil {
+			return nil, err
+		}
+		key.Hash = ""
+		keys = append(keys, &key)
+	}
+	return keys, nil
+}
+
+func getKey(id string) (*APIKey, error) {
+	keyCacheMutex.Lock()
+	cached, ok := keyCache[id]
+	keyCacheMutex.Unlock()
+	if ok && time.Since(cached.fetched) < keyCacheTTL {
+		return cached.key, nil
+	}
+
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return nil, err
+	}
+	value, found, err := store.Get(context.Background(), getEtcdKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, nil
+	}
+	key := &APIKey{}
+	if err := json.Unmarshal(value, key); err != nil {
+		return nil, err
+	}
+
+	cacheKey(id, key)
+	return key, nil
+}
+
+func cacheKey(id string, key *APIKey) {
+	keyCacheMutex.Lock()
+	defer keyCacheMutex.Unlock()
+	if len(keyCache) >= maxCachedKeys {
+		for cachedID, cached := range keyCache {
+			if time.Since(cached.fetched) >= keyCacheTTL {
+				delete(keyCache, cachedID)
+			}
+		}
+		if len(keyCache) >= maxCachedKeys {
+			return
+		}
+	}
+	keyCache[id] = cachedKey{key: key, fetched: time.Now()}
+}
+
+func authenticateAPIKey(token string) (*Principal, error) {
+	// malformed keys are rejected before looking them up
+	if !validAPIKey.MatchString(token) {
+		return nil, UnauthenticatedErr
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, apiKeyPrefix), "_", 2)
+
+	key, err := getKey(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(hashSecret(parts[1])), []byte(key.Hash)) != 1 {
+		return nil, UnauthenticatedErr
+	}
+	if !key.Expires.IsZero() && time.Now().After(key.Expires) {
+		return nil, fmt.Errorf("%w: expired key", UnauthenticatedErr)
+	}
+
+	return &Principal{Name: key.Name, Admin: key.Admin, Roles: key.Roles}, nil
+}
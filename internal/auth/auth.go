@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/utils"
+)
+
+var UnauthenticatedErr = errors.New("missing or invalid credentials")
+
+// A Principal is an authenticated user, team or node.
+type Principal struct {
+	Name  string
+	Admin bool            // admins can do anything, e.g., issue and revoke API keys
+	Node  bool            // another Serverledge node (e.g., offloading a request)
+	Roles map[string]Role // namespace -> role
+}
+
+const nodePrincipalName = "serverledge-node"
+
+var Enabled bool
+
+// nodeTLS is true if nodes are authenticated through TLS client certificates
+var nodeTLS bool
+
+var jwtSecret []byte
+var adminToken string
+var nodeToken string
+
+// Init configures authentication according to the configuration.
+func Init() error {
+	Enabled = config.GetBool(config.AUTH_ENABLED, false)
+	jwtSecret = []byte(config.GetString(config.AUTH_JWT_SECRET, ""))
+	adminToken = config.GetString(config.AUTH_ADMIN_TOKEN, "")
+	nodeToken = config.GetString(config.AUTH_NODE_TOKEN, "")
+	nodeTLS = utils.NodeClientAuthEnabled()
+	if !Enabled {
+		return nil
+	}
+
+	if nodeToken == "" && !nodeTLS {
+		slog.Warn("No node token configured: requests offloaded by other nodes will be rejected")
+	}
+	slog.Info("Authentication enabled", "jwt", len(jwtSecret) > 0)
+	return nil
+}
+
+// NodeToken returns the token used by this node to authenticate with other
+// nodes (empty if not configured).
+func NodeToken() string {
+	return nodeToken
+}
+
+// Authenticate verifies a token, which can be an API key or a JWT bearer
+// token.
+func Authenticate(token string) (*Principal, error) {
+	if token == "" {
+		return nil, UnauthenticatedErr
+	}
+
+	if p := authenticateNodeToken(token); p != nil {
+		return p, nil
+	}
+	if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return &Principal{Name: "admin", Admin: true}, nil
+	}
+
+	if strings.HasPrefix(token, apiKeyPrefix) {
+		return authenticateAPIKey(token)
+	}
+	return authenticateJWT(token)
+}
+
+// Claims of the JWT bearer tokens accepted by Serverledge.
+type Claims struct {
+	Admin bool            `json:"admin,omitempty"`
+	Roles map[string]Role `json:"roles,omitempty"` // namespace -> role
+	jwt.RegisteredClaims
+}
+
+func authenticateJWT(token string) (*Principal, error) {
+	if len(jwtSecret) == 0 {
+		return nil, UnauthenticatedErr
+	}
+
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", UnauthenticatedErr, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", UnauthenticatedErr)
+	}
+
+	return &Principal{Name: claims.Subject, Admin: claims.Admin, Roles: claims.Roles}, nil
+}
+
+// authenticateNodeToken returns the principal of the nodes if the token is
+// the node token, or nil otherwise.
+func authenticateNodeToken(token string) *Principal {
+	if nodeToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(nodeToken)) == 1 {
+		return &Principal{Name: nodePrincipalName, Node: true}
+	}
+	return nil
+}
+
+// AuthenticateNode identifies other nodes through the client certificate of
+// a TLS connection, if verified with the CA of the nodes. It returns nil if
+// the peer is not a node.
+func AuthenticateNode(state *tls.ConnectionState) *Principal {
+	if !nodeTLS || state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return &Principal{Name: state.VerifiedChains[0][0].Subject.CommonName, Node: true}
+}
+
+// AuthorizeOffloaded checks that a request marked as offloaded comes from
+// another node. If neither authentication nor node certificates are enabled,
+// requests from unknown clients are accepted, but they are not treated as
+// offloaded (see IsNode).
+func AuthorizeOffloaded(p *Principal) error {
+	if (!Enabled && !nodeTLS) || (p != nil && p.Node) {
+		return nil
+	}
+	return fmt.Errorf("%w: only nodes can offload requests", ForbiddenErr)
+}
+
+// IsNode returns true if the principal is another node, i.e., it has been
+// authenticated with the node token or a node certificate.
+func IsNode(p *Principal) bool {
+	return p != nil && p.Node
+}
+
+// BearerToken extracts the token from an Authorization header.
+func BearerToken(header string) string {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grussorusso/serverledge/utils"
+)
+
+func TestAPIKeys(t *testing.T) {
+	utils.SetKVStore(utils.NewMemoryKVStore())
+
+	token, key, err := IssueKey("alice", false, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "alice" || p.Admin || p.Node {
+		t.Errorf("unexpected principal: %+v", p)
+	}
+
+	if _, err := Authenticate(token + "x"); !errors.Is(err, UnauthenticatedErr) {
+		t.Errorf("tampered key accepted: %v", err)
+	}
+
+	if found, err := RevokeKey(key.ID); err != nil || !found {
+		t.Fatalf("revocation failed: %v", err)
+	}
+	if _, err := Authenticate(token); !errors.Is(err, UnauthenticatedErr) {
+		t.Errorf("revoked key accepted: %v", err)
+	}
+
+	// unknown and malformed keys are not cached
+	unknown, _, _ := IssueKey("bob", false, nil, time.Hour)
+	unknown = unknown[:len(apiKeyPrefix)] + "2222222222222222222222" + unknown[len(apiKeyPrefix)+22:]
+	for _, token := range []string{unknown, "sl_../x_secret", apiKeyPrefix + key.ID + "_short"} {
+		if _, err := Authenticate(token); !errors.Is(err, UnauthenticatedErr) {
+			t.Errorf("invalid key %s accepted: %v", token, err)
+		}
+	}
+	keyCacheMutex.Lock()
+	defer keyCacheMutex.Unlock()
+	if len(keyCache) != 0 {
+		t.Errorf("unexpected cached keys: %v", keyCache)
+	}
+}
+
+func TestJWT(t *testing.T) {
+	jwtSecret = []byte("secret")
+	defer func() { jwtSecret = nil }()
+
+	sign := func(exp time.Time) string {
+		claims := Claims{Admin: true, RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "bob",
+			ExpiresAt: jwt.NewNumericDate(exp),
+		}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	p, err := Authenticate(sign(time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "bob" || !p.Admin {
+		t.Errorf("unexpected principal: %+v", p)
+	}
+
+	if _, err := Authenticate(sign(time.Now().Add(-time.Hour))); !errors.Is(err, UnauthenticatedErr) {
+		t.Errorf("expired token accepted: %v", err)
+	}
+}
+
+func TestStaticTokens(t *testing.T) {
+	adminToken, nodeToken = "admin-token", "node-token"
+	defer func() { adminToken, nodeToken = "", "" }()
+
+	if p, err := Authenticate("admin-token"); err != nil || !p.Admin {
+		t.Errorf("admin token not accepted: %v", err)
+	}
+	if p, err := Authenticate("node-token"); err != nil || !p.Node {
+		t.Errorf("node token not accepted: %v", err)
+	}
+	if _, err := Authenticate(""); !errors.Is(err, UnauthenticatedErr) {
+		t.Errorf("empty token accepted")
+	}
+}
+
+func TestRoles(t *testing.T) {
+	Enabled = true
+	defer func() { Enabled = false }()
+
+	p := &Principal{Name: "carol", Roles: map[string]Role{"team-a": ROLE_DEVELOPER, ALL_NAMESPACES: ROLE_INVOKER}}
+	for _, c := range []struct {
+		namespace string
+		action    Action
+		allowed   bool
+	}{
+		{"team-a", ACTION_CREATE, true},
+		{"team-a", ACTION_MANAGE, false},
+		{"team-b", ACTION_INVOKE, true},
+		{"team-b", ACTION_DELETE, false},
+	} {
+		err := Authorize(p, c.namespace, c.action)
+		if allowed := err == nil; allowed != c.allowed {
+			t.Errorf("%s in %s: allowed=%v, expected %v", c.action, c.namespace, allowed, c.allowed)
+		}
+	}
+
+	if err := Authorize(nil, "team-a", ACTION_STATUS); !errors.Is(err, ForbiddenErr) {
+		t.Errorf("anonymous request allowed")
+	}
+	if err := Authorize(&Principal{Admin: true}, "team-b", ACTION_MANAGE); err != nil {
+		t.Errorf("admin not allowed: %v", err)
+	}
+
+	node := &Principal{Name: "edge-1", Node: true}
+	for _, action := range []Action{ACTION_STATUS, ACTION_INVOKE, ACTION_CREATE, ACTION_DELETE, ACTION_MANAGE} {
+		allowed := Authorize(node, "team-b", action) == nil
+		if expected := action == ACTION_STATUS || action == ACTION_INVOKE; allowed != expected {
+			t.Errorf("node %s: allowed=%v, expected %v", action, allowed, expected)
+		}
+	}
+}
+
+func TestNodeCertificates(t *testing.T) {
+	nodeTLS = true
+	defer func() { nodeTLS = false }()
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "edge-1"}}
+	p := AuthenticateNode(&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}})
+	if p == nil || p.Name != "edge-1" || !p.Node {
+		t.Fatalf("unexpected principal: %+v", p)
+	}
+	if err := AuthorizeOffloaded(p); err != nil {
+		t.Errorf("node not allowed to offload: %v", err)
+	}
+
+	// unverified or missing certificates do not identify nodes
+	if p := AuthenticateNode(&tls.ConnectionState{}); p != nil {
+		t.Errorf("unverified peer identified as node: %+v", p)
+	}
+	if err := AuthorizeOffloaded(&Principal{Name: "alice"}); !errors.Is(err, ForbiddenErr) {
+		t.Errorf("user allowed to offload: %v", err)
+	}
+	if err := AuthorizeOffloaded(nil); !errors.Is(err, ForbiddenErr) {
+		t.Errorf("anonymous client allowed to offload: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const principalKey = "principal"
+
+type principalContextKey struct{}
+
+// Middleware authenticates requests to the REST API through the
+// Authorization header (i.e., "Bearer <API key or JWT>").
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if p := AuthenticateNode(c.Request().TLS); p != nil {
+				c.Set(principalKey, p)
+				return next(c)
+			}
+			token := BearerToken(c.Request().Header.Get(echo.HeaderAuthorization))
+			if !Enabled {
+				// nodes are still identified, e.g., to skip the
+				// rate limits for offloaded requests
+				if p := authenticateNodeToken(token); p != nil {
+					c.Set(principalKey, p)
+				}
+				return next(c)
+			}
+
+			p, err := Authenticate(token)
+			if errors.Is(err, UnauthenticatedErr) {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return c.String(http.StatusUnauthorized, "")
+			} else if err != nil {
+				slog.Error("Authentication failed", "err", err)
+				return c.String(http.StatusServiceUnavailable, "")
+			}
+
+			c.Set(principalKey, p)
+			return next(c)
+		}
+	}
+}
+
+// RequireAdmin is a middleware rejecting requests not coming from admins.
+func RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if Enabled {
+			if p := GetPrincipal(c); p == nil || !p.Admin {
+				return c.String(http.StatusForbidden, "")
+			}
+		}
+		return next(c)
+	}
+}
+
+// GetPrincipal returns the principal that sent a request to the REST API
+// (nil if authentication is disabled).
+func GetPrincipal(c echo.Context) *Principal {
+	p, _ := c.Get(principalKey).(*Principal)
+	return p
+}
+
+// PrincipalFromContext returns the principal that sent a gRPC request (nil
+// if authentication is disabled).
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return p
+}
+
+func authenticateGRPC(ctx context.Context) (context.Context, error) {
+	if pr, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := pr.AuthInfo.(credentials.TLSInfo); ok {
+			if p := AuthenticateNode(&tlsInfo.State); p != nil {
+				return context.WithValue(ctx, principalContextKey{}, p), nil
+			}
+		}
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = BearerToken(values[0])
+		}
+	}
+	if !Enabled {
+		if p := authenticateNodeToken(token); p != nil {
+			return context.WithValue(ctx, principalContextKey{}, p), nil
+		}
+		return ctx, nil
+	}
+
+	p, err := Authenticate(token)
+	if errors.Is(err, UnauthenticatedErr) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	} else if err != nil {
+		slog.Error("Authentication failed", "err", err)
+		return nil, status.Error(codes.Unavailable, "authentication failed")
+	}
+	return context.WithValue(ctx, principalContextKey{}, p), nil
+}
+
+// UnaryServerInterceptor authenticates unary gRPC calls through the
+// "authorization" metadata.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticateGRPC(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates streaming gRPC calls through the
+// "authorization" metadata.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticateGRPC(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// SetNodeCredentials adds the node token (if any) to an outgoing HTTP
+// request.
+func SetNodeCredentials(header http.Header) {
+	if nodeToken != "" {
+		header.Set(echo.HeaderAuthorization, "Bearer "+nodeToken)
+	}
+}
+
+// WithNodeCredentials returns a context for outgoing gRPC calls carrying the
+// node token (if any).
+func WithNodeCredentials(ctx context.Context) context.Context {
+	if nodeToken == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+nodeToken)
+}
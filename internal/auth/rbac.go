@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ForbiddenErr = errors.New("permission denied")
+
+// A Role grants a set of permissions within a namespace.
+type Role string
+
+const (
+	ROLE_ADMIN     Role = "admin"
+	ROLE_DEVELOPER Role = "developer"
+	ROLE_INVOKER   Role = "invoker"
+)
+
+// An Action is an operation on the functions (or other resources) of a
+// namespace.
+type Action int
+
+const (
+	ACTION_STATUS Action = iota // list functions and poll async results
+	ACTION_INVOKE
+	ACTION_CREATE
+	ACTION_DELETE
+	ACTION_MANAGE // query usage and manage the namespace
+)
+
+func (a Action) String() string {
+	switch a {
+	case ACTION_STATUS:
+		return "status"
+	case ACTION_INVOKE:
+		return "invoke"
+	case ACTION_CREATE:
+		return "create"
+	case ACTION_DELETE:
+		return "delete"
+	case ACTION_MANAGE:
+		return "manage"
+	default:
+		return "unknown"
+	}
+}
+
+var permissions = map[Role][]Action{
+	ROLE_INVOKER:   {ACTION_STATUS, ACTION_INVOKE},
+	ROLE_DEVELOPER: {ACTION_STATUS, ACTION_INVOKE, ACTION_CREATE, ACTION_DELETE},
+	ROLE_ADMIN:     {ACTION_STATUS, ACTION_INVOKE, ACTION_CREATE, ACTION_DELETE, ACTION_MANAGE},
+}
+
+// nodePermissions are granted to other nodes in every namespace, so that
+// they can offload requests and poll their results.
+var nodePermissions = []Action{ACTION_STATUS, ACTION_INVOKE}
+
+// ALL_NAMESPACES can be used in place of a namespace to grant a role in
+// every namespace.
+const ALL_NAMESPACES = "*"
+
+// ParseRole parses a role binding in the form "<namespace>:<role>".
+func ParseRole(binding string) (namespace string, role Role, err error) {
+	parts := strings.SplitN(binding, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("invalid role binding '%s': expected <namespace>:<role>", binding)
+	}
+	role = Role(parts[1])
+	if _, ok := permissions[role]; !ok {
+		return "", "", fmt.Errorf("unknown role: %s", parts[1])
+	}
+	return parts[0], role, nil
+}
+
+// ValidateRoles checks that roles are bound to namespaces and known.
+func ValidateRoles(roles map[string]Role) error {
+	for namespace, role := range roles {
+		if _, _, err := ParseRole(namespace + ":" + string(role)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Can checks whether the principal is allowed to perform an action in a
+// namespace. Admins can do anything, while nodes can only invoke functions
+// and query their status.
+func (p *Principal) Can(namespace string, action Action) bool {
+	if p.Admin {
+		return true
+	}
+	if p.Node {
+		return slices.Contains(nodePermissions, action)
+	}
+
+	for _, ns := range []string{namespace, ALL_NAMESPACES} {
+		if role, ok := p.Roles[ns]; ok && slices.Contains(permissions[role], action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize checks whether a principal is allowed to perform an action in a
+// namespace. Everything is allowed if authentication is disabled.
+func Authorize(p *Principal, namespace string, action Action) error {
+	if !Enabled {
+		return nil
+	}
+	if p == nil || !p.Can(namespace, action) {
+		return fmt.Errorf("%w: cannot %s in namespace '%s'", ForbiddenErr, action, namespace)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package billing
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/config"
+)
+
+const (
+	SINK_FILE = "file"
+	SINK_ETCD = "etcd"
+)
+
+// A Record accounts for the resources used by a completed invocation.
+type Record struct {
+	ReqId        string
+	Function     string
+	Namespace    string
+	Owner        string
+	Node         string    // node where the function was executed
+	Time         time.Time // completion time
+	Success      bool
+	Duration     float64 // execution time (seconds)
+	InitTime     float64 // seconds
+	MemoryMB     int64   // memory allocated to the function
+	CPUSeconds   float64
+	PeakMemoryMB float64
+	GBSeconds    float64 // allocated memory (GB) x duration
+	WarmStart    bool
+	Offloaded    bool // the request was offloaded to this node by another node
+}
+
+// A Sink stores billing records.
+type Sink interface {
+	Write(*Record) error
+	// Read returns the records completed in [since, until).
+	Read(since, until time.Time) ([]*Record, error)
+}
+
+var Enabled bool
+var sink Sink
+var records chan *Record
+
+// Init configures the sink of billing records, if billing is enabled.
+func Init() error {
+	if !config.GetBool(config.BILLING_ENABLED, false) {
+		Enabled = false
+		return nil
+	}
+
+	var err error
+	switch sinkType := config.GetString(config.BILLING_SINK, SINK_FILE); sinkType {
+	case SINK_FILE:
+		sink, err = NewFileSink(config.GetString(config.BILLING_FILE, "serverledge-billing.jsonl"))
+	case SINK_ETCD:
+		retention := time.Duration(config.GetInt(config.BILLING_RETENTION, 0)) * time.Second
+		sink = NewEtcdSink(retention)
+	default:
+		err = fmt.Errorf("unknown billing sink: %s", sinkType)
+	}
+	if err != nil {
+		return err
+	}
+
+	SetSink(sink)
+	slog.Info("Billing enabled", "sink", config.GetString(config.BILLING_SINK, SINK_FILE))
+	return nil
+}
+
+// SetSink enables billing, writing records to the given sink.
+func SetSink(s Sink) {
+	sink = s
+	records = make(chan *Record, 1000)
+	Enabled = true
+	go writeRecords(s, records)
+}
+
+// Add queues a record to be written to the sink. Records are dropped if the
+// sink cannot keep up, so that invocations are never slowed down.
+func Add(r *Record) {
+	select {
+	case records <- r:
+	default:
+		slog.Warn("Billing record dropped", "reqId", r.ReqId, "function", r.Function)
+	}
+}
+
+func writeRecords(s Sink, records chan *Record) {
+	for r := range records {
+		if err := s.Write(r); err != nil {
+			slog.Error("Could not write billing record", "reqId", r.ReqId, "function", r.Function, "err", err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package billing
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSink(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "billing.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Now()
+	for i, owner := range []string{"alice", "bob", "alice"} {
+		r := &Record{ReqId: "r", Function: "f", Owner: owner, Time: t0.Add(time.Duration(i) * time.Minute), Success: true, GBSeconds: 0.5}
+		if err := sink.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := sink.Read(t0.Add(time.Second), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Owner != "bob" {
+		t.Errorf("unexpected records: %v", records)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	records := []*Record{
+		{Function: "f", Owner: "alice", Node: "n1", Success: true, WarmStart: false, Duration: 1.0, CPUSeconds: 0.5, GBSeconds: 0.25},
+		{Function: "g", Owner: "alice", Node: "n2", Success: false, WarmStart: true, Duration: 2.0, CPUSeconds: 1.0, GBSeconds: 0.5, Offloaded: true},
+		{Function: "f", Owner: "bob", Node: "n1", Success: true, WarmStart: true, Duration: 1.0, CPUSeconds: 0.5, GBSeconds: 0.25},
+	}
+
+	usage, err := Aggregate(records, GROUP_BY_OWNER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Usage{
+		{Key: "alice", Invocations: 2, Failures: 1, ColdStarts: 1, Offloaded: 1, Duration: 3.0, CPUSeconds: 1.5, GBSeconds: 0.75},
+		{Key: "bob", Invocations: 1, Duration: 1.0, CPUSeconds: 0.5, GBSeconds: 0.25},
+	}
+	if len(usage) != len(expected) {
+		t.Fatalf("unexpected usage: %v", usage)
+	}
+	for i := range expected {
+		if usage[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], usage[i])
+		}
+	}
+
+	usage, _ = Aggregate(records, GROUP_BY_FUNCTION)
+	if len(usage) != 2 || usage[0].Key != "f" || usage[0].Invocations != 2 {
+		t.Errorf("unexpected usage by function: %v", usage)
+	}
+
+	if _, err := Aggregate(records, "team"); err == nil {
+		t.Error("expected error for unknown grouping")
+	}
+}
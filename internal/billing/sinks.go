@@ -0,0 +1,117 @@
+package billing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grussorusso/serverledge/utils"
+)
+
+// FileSink appends records to a local file, as JSON lines.
+type FileSink struct {
+	path  string
+	file  *os.File
+	mutex sync.Mutex
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open billing file: %v", err)
+	}
+	return &FileSink{path: path, file: f}, nil
+}
+
+func (s *FileSink) Write(r *Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileSink) Read(since, until time.Time) ([]*Record, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := make([]*Record, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		if inInterval(&r, since, until) {
+			result = append(result, &r)
+		}
+	}
+	return result, scanner.Err()
+}
+
+// EtcdSink stores records in the key-value store shared by the nodes, so
+// that usage can be aggregated across the whole system.
+type EtcdSink struct {
+	retention time.Duration // no expiration if zero
+}
+
+func NewEtcdSink(retention time.Duration) *EtcdSink {
+	return &EtcdSink{retention: retention}
+}
+
+const etcdPrefix = "billing/"
+
+func (s *EtcdSink) Write(r *Record) error {
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s%s/%s", etcdPrefix, r.Node, r.ReqId)
+	return store.Put(context.Background(), key, payload, s.retention)
+}
+
+func (s *EtcdSink) Read(since, until time.Time) ([]*Record, error) {
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return nil, err
+	}
+	values, err := store.GetWithPrefix(context.Background(), etcdPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Record, 0, len(values))
+	for _, value := range values {
+		var r Record
+		if err := json.Unmarshal(value, &r); err != nil {
+			return nil, err
+		}
+		if inInterval(&r, since, until) {
+			result = append(result, &r)
+		}
+	}
+	return result, nil
+}
+
+func inInterval(r *Record, since, until time.Time) bool {
+	return !r.Time.Before(since) && (until.IsZero() || r.Time.Before(until))
+}
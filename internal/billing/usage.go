@@ -0,0 +1,115 @@
+package billing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Keys to group usage by
+const (
+	GROUP_BY_NAMESPACE = "namespace"
+	GROUP_BY_OWNER     = "owner"
+	GROUP_BY_FUNCTION  = "function"
+	GROUP_BY_NODE      = "node"
+)
+
+// Filter selects the records to aggregate. Empty fields match any record.
+type Filter struct {
+	Namespace string
+	Owner     string
+	Function  string
+	Since     time.Time
+	Until     time.Time
+}
+
+// Usage aggregates the records of a namespace, an owner, a function or a node.
+type Usage struct {
+	Key         string
+	Invocations int
+	Failures    int
+	ColdStarts  int
+	Offloaded   int
+	Duration    float64
+	CPUSeconds  float64
+	GBSeconds   float64
+}
+
+// Query aggregates the records in the sink matching the filter.
+func Query(f Filter, groupBy string) ([]Usage, error) {
+	if !Enabled {
+		return nil, fmt.Errorf("billing is not enabled")
+	}
+
+	records, err := sink.Read(f.Since, f.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]*Record, 0, len(records))
+	for _, r := range records {
+		if (f.Namespace == "" || r.namespace() == f.Namespace) &&
+			(f.Owner == "" || r.Owner == f.Owner) &&
+			(f.Function == "" || r.Function == f.Function) {
+			matching = append(matching, r)
+		}
+	}
+	return Aggregate(matching, groupBy)
+}
+
+// Aggregate sums up the usage in the records, grouping them by namespace,
+// owner, function or node.
+func Aggregate(records []*Record, groupBy string) ([]Usage, error) {
+	var keyOf func(*Record) string
+	switch groupBy {
+	case GROUP_BY_NAMESPACE:
+		keyOf = func(r *Record) string { return r.namespace() }
+	case GROUP_BY_OWNER, "":
+		keyOf = func(r *Record) string { return r.Owner }
+	case GROUP_BY_FUNCTION:
+		keyOf = func(r *Record) string { return r.Function }
+	case GROUP_BY_NODE:
+		keyOf = func(r *Record) string { return r.Node }
+	default:
+		return nil, fmt.Errorf("cannot group usage by: %s", groupBy)
+	}
+
+	usage := make(map[string]*Usage)
+	for _, r := range records {
+		key := keyOf(r)
+		u, ok := usage[key]
+		if !ok {
+			u = &Usage{Key: key}
+			usage[key] = u
+		}
+		u.Invocations++
+		if !r.Success {
+			u.Failures++
+		}
+		if !r.WarmStart {
+			u.ColdStarts++
+		}
+		if r.Offloaded {
+			u.Offloaded++
+		}
+		u.Duration += r.Duration
+		u.CPUSeconds += r.CPUSeconds
+		u.GBSeconds += r.GBSeconds
+	}
+
+	result := make([]Usage, 0, len(usage))
+	for _, u := range usage {
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result, nil
+}
+
+// namespace returns the namespace of the function (records written before
+// namespaces were introduced belong to the default one).
+func (r *Record) namespace() string {
+	if r.Namespace == "" {
+		return "default"
+	}
+	return r.Namespace
+}
@@ -0,0 +1,450 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/api"
+	"github.com/grussorusso/serverledge/internal/client"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/sim"
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Generates load against one or more nodes",
+	Long: `Generates open-loop (Poisson or trace-driven) or closed-loop load against
+one or more Serverledge nodes, and reports latency percentiles along with
+cold starts, offloaded and dropped requests.`,
+	Run: bench,
+}
+
+var benchNodes, benchFunctions, benchClasses []string
+var benchMode, benchTrace, benchOutput string
+var benchRate, benchAsyncRatio, benchThinkTime float64
+var benchDuration time.Duration
+var benchRequests, benchConcurrency int
+var benchSeed int64
+
+func initBenchCmd() {
+	rootCmd.AddCommand(benchCmd)
+	benchCmd.Flags().StringSliceVarP(&benchNodes, "node", "n", nil, "target node <host>:<port> (default: --host and --port)")
+	benchCmd.Flags().StringSliceVarP(&benchFunctions, "function", "f", nil, "function to invoke: <name>[:<weight>]")
+	benchCmd.Flags().StringSliceVarP(&benchClasses, "class", "c", []string{"low"}, "QoS class: <class>[:<weight>]")
+	benchCmd.Flags().Float64VarP(&qosMaxRespT, "resptime", "", -1.0, "Max. response time (optional)")
+	benchCmd.Flags().StringSliceVarP(&params, "param", "p", nil, "Function parameter: <name>:<value>")
+	benchCmd.Flags().Float64VarP(&benchAsyncRatio, "async_ratio", "", 0.0, "fraction of asynchronous invocations")
+	benchCmd.Flags().StringVarP(&benchMode, "mode", "m", "open", "load generation mode (open, closed)")
+	benchCmd.Flags().Float64VarP(&benchRate, "rate", "r", 10.0, "arrival rate (req/s) of Poisson arrivals in open-loop mode")
+	benchCmd.Flags().StringVarP(&benchTrace, "trace", "t", "", "trace to replay in open-loop mode (JSON Lines, or Azure Functions CSV)")
+	benchCmd.Flags().IntVarP(&benchConcurrency, "concurrency", "", 1, "number of clients in closed-loop mode")
+	benchCmd.Flags().Float64VarP(&benchThinkTime, "think_time", "", 0.0, "think time (s) of clients in closed-loop mode")
+	benchCmd.Flags().DurationVarP(&benchDuration, "duration", "d", 60*time.Second, "duration of the benchmark (the whole trace, if replaying one)")
+	benchCmd.Flags().IntVarP(&benchRequests, "requests", "", 0, "max. number of requests (0 for no limit)")
+	benchCmd.Flags().StringVarP(&benchOutput, "output", "o", "", "file where the execution report of every request is written (JSON Lines)")
+	benchCmd.Flags().Int64VarP(&benchSeed, "seed", "", 0, "random seed (0 for a random one)")
+}
+
+// weightedChoice picks values at random according to their weights.
+type weightedChoice struct {
+	values  []string
+	weights []float64
+	total   float64
+}
+
+// parseWeightedChoice parses values in the form <value>[:<weight>].
+func parseWeightedChoice(specs []string) (*weightedChoice, error) {
+	c := &weightedChoice{}
+	for _, spec := range specs {
+		value, weight := spec, 1.0
+		if i := strings.LastIndex(spec, ":"); i >= 0 {
+			var err error
+			value = spec[:i]
+			if weight, err = strconv.ParseFloat(spec[i+1:], 64); err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid weight in '%s'", spec)
+			}
+		}
+		c.values = append(c.values, value)
+		c.weights = append(c.weights, weight)
+		c.total += weight
+	}
+	if c.total <= 0 {
+		return nil, fmt.Errorf("no values with positive weight")
+	}
+	return c, nil
+}
+
+func (c *weightedChoice) pick(rng *rand.Rand) string {
+	x := rng.Float64() * c.total
+	for i, w := range c.weights {
+		if x < w {
+			return c.values[i]
+		}
+		x -= w
+	}
+	return c.values[len(c.values)-1]
+}
+
+// requestGenerator generates the requests of the benchmark, picking
+// functions and classes at random and assigning nodes in round-robin.
+type requestGenerator struct {
+	nodes      []string
+	functions  *weightedChoice // nil if functions are taken from a trace
+	classes    *weightedChoice
+	asyncRatio float64
+	params     map[string]interface{}
+	rng        *rand.Rand
+	count      int // requests generated so far
+}
+
+func (g *requestGenerator) next() benchRequest {
+	r := benchRequest{
+		Node:   g.nodes[g.count%len(g.nodes)],
+		Class:  g.classes.pick(g.rng),
+		Async:  g.rng.Float64() < g.asyncRatio,
+		Params: g.params,
+	}
+	if g.functions != nil {
+		r.Function = g.functions.pick(g.rng)
+	}
+	g.count++
+	return r
+}
+
+// openLoopRequests returns the requests to issue in open-loop mode, sorted
+// by time. Trace arrivals are replayed if given; otherwise, arrivals follow
+// a Poisson process with the given rate. Requests arriving after duration,
+// or beyond maxRequests (if positive), are discarded.
+func openLoopRequests(g *requestGenerator, arrivals []sim.Arrival, rate float64, duration time.Duration, maxRequests int) []benchRequest {
+	requests := make([]benchRequest, 0)
+	t := 0.0
+	for i := 0; maxRequests <= 0 || i < maxRequests; i++ {
+		var r benchRequest
+		if arrivals != nil {
+			if i >= len(arrivals) {
+				break
+			}
+			a := arrivals[i]
+			r = g.next()
+			t = a.Time
+			r.Function = a.Function
+			if a.Class != "" {
+				r.Class = a.Class
+			}
+			if a.Params != nil {
+				r.Params = a.Params
+			}
+		} else {
+			r = g.next()
+			t += g.rng.ExpFloat64() / rate
+		}
+		if toDuration(t) > duration {
+			break
+		}
+		r.Time = t
+		requests = append(requests, r)
+	}
+	return requests
+}
+
+// traceLength returns the time of the last arrival of a trace.
+func traceLength(arrivals []sim.Arrival) time.Duration {
+	if len(arrivals) == 0 {
+		return 0
+	}
+	return toDuration(arrivals[len(arrivals)-1].Time)
+}
+
+// toDuration converts seconds to a duration.
+func toDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+// benchRequest is a request issued by the load generator.
+type benchRequest struct {
+	Time     float64 // seconds since the beginning of the benchmark
+	Node     string
+	Function string
+	Class    string
+	Async    bool
+	Params   map[string]interface{}
+}
+
+// benchRecord is written to the output file for every request.
+type benchRecord struct {
+	benchRequest
+	ReqId   string `json:",omitempty"`
+	Outcome string
+	Latency float64                   // seconds, as observed by the client
+	Report  *function.ExecutionReport `json:",omitempty"`
+}
+
+// benchmark holds the state of a run.
+type benchmark struct {
+	start   time.Time
+	client  *http.Client
+	mutex   sync.Mutex
+	results []sim.Result
+	output  *json.Encoder
+}
+
+func bench(cmd *cobra.Command, args []string) {
+	if len(benchNodes) == 0 {
+		benchNodes = []string{fmt.Sprintf("%s:%d", ServerConfig.Host, ServerConfig.Port)}
+	}
+	if benchTrace == "" && len(benchFunctions) == 0 {
+		fmt.Println("At least a function (or a trace) is needed.")
+		cmd.Help()
+		os.Exit(1)
+	}
+	if benchMode != "open" && benchMode != "closed" {
+		fmt.Printf("Invalid mode: %s\n", benchMode)
+		os.Exit(1)
+	}
+	if benchMode == "closed" && benchTrace != "" {
+		fmt.Println("Traces can only be replayed in open-loop mode.")
+		os.Exit(1)
+	}
+
+	var arrivals []sim.Arrival
+	if benchTrace != "" {
+		var err error
+		if arrivals, err = sim.ReadTrace(benchTrace); err != nil {
+			fmt.Printf("Could not read trace: %v\n", err)
+			os.Exit(1)
+		}
+		if length := traceLength(arrivals); !cmd.Flags().Changed("duration") {
+			benchDuration = length
+		} else if benchDuration < length {
+			fmt.Printf("Warning: the trace lasts %v, only the first %v are replayed\n", length, benchDuration)
+		}
+	}
+
+	var functions *weightedChoice
+	var err error
+	if len(benchFunctions) > 0 {
+		if functions, err = parseWeightedChoice(benchFunctions); err != nil {
+			fmt.Printf("Invalid functions: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	classes, err := parseWeightedChoice(benchClasses)
+	if err != nil {
+		fmt.Printf("Invalid classes: %v\n", err)
+		os.Exit(1)
+	}
+
+	paramsMap := make(map[string]interface{})
+	for _, rawParam := range params {
+		tokens := strings.Split(rawParam, ":")
+		if len(tokens) < 2 {
+			cmd.Help()
+			os.Exit(1)
+		}
+		paramsMap[tokens[0]] = strings.Join(tokens[1:], ":")
+	}
+
+	if benchSeed == 0 {
+		benchSeed = time.Now().UnixNano()
+	}
+	gen := &requestGenerator{
+		nodes:      benchNodes,
+		functions:  functions,
+		classes:    classes,
+		asyncRatio: benchAsyncRatio,
+		params:     paramsMap,
+		rng:        rand.New(rand.NewSource(benchSeed)),
+	}
+
+	tr := &http.Transport{
+		MaxIdleConns:        2500,
+		MaxIdleConnsPerHost: 2500,
+		TLSClientConfig:     tlsConfig(),
+	}
+	b := &benchmark{client: &http.Client{Transport: tr, Timeout: 5 * time.Minute}}
+	if benchOutput != "" {
+		file, err := os.Create(benchOutput)
+		if err != nil {
+			fmt.Printf("Could not create '%s': %v\n", benchOutput, err)
+			os.Exit(1)
+		}
+		w := bufio.NewWriter(file)
+		defer file.Close()
+		defer w.Flush()
+		b.output = json.NewEncoder(w)
+	}
+
+	b.start = time.Now()
+	var wg sync.WaitGroup
+	if benchMode == "closed" {
+		var counterMutex sync.Mutex
+		for i := 0; i < benchConcurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Since(b.start) < benchDuration {
+					counterMutex.Lock()
+					if benchRequests > 0 && gen.count >= benchRequests {
+						counterMutex.Unlock()
+						return
+					}
+					r := gen.next()
+					counterMutex.Unlock()
+
+					r.Time = time.Since(b.start).Seconds()
+					b.run(r)
+					time.Sleep(time.Duration(benchThinkTime * float64(time.Second)))
+				}
+			}()
+		}
+	} else {
+		for _, r := range openLoopRequests(gen, arrivals, benchRate, benchDuration, benchRequests) {
+			time.Sleep(time.Until(b.start.Add(toDuration(r.Time))))
+			wg.Add(1)
+			go func(r benchRequest) {
+				defer wg.Done()
+				b.run(r)
+			}(r)
+		}
+	}
+	wg.Wait()
+
+	fmt.Printf("Completed %d requests in %v\n\n", len(b.results), time.Since(b.start).Round(time.Millisecond))
+	sim.NewReport(b.results).Print(os.Stdout)
+}
+
+// run issues a request and records its outcome.
+func (b *benchmark) run(r benchRequest) {
+	record := benchRecord{benchRequest: r}
+	sent := time.Now()
+	reqId, report, outcome := b.invoke(r)
+	record.Latency = time.Since(sent).Seconds()
+	record.ReqId = reqId
+	record.Report = report
+
+	result := sim.Result{Function: r.Function, Outcome: outcome, ResponseTime: record.Latency}
+	switch outcome {
+	case sim.Completed:
+		record.Outcome = "completed"
+		result.Offloaded = report.SchedAction == "O"
+		result.ColdStart = !report.IsWarmStart
+	case sim.Dropped:
+		record.Outcome = "dropped"
+	default:
+		record.Outcome = "failed"
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.results = append(b.results, result)
+	if b.output != nil {
+		b.output.Encode(record)
+	}
+}
+
+// invoke sends an invocation request and waits for its result, polling
+// for the results of asynchronous invocations.
+func (b *benchmark) invoke(r benchRequest) (string, *function.ExecutionReport, sim.Outcome) {
+	request := client.InvocationRequest{
+		Params:          r.Params,
+		QoSClass:        int64(api.DecodeServiceClass(r.Class)),
+		QoSMaxRespT:     qosMaxRespT,
+		CanDoOffloading: true,
+		Async:           r.Async}
+	invocationBody, err := json.Marshal(request)
+	if err != nil {
+		return "", nil, sim.Failed
+	}
+
+	url := fmt.Sprintf("%s/invoke/%s", nodeUrl(r.Node), r.Function)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(invocationBody))
+	if err != nil {
+		return "", nil, sim.Failed
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	setCredentials(req)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		if verbose {
+			fmt.Printf("Invocation failed: %v\n", err)
+		}
+		return "", nil, sim.Failed
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "", nil, sim.Dropped
+	} else if resp.StatusCode != http.StatusOK {
+		if verbose {
+			fmt.Printf("Invocation failed: %v\n", resp.Status)
+		}
+		return "", nil, sim.Failed
+	}
+
+	if !r.Async {
+		var response function.Response
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil || !response.Success {
+			return "", nil, sim.Failed
+		}
+		return "", &response.ExecutionReport, sim.Completed
+	}
+
+	var asyncResponse function.AsyncResponse
+	if err := json.NewDecoder(resp.Body).Decode(&asyncResponse); err != nil {
+		return "", nil, sim.Failed
+	}
+	report, outcome := b.pollResult(r.Node, asyncResponse.ReqId)
+	return asyncResponse.ReqId, report, outcome
+}
+
+// pollResult waits for the result of an asynchronous invocation.
+func (b *benchmark) pollResult(node, reqId string) (*function.ExecutionReport, sim.Outcome) {
+	url := fmt.Sprintf("%s/poll/%s", nodeUrl(node), reqId)
+	deadline := time.Now().Add(b.client.Timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, sim.Failed
+		}
+		setCredentials(req)
+		resp, err := b.client.Do(req)
+		if err != nil {
+			return nil, sim.Failed
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			continue
+		}
+
+		var response function.Response
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK || !response.Success {
+			return nil, sim.Failed
+		}
+		return &response.ExecutionReport, sim.Completed
+	}
+
+	return nil, sim.Failed
+}
+
+// nodeUrl returns the base URL of a node given as host:port.
+func nodeUrl(node string) string {
+	if ServerConfig.TLS {
+		return "https://" + node
+	}
+	return "http://" + node
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/sim"
+)
+
+func newTestGenerator(t *testing.T, functions []string) *requestGenerator {
+	g := &requestGenerator{nodes: []string{"a:1323", "b:1323"}, rng: rand.New(rand.NewSource(1))}
+	var err error
+	if functions != nil {
+		if g.functions, err = parseWeightedChoice(functions); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.classes, err = parseWeightedChoice([]string{"low"}); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestPoissonArrivals(t *testing.T) {
+	g := newTestGenerator(t, []string{"f:3", "g:1"})
+	requests := openLoopRequests(g, nil, 100.0, 60*time.Second, 0)
+
+	// 6000 requests are expected on average
+	if n := len(requests); n < 5700 || n > 6300 {
+		t.Errorf("unexpected number of requests: %d", n)
+	}
+	counts := make(map[string]int)
+	for i, r := range requests {
+		if r.Time > 60.0 || (i > 0 && r.Time < requests[i-1].Time) {
+			t.Fatalf("request %d arrives at %f", i, r.Time)
+		}
+		if r.Node != g.nodes[i%2] {
+			t.Errorf("request %d sent to %s", i, r.Node)
+		}
+		counts[r.Function]++
+	}
+	if counts["f"] < 2*counts["g"] {
+		t.Errorf("functions not picked according to their weights: %v", counts)
+	}
+
+	g = newTestGenerator(t, []string{"f"})
+	if n := len(openLoopRequests(g, nil, 100.0, 60*time.Second, 10)); n != 10 {
+		t.Errorf("expected 10 requests, got %d", n)
+	}
+}
+
+func TestTraceArrivals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	trace := `{"time": 90.5, "function": "g", "class": "performance"}
+{"time": 0.5, "function": "f", "params": {"n": 1}}
+{"time": 30, "function": "f"}
+`
+	if err := os.WriteFile(path, []byte(trace), 0600); err != nil {
+		t.Fatal(err)
+	}
+	arrivals, err := sim.ReadTrace(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	length := traceLength(arrivals)
+	if length != 90500*time.Millisecond {
+		t.Errorf("unexpected trace length: %v", length)
+	}
+
+	requests := openLoopRequests(newTestGenerator(t, nil), arrivals, 0, length, 0)
+	if len(requests) != 3 {
+		t.Fatalf("expected the whole trace to be replayed, got %d requests", len(requests))
+	}
+	if r := requests[0]; r.Time != 0.5 || r.Function != "f" || r.Class != "low" || r.Params["n"] != 1.0 {
+		t.Errorf("unexpected first request: %+v", r)
+	}
+	if r := requests[2]; r.Time != 90.5 || r.Function != "g" || r.Class != "performance" {
+		t.Errorf("unexpected last request: %+v", r)
+	}
+
+	if requests = openLoopRequests(newTestGenerator(t, nil), arrivals, 0, 60*time.Second, 0); len(requests) != 2 {
+		t.Errorf("expected 2 requests within 60s, got %d", len(requests))
+	}
+}
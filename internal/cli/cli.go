@@ -1,12 +1,17 @@
 package cli
 
 import (
+	"bytes"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/grussorusso/serverledge/internal/api"
@@ -43,6 +48,12 @@ var createCmd = &cobra.Command{
 	Run:   create,
 }
 
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Updates an existing function (only the given options are changed)",
+	Run:   update,
+}
+
 var deleteCmd = &cobra.Command{
 	Use:   "delete",
 	Short: "Deletes a function",
@@ -61,12 +72,24 @@ var statusCmd = &cobra.Command{
 	Run:   getStatus,
 }
 
-var funcName, runtime, handler, customImage, src, qosClass string
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Prints resource usage accounted for billing",
+	Run:   getUsage,
+}
+
+var funcName, runtime, handler, customImage, src, qosClass, owner string
+var namespace string
+var usageSince, usageUntil, usageGroupBy string
 var requestId string
 var memory int64
+var maxConcurrency int
 var cpuDemand, qosMaxRespT float64
 var params []string
+var envVars []string
+var isolationOpts []string
 var paramsFile string
+var payloadFile, contentType, outputFile string
 var asyncInvocation bool
 var verbose bool
 
@@ -74,31 +97,73 @@ func Init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().StringVarP(&ServerConfig.Host, "host", "H", ServerConfig.Host, "remote Serverledge host")
 	rootCmd.PersistentFlags().IntVarP(&ServerConfig.Port, "port", "P", ServerConfig.Port, "remote Serverledge port")
+	rootCmd.PersistentFlags().StringVarP(&ServerConfig.Token, "token", "T", ServerConfig.Token, "API key or JWT token for authentication")
+	rootCmd.PersistentFlags().BoolVarP(&ServerConfig.TLS, "tls", "", ServerConfig.TLS, "connect to the server over TLS")
+	rootCmd.PersistentFlags().StringVarP(&ServerConfig.CA, "ca", "", ServerConfig.CA, "CA certificates used to verify the server (TLS only)")
 
 	rootCmd.AddCommand(invokeCmd)
-	invokeCmd.Flags().StringVarP(&funcName, "function", "f", "", "name of the function")
+	invokeCmd.Flags().StringVarP(&funcName, "function", "f", "", "name of the function ([<namespace>/]<name>)")
 	invokeCmd.Flags().Float64VarP(&qosMaxRespT, "resptime", "", -1.0, "Max. response time (optional)")
 	invokeCmd.Flags().StringVarP(&qosClass, "class", "c", "", "QoS class (optional)")
 	invokeCmd.Flags().StringSliceVarP(&params, "param", "p", nil, "Function parameter: <name>:<value>")
 	invokeCmd.Flags().StringVarP(&paramsFile, "params_file", "j", "", "File containing parameters (JSON)")
 	invokeCmd.Flags().BoolVarP(&asyncInvocation, "async", "a", false, "Asynchronous invocation")
+	invokeCmd.Flags().StringVarP(&payloadFile, "payload_file", "", "", "File containing a raw (non-JSON) payload for the function")
+	invokeCmd.Flags().StringVarP(&contentType, "content_type", "", "application/octet-stream", "Content type of the raw payload")
+	invokeCmd.Flags().StringVarP(&outputFile, "output", "o", "", "File where the function result is written (useful for binary results)")
 
 	rootCmd.AddCommand(createCmd)
-	createCmd.Flags().StringVarP(&funcName, "function", "f", "", "name of the function")
+	createCmd.Flags().StringVarP(&funcName, "function", "f", "", "name of the function ([<namespace>/]<name>)")
 	createCmd.Flags().StringVarP(&runtime, "runtime", "", "python38", "runtime for the function")
 	createCmd.Flags().StringVarP(&handler, "handler", "", "", "function handler (runtime specific)")
 	createCmd.Flags().Int64VarP(&memory, "memory", "", 128, "memory (in MB) for the function")
 	createCmd.Flags().Float64VarP(&cpuDemand, "cpu", "", 0.0, "estimated CPU demand for the function (1.0 = 1 core)")
 	createCmd.Flags().StringVarP(&src, "src", "", "", "source for the function (single file, directory or TAR archive) (not necessary for runtime==custom)")
 	createCmd.Flags().StringVarP(&customImage, "custom_image", "", "", "custom container image (only if runtime == 'custom')")
+	createCmd.Flags().IntVarP(&maxConcurrency, "max_concurrency", "", 1, "max. number of concurrent invocations served by a single container")
+	createCmd.Flags().StringVarP(&owner, "owner", "", "", "user or team charged for invocations of the function")
+	createCmd.Flags().StringSliceVarP(&secretRefs, "secret", "", nil, "secret exposed as environment variable: <secret>[:<variable>]")
+	createCmd.Flags().StringArrayVarP(&envVars, "env", "e", nil, "environment variable for the function: <name>=<value>")
+	createCmd.Flags().StringVarP(&signKeyFile, "sign_key", "", "", "file containing the private key used to sign the function code (see keygen)")
+	createCmd.Flags().StringSliceVarP(&isolationOpts, "isolation", "", nil, "relaxed or additional isolation settings: writable-rootfs, image-user, cap=<capability>, pids=<limit>, unconfined, gvisor, network=<none|internal|full>")
+
+	rootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().StringVarP(&funcName, "function", "f", "", "name of the function ([<namespace>/]<name>)")
+	updateCmd.Flags().StringVarP(&runtime, "runtime", "", "python38", "runtime for the function")
+	updateCmd.Flags().StringVarP(&handler, "handler", "", "", "function handler (runtime specific)")
+	updateCmd.Flags().Int64VarP(&memory, "memory", "", 128, "memory (in MB) for the function")
+	updateCmd.Flags().Float64VarP(&cpuDemand, "cpu", "", 0.0, "estimated CPU demand for the function (1.0 = 1 core)")
+	updateCmd.Flags().StringVarP(&src, "src", "", "", "source for the function (single file, directory or TAR archive)")
+	updateCmd.Flags().StringVarP(&customImage, "custom_image", "", "", "custom container image (only if runtime == 'custom')")
+	updateCmd.Flags().IntVarP(&maxConcurrency, "max_concurrency", "", 1, "max. number of concurrent invocations served by a single container")
+	updateCmd.Flags().StringVarP(&owner, "owner", "", "", "user or team charged for invocations of the function")
+	updateCmd.Flags().StringSliceVarP(&secretRefs, "secret", "", nil, "secret exposed as environment variable: <secret>[:<variable>] (replaces all the secrets)")
+	updateCmd.Flags().StringArrayVarP(&envVars, "env", "e", nil, "environment variable for the function: <name>=<value> (replaces all the variables)")
+	updateCmd.Flags().StringVarP(&signKeyFile, "sign_key", "", "", "file containing the private key used to sign the function code (see keygen)")
+	updateCmd.Flags().StringSliceVarP(&isolationOpts, "isolation", "", nil, "relaxed or additional isolation settings: writable-rootfs, image-user, cap=<capability>, pids=<limit>, unconfined, gvisor, network=<none|internal|full> (replaces all the settings)")
 
 	rootCmd.AddCommand(deleteCmd)
-	deleteCmd.Flags().StringVarP(&funcName, "function", "f", "", "name of the function")
+	deleteCmd.Flags().StringVarP(&funcName, "function", "f", "", "name of the function ([<namespace>/]<name>)")
 
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only list functions in this namespace")
 
 	rootCmd.AddCommand(statusCmd)
 
+	rootCmd.AddCommand(usageCmd)
+	usageCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only consider functions in this namespace")
+	usageCmd.Flags().StringVarP(&owner, "owner", "", "", "only consider functions of this owner")
+	usageCmd.Flags().StringVarP(&funcName, "function", "f", "", "only consider this function")
+	usageCmd.Flags().StringVarP(&usageSince, "since", "", "", "only consider invocations completed since this time (RFC3339)")
+	usageCmd.Flags().StringVarP(&usageUntil, "until", "", "", "only consider invocations completed before this time (RFC3339)")
+	usageCmd.Flags().StringVarP(&usageGroupBy, "groupby", "", "owner", "group usage by: namespace, owner, function, node")
+
+	initBenchCmd()
+	initKeyCmd()
+	initQuotaCmd()
+	initSecretCmd()
+	initCodesignCmd()
+
 	rootCmd.AddCommand(pollCmd)
 	pollCmd.Flags().StringVarP(&requestId, "request", "", "", "ID of the async request")
 
@@ -151,6 +216,15 @@ func invoke(cmd *cobra.Command, args []string) {
 		QoSMaxRespT:     qosMaxRespT,
 		CanDoOffloading: true,
 		Async:           asyncInvocation}
+	if len(payloadFile) > 0 {
+		payload, err := ioutil.ReadFile(payloadFile)
+		if err != nil {
+			fmt.Printf("Could not read payload from '%s': %v\n", payloadFile, err)
+			os.Exit(1)
+		}
+		request.Payload = payload
+		request.ContentType = contentType
+	}
 	invocationBody, err := json.Marshal(request)
 	if err != nil {
 		cmd.Help()
@@ -158,15 +232,40 @@ func invoke(cmd *cobra.Command, args []string) {
 	}
 
 	// Send invocation request
-	url := fmt.Sprintf("http://%s:%d/invoke/%s", ServerConfig.Host, ServerConfig.Port, funcName)
-	resp, err := utils.PostJson(url, invocationBody)
+	url := serverUrl("/invoke/%s", funcName)
+	resp, err := postJson(url, invocationBody)
 	if err != nil {
 		fmt.Printf("Invocation failed: %v", err)
 		os.Exit(2)
 	}
+	if len(outputFile) > 0 && !asyncInvocation {
+		writeResultToFile(resp.Body, outputFile)
+		return
+	}
 	utils.PrintJsonResponse(resp.Body)
 }
 
+// writeResultToFile stores the result contained in an invocation response to
+// a file, decoding binary results.
+func writeResultToFile(body io.ReadCloser, fileName string) {
+	defer body.Close()
+
+	var response function.Response
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		fmt.Printf("Could not parse response: %v\n", err)
+		os.Exit(2)
+	}
+
+	result := []byte(response.Result)
+	if response.ContentType != "" {
+		result = response.RawResult
+	}
+	if err := ioutil.WriteFile(fileName, result, 0644); err != nil {
+		fmt.Printf("Could not write result to '%s': %v\n", fileName, err)
+		os.Exit(2)
+	}
+}
+
 func create(cmd *cobra.Command, args []string) {
 	if funcName == "" || runtime == "" {
 		cmd.Help()
@@ -181,7 +280,7 @@ func create(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	var encoded string
+	var encoded, digest, signature string
 	if runtime != "custom" {
 		srcContent, err := readSourcesAsTar(src)
 		if err != nil {
@@ -189,15 +288,43 @@ func create(cmd *cobra.Command, args []string) {
 			os.Exit(3)
 		}
 		encoded = base64.StdEncoding.EncodeToString(srcContent)
+		digest, signature = signCode(srcContent)
 	} else {
 		encoded = ""
 	}
 
+	secrets, err := parseSecretRefs(secretRefs)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	env, err := parseEnv(envVars)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	var isolation *function.Isolation
+	if len(isolationOpts) > 0 {
+		if isolation, err = parseIsolation(isolationOpts); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	request := function.Function{Name: funcName, Handler: handler,
 		Runtime: runtime, MemoryMB: memory,
-		CPUDemand:       cpuDemand,
-		TarFunctionCode: encoded,
-		CustomImage:     customImage,
+		CPUDemand:                 cpuDemand,
+		TarFunctionCode:           encoded,
+		CustomImage:               customImage,
+		MaxConcurrencyPerInstance: maxConcurrency,
+		Owner:                     owner,
+		Secrets:                   secrets,
+		Env:                       env,
+		Isolation:                 isolation,
+		CodeDigest:                digest,
+		CodeSignature:             signature,
 	}
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -205,8 +332,8 @@ func create(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	url := fmt.Sprintf("http://%s:%d/create", ServerConfig.Host, ServerConfig.Port)
-	resp, err := utils.PostJson(url, requestBody)
+	url := serverUrl("/create")
+	resp, err := postJson(url, requestBody)
 	if err != nil {
 		// TODO: check returned error code
 		fmt.Printf("Creation request failed: %v\n", err)
@@ -215,6 +342,130 @@ func create(cmd *cobra.Command, args []string) {
 	utils.PrintJsonResponse(resp.Body)
 }
 
+func update(cmd *cobra.Command, args []string) {
+	if funcName == "" {
+		cmd.Help()
+		os.Exit(1)
+	}
+
+	// only the options given on the command line are sent
+	request := function.Function{Name: funcName}
+	flags := cmd.Flags()
+	if flags.Changed("runtime") {
+		request.Runtime = runtime
+	}
+	if flags.Changed("handler") {
+		request.Handler = handler
+	}
+	if flags.Changed("memory") {
+		request.MemoryMB = memory
+	}
+	if flags.Changed("cpu") {
+		request.CPUDemand = cpuDemand
+	}
+	if flags.Changed("src") {
+		srcContent, err := readSourcesAsTar(src)
+		if err != nil {
+			fmt.Printf("%v", err)
+			os.Exit(3)
+		}
+		request.TarFunctionCode = base64.StdEncoding.EncodeToString(srcContent)
+		request.CodeDigest, request.CodeSignature = signCode(srcContent)
+	}
+	if flags.Changed("custom_image") {
+		request.CustomImage = customImage
+	}
+	if flags.Changed("max_concurrency") {
+		request.MaxConcurrencyPerInstance = maxConcurrency
+	}
+	if flags.Changed("owner") {
+		request.Owner = owner
+	}
+	var err error
+	if flags.Changed("secret") {
+		if request.Secrets, err = parseSecretRefs(secretRefs); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	}
+	if flags.Changed("env") {
+		if request.Env, err = parseEnv(envVars); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	}
+	if flags.Changed("isolation") {
+		if request.Isolation, err = parseIsolation(isolationOpts); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		cmd.Help()
+		os.Exit(1)
+	}
+
+	resp, err := postJson(serverUrl("/update"), requestBody)
+	if err != nil {
+		fmt.Printf("Update request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
+
+// parseEnv parses environment variables specified as <name>=<value>.
+func parseEnv(specs []string) (map[string]string, error) {
+	env := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid environment variable '%s': expected <name>=<value>", spec)
+		}
+		if err := function.ValidateEnvName(name); err != nil {
+			return nil, err
+		}
+		env[name] = value
+	}
+	return env, nil
+}
+
+// parseIsolation parses the isolation settings of a function (e.g.,
+// "image-user", "cap=NET_RAW", "network=internal").
+func parseIsolation(opts []string) (*function.Isolation, error) {
+	isolation := &function.Isolation{}
+	for _, opt := range opts {
+		name, value, _ := strings.Cut(opt, "=")
+		switch name {
+		case "writable-rootfs":
+			isolation.WritableRootfs = true
+		case "image-user":
+			isolation.ImageUser = true
+		case "cap":
+			isolation.Capabilities = append(isolation.Capabilities, strings.ToUpper(value))
+		case "pids":
+			limit, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pids limit '%s'", value)
+			}
+			isolation.PidsLimit = limit
+		case "unconfined":
+			isolation.Unconfined = true
+		case "gvisor":
+			isolation.GVisor = true
+		case "network":
+			isolation.Network = value
+		default:
+			return nil, fmt.Errorf("unknown isolation setting '%s'", opt)
+		}
+	}
+	if err := isolation.Validate(); err != nil {
+		return nil, err
+	}
+	return isolation, nil
+}
+
 func readSourcesAsTar(srcPath string) ([]byte, error) {
 	fileInfo, err := os.Stat(srcPath)
 	if err != nil {
@@ -248,8 +499,8 @@ func deleteFunction(cmd *cobra.Command, args []string) {
 		os.Exit(2)
 	}
 
-	url := fmt.Sprintf("http://%s:%d/delete", ServerConfig.Host, ServerConfig.Port)
-	resp, err := utils.PostJson(url, requestBody)
+	url := serverUrl("/delete")
+	resp, err := postJson(url, requestBody)
 	if err != nil {
 		fmt.Printf("Deletion request failed: %v\n", err)
 		os.Exit(2)
@@ -258,8 +509,13 @@ func deleteFunction(cmd *cobra.Command, args []string) {
 }
 
 func listFunctions(cmd *cobra.Command, args []string) {
-	url := fmt.Sprintf("http://%s:%d/function", ServerConfig.Host, ServerConfig.Port)
-	resp, err := http.Get(url)
+	query := url.Values{}
+	if namespace != "" {
+		query.Set("namespace", namespace)
+	}
+
+	listUrl := serverUrl("/function?%s", query.Encode())
+	resp, err := get(listUrl)
 	if err != nil {
 		fmt.Printf("List request failed: %v\n", err)
 		os.Exit(2)
@@ -268,8 +524,8 @@ func listFunctions(cmd *cobra.Command, args []string) {
 }
 
 func getStatus(cmd *cobra.Command, args []string) {
-	url := fmt.Sprintf("http://%s:%d/status", ServerConfig.Host, ServerConfig.Port)
-	resp, err := http.Get(url)
+	url := serverUrl("/status")
+	resp, err := get(url)
 	if err != nil {
 		fmt.Printf("Invocation failed: %v", err)
 		os.Exit(2)
@@ -277,17 +533,103 @@ func getStatus(cmd *cobra.Command, args []string) {
 	utils.PrintJsonResponse(resp.Body)
 }
 
+func getUsage(cmd *cobra.Command, args []string) {
+	query := url.Values{}
+	query.Set("groupby", usageGroupBy)
+	for key, value := range map[string]string{"namespace": namespace, "owner": owner, "function": funcName, "since": usageSince, "until": usageUntil} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	usageUrl := serverUrl("/usage?%s", query.Encode())
+	resp, err := get(usageUrl)
+	if err != nil {
+		fmt.Printf("Usage request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
+
 func poll(cmd *cobra.Command, args []string) {
 	if len(requestId) < 1 {
 		cmd.Help()
 		os.Exit(1)
 	}
 
-	url := fmt.Sprintf("http://%s:%d/poll/%s", ServerConfig.Host, ServerConfig.Port, requestId)
-	resp, err := http.Get(url)
+	url := serverUrl("/poll/%s", requestId)
+	resp, err := get(url)
 	if err != nil {
 		fmt.Printf("Polling request failed: %v\n", err)
 		os.Exit(2)
 	}
 	utils.PrintJsonResponse(resp.Body)
 }
+
+// setCredentials adds the configured credentials (if any) to a request.
+func setCredentials(req *http.Request) {
+	if ServerConfig.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+ServerConfig.Token)
+	}
+}
+
+// serverUrl returns the URL of an API endpoint of the remote server.
+func serverUrl(path string, args ...interface{}) string {
+	scheme := "http"
+	if ServerConfig.TLS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, ServerConfig.Host, ServerConfig.Port) + fmt.Sprintf(path, args...)
+}
+
+// tlsConfig returns the TLS configuration used to connect to the server,
+// or nil if TLS is disabled.
+func tlsConfig() *tls.Config {
+	if !ServerConfig.TLS {
+		return nil
+	}
+	conf, err := utils.NewClientTLSConfig("", "", ServerConfig.CA)
+	if err != nil {
+		fmt.Printf("Invalid TLS configuration: %v\n", err)
+		os.Exit(1)
+	}
+	return conf
+}
+
+var httpClient *http.Client
+
+func getHttpClient() *http.Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig()}}
+	}
+	return httpClient
+}
+
+func doRequest(method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	setCredentials(req)
+
+	resp, err := getHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("Server response: %v", resp.Status)
+	}
+	return resp, nil
+}
+
+func postJson(url string, body []byte) (*http.Response, error) {
+	return doRequest(http.MethodPost, url, body)
+}
+
+func get(url string) (*http.Response, error) {
+	return doRequest(http.MethodGet, url, nil)
+}
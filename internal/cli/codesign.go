@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/grussorusso/serverledge/internal/codesign"
+	"github.com/spf13/cobra"
+)
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generates a key pair to sign function packages",
+	Run:   keygen,
+}
+
+var signKeyFile, keygenOutput string
+
+func initCodesignCmd() {
+	rootCmd.AddCommand(keygenCmd)
+	keygenCmd.Flags().StringVarP(&keygenOutput, "out", "o", "serverledge-signing.key", "file where the private key is written (the public key is written to <file>.pub)")
+}
+
+func keygen(cmd *cobra.Command, args []string) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Printf("Could not generate the key pair: %v\n", err)
+		os.Exit(2)
+	}
+
+	encodedPub := base64.StdEncoding.EncodeToString(pub)
+	if err := writeNewFile(keygenOutput, base64.StdEncoding.EncodeToString(priv), 0600); err != nil {
+		fmt.Printf("Could not write the private key: %v\n", err)
+		os.Exit(2)
+	}
+	if err := writeNewFile(keygenOutput+".pub", encodedPub, 0644); err != nil {
+		fmt.Printf("Could not write the public key: %v\n", err)
+		os.Exit(2)
+	}
+	fmt.Printf("Public key: %s\n", encodedPub)
+}
+
+// writeNewFile writes a file, failing if it already exists.
+func writeNewFile(name string, content string, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// signCode returns the digest of a function package and, if a signing key
+// has been given, its signature.
+func signCode(code []byte) (digest string, signature string) {
+	digest = codesign.Digest(code)
+	if signKeyFile == "" {
+		return digest, ""
+	}
+
+	content, err := os.ReadFile(signKeyFile)
+	if err != nil {
+		fmt.Printf("Could not read the signing key: %v\n", err)
+		os.Exit(1)
+	}
+	key, err := codesign.ParsePrivateKey(strings.TrimSpace(string(content)))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	return digest, codesign.Sign(code, key)
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/api"
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/grussorusso/serverledge/utils"
+	"github.com/spf13/cobra"
+)
+
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manages API keys (admin only)",
+}
+
+var keyIssueCmd = &cobra.Command{
+	Use:   "issue",
+	Short: "Issues a new API key",
+	Run:   issueKey,
+}
+
+var keyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the issued API keys",
+	Run:   listKeys,
+}
+
+var keyRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revokes an API key",
+	Run:   revokeKey,
+}
+
+var keyName, keyId string
+var keyRoles []string
+var keyAdmin bool
+var keyTTL time.Duration
+
+func initKeyCmd() {
+	rootCmd.AddCommand(keyCmd)
+
+	keyCmd.AddCommand(keyIssueCmd)
+	keyIssueCmd.Flags().StringVarP(&keyName, "name", "", "", "name of the principal owning the key")
+	keyIssueCmd.Flags().BoolVarP(&keyAdmin, "admin", "", false, "grant administrative privileges in every namespace")
+	keyIssueCmd.Flags().StringSliceVarP(&keyRoles, "role", "r", nil, "role in a namespace: <namespace>:<role> (roles: admin, developer, invoker)")
+	keyIssueCmd.Flags().DurationVarP(&keyTTL, "ttl", "", 0, "validity of the key (no expiration if zero)")
+
+	keyCmd.AddCommand(keyListCmd)
+
+	keyCmd.AddCommand(keyRevokeCmd)
+	keyRevokeCmd.Flags().StringVarP(&keyId, "id", "", "", "ID of the key")
+}
+
+func issueKey(cmd *cobra.Command, args []string) {
+	if len(keyName) < 1 {
+		fmt.Printf("Invalid key name.\n")
+		cmd.Help()
+		os.Exit(1)
+	}
+
+	roles := make(map[string]auth.Role)
+	for _, binding := range keyRoles {
+		ns, role, err := auth.ParseRole(binding)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		roles[ns] = role
+	}
+
+	request := api.KeyRequest{Name: keyName, Admin: keyAdmin, Roles: roles, TTL: int64(keyTTL.Seconds())}
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		fmt.Printf("Error: %v", err)
+		os.Exit(2)
+	}
+
+	url := serverUrl("/auth/keys")
+	resp, err := postJson(url, requestBody)
+	if err != nil {
+		fmt.Printf("Key request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
+
+func listKeys(cmd *cobra.Command, args []string) {
+	url := serverUrl("/auth/keys")
+	resp, err := get(url)
+	if err != nil {
+		fmt.Printf("List request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
+
+func revokeKey(cmd *cobra.Command, args []string) {
+	if len(keyId) < 1 {
+		cmd.Help()
+		os.Exit(1)
+	}
+
+	url := serverUrl("/auth/keys/%s", keyId)
+	resp, err := doRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		fmt.Printf("Revocation failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/grussorusso/serverledge/internal/quota"
+	"github.com/grussorusso/serverledge/utils"
+	"github.com/spf13/cobra"
+)
+
+var quotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Manages the quotas of namespaces and functions",
+}
+
+var quotaGetCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Prints the quota of a namespace or a function",
+	Run:   getQuota,
+}
+
+var quotaSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Sets the quota of a namespace or a function (admin only)",
+	Run:   setQuota,
+}
+
+var quotaDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Removes the quota of a namespace or a function (admin only)",
+	Run:   deleteQuota,
+}
+
+var quotaSpec quota.Quota
+
+func initQuotaCmd() {
+	rootCmd.AddCommand(quotaCmd)
+
+	for _, cmd := range []*cobra.Command{quotaGetCmd, quotaSetCmd, quotaDeleteCmd} {
+		quotaCmd.AddCommand(cmd)
+		cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace")
+		cmd.Flags().StringVarP(&funcName, "function", "f", "", "function ([<namespace>/]<name>)")
+	}
+
+	quotaSetCmd.Flags().Float64VarP(&quotaSpec.CPUShare, "cpu_share", "", 0.0, "max. fraction of the node CPUs used by concurrent invocations")
+	quotaSetCmd.Flags().Float64VarP(&quotaSpec.MemoryShare, "memory_share", "", 0.0, "max. fraction of the node memory used by concurrent invocations")
+	quotaSetCmd.Flags().Float64VarP(&quotaSpec.RequestsPerSecond, "rate", "", 0.0, "max. requests per second (across all the nodes)")
+	quotaSetCmd.Flags().IntVarP(&quotaSpec.Burst, "burst", "", 0, "max. burst of requests")
+	quotaSetCmd.Flags().IntVarP(&quotaSpec.MaxFunctions, "functions", "", 0, "max. number of functions (only for namespaces)")
+}
+
+// quotaUrl returns the URL of the quota selected by the flags.
+func quotaUrl(cmd *cobra.Command) string {
+	var target string
+	if namespace != "" && funcName == "" {
+		target = quota.KIND_NAMESPACE + "/" + namespace
+	} else if funcName != "" && namespace == "" {
+		target = quota.KIND_FUNCTION + "/" + funcName
+	} else {
+		fmt.Println("Either --namespace or --function must be specified")
+		cmd.Help()
+		os.Exit(1)
+	}
+	return serverUrl("/quota/%s", target)
+}
+
+func getQuota(cmd *cobra.Command, args []string) {
+	resp, err := get(quotaUrl(cmd))
+	if err != nil {
+		fmt.Printf("Quota request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
+
+func setQuota(cmd *cobra.Command, args []string) {
+	url := quotaUrl(cmd)
+	requestBody, err := json.Marshal(quotaSpec)
+	if err != nil {
+		fmt.Printf("Error: %v", err)
+		os.Exit(2)
+	}
+
+	resp, err := doRequest(http.MethodPut, url, requestBody)
+	if err != nil {
+		fmt.Printf("Quota request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
+
+func deleteQuota(cmd *cobra.Command, args []string) {
+	resp, err := doRequest(http.MethodDelete, quotaUrl(cmd), nil)
+	if err != nil {
+		fmt.Printf("Quota request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
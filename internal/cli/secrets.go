@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/utils"
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manages the secrets available to functions",
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Creates or updates a secret",
+	Run:   setSecret,
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the secrets of a namespace (values are not shown)",
+	Run:   listSecrets,
+}
+
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Deletes a secret",
+	Run:   deleteSecret,
+}
+
+var secretName, secretValue, secretFile string
+var secretRefs []string
+
+func initSecretCmd() {
+	rootCmd.AddCommand(secretCmd)
+
+	secretCmd.AddCommand(secretSetCmd)
+	secretSetCmd.Flags().StringVarP(&secretName, "name", "", "", "name of the secret ([<namespace>/]<name>)")
+	secretSetCmd.Flags().StringVarP(&secretValue, "value", "", "", "value of the secret")
+	secretSetCmd.Flags().StringVarP(&secretFile, "file", "", "", "file containing the value of the secret")
+
+	secretCmd.AddCommand(secretListCmd)
+	secretListCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace (default: the default namespace)")
+
+	secretCmd.AddCommand(secretDeleteCmd)
+	secretDeleteCmd.Flags().StringVarP(&secretName, "name", "", "", "name of the secret ([<namespace>/]<name>)")
+}
+
+// parseSecretRefs parses the secrets referenced by a function, specified
+// as <secret>[:<env var>].
+func parseSecretRefs(specs []string) ([]function.SecretRef, error) {
+	refs := make([]function.SecretRef, 0, len(specs))
+	for _, spec := range specs {
+		var ref function.SecretRef
+		ref.Name, ref.Env, _ = strings.Cut(spec, ":")
+		if err := ref.Validate(); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+func secretUrl(cmd *cobra.Command) string {
+	if function.ValidateName(secretName) != nil {
+		fmt.Printf("Invalid secret name.\n")
+		cmd.Help()
+		os.Exit(1)
+	}
+	return serverUrl("/secrets/%s", secretName)
+}
+
+func setSecret(cmd *cobra.Command, args []string) {
+	url := secretUrl(cmd)
+
+	value := secretValue
+	if secretFile != "" {
+		content, err := os.ReadFile(secretFile)
+		if err != nil {
+			fmt.Printf("Could not read the secret from '%s': %v\n", secretFile, err)
+			os.Exit(1)
+		}
+		value = string(content)
+	} else if value == "" {
+		fmt.Println("Either --value or --file must be specified")
+		cmd.Help()
+		os.Exit(1)
+	}
+
+	requestBody, err := json.Marshal(struct{ Value string }{value})
+	if err != nil {
+		fmt.Printf("Error: %v", err)
+		os.Exit(2)
+	}
+	resp, err := doRequest(http.MethodPut, url, requestBody)
+	if err != nil {
+		fmt.Printf("Secret request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
+
+func listSecrets(cmd *cobra.Command, args []string) {
+	query := url.Values{}
+	if namespace != "" {
+		query.Set("namespace", namespace)
+	}
+	resp, err := get(serverUrl("/secrets?%s", query.Encode()))
+	if err != nil {
+		fmt.Printf("Secret request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
+
+func deleteSecret(cmd *cobra.Command, args []string) {
+	resp, err := doRequest(http.MethodDelete, secretUrl(cmd), nil)
+	if err != nil {
+		fmt.Printf("Secret request failed: %v\n", err)
+		os.Exit(2)
+	}
+	utils.PrintJsonResponse(resp.Body)
+}
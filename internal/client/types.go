@@ -6,4 +6,7 @@ type InvocationRequest struct {
 	QoSMaxRespT     float64
 	CanDoOffloading bool
 	Async           bool
+	Payload         []byte // raw input (base64-encoded when marshaled to JSON)
+	ContentType     string // content type of Payload
+	Offloaded       bool   `json:",omitempty"` // set by nodes offloading the request
 }
@@ -0,0 +1,154 @@
+package codesign
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/function"
+)
+
+// Function packages (i.e., the tar archives in TarFunctionCode) carry their
+// SHA-256 digest and, optionally, an ed25519 signature of the package. The
+// digest is checked when functions are created and before their code is
+// copied into new containers; signatures are checked against the keys
+// trusted by the node.
+
+var InvalidCodeErr = errors.New("function code verification failed")
+
+const digestPrefix = "sha256:"
+
+// Strict refuses functions whose packages are not signed with a trusted key.
+var Strict bool
+
+var trustedKeys []ed25519.PublicKey
+
+// Init loads the trusted keys from the configuration.
+func Init() error {
+	Strict = config.GetBool(config.CODE_SIGNING_STRICT, false)
+	trustedKeys = nil
+	if keysFile := config.GetString(config.CODE_SIGNING_KEYS, ""); keysFile != "" {
+		keys, err := readPublicKeys(keysFile)
+		if err != nil {
+			return fmt.Errorf("could not read trusted keys: %v", err)
+		}
+		trustedKeys = keys
+	}
+	if Strict && len(trustedKeys) == 0 {
+		return fmt.Errorf("strict code verification requires trusted keys (%s)", config.CODE_SIGNING_KEYS)
+	}
+	slog.Info("Code verification configured", "strict", Strict, "keys", len(trustedKeys))
+	return nil
+}
+
+// readPublicKeys reads base64-encoded ed25519 public keys, one per line.
+// Empty lines and lines starting with '#' are ignored.
+func readPublicKeys(file string) ([]ed25519.PublicKey, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []ed25519.PublicKey
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := ParsePublicKey(line)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
+
+// ParsePublicKey decodes a base64-encoded ed25519 public key.
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key '%s'", encoded)
+	}
+	return key, nil
+}
+
+// ParsePrivateKey decodes a base64-encoded ed25519 private key (or seed).
+func ParsePrivateKey(encoded string) (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("invalid private key: unexpected length %d", len(key))
+	}
+}
+
+// Digest returns the digest of a function package, e.g., "sha256:8f43...".
+func Digest(code []byte) string {
+	sum := sha256.Sum256(code)
+	return digestPrefix + hex.EncodeToString(sum[:])
+}
+
+// Sign returns the base64-encoded signature of a function package.
+func Sign(code []byte, key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, code))
+}
+
+// Verify checks the digest and the signature of the package of a function.
+// Functions without a package (e.g., with custom images) are not checked.
+// Unless Strict is set, functions without a digest or a signature are
+// accepted, but signatures are always checked whenever trusted keys are
+// configured.
+func Verify(f *function.Function) error {
+	if f.TarFunctionCode == "" {
+		return nil
+	}
+	code, err := base64.StdEncoding.DecodeString(f.TarFunctionCode)
+	if err != nil {
+		return fmt.Errorf("%w: bad encoding: %v", InvalidCodeErr, err)
+	}
+
+	if f.CodeDigest != "" {
+		if f.CodeDigest != Digest(code) {
+			return fmt.Errorf("%w: digest mismatch for '%s'", InvalidCodeErr, f.Name)
+		}
+	} else if Strict {
+		return fmt.Errorf("%w: missing digest for '%s'", InvalidCodeErr, f.Name)
+	}
+
+	if f.CodeSignature == "" {
+		if Strict {
+			return fmt.Errorf("%w: '%s' is not signed", InvalidCodeErr, f.Name)
+		}
+		return nil
+	}
+	if len(trustedKeys) == 0 {
+		// nothing to check against
+		return nil
+	}
+	signature, err := base64.StdEncoding.DecodeString(f.CodeSignature)
+	if err == nil {
+		for _, key := range trustedKeys {
+			if ed25519.Verify(key, code, signature) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: '%s' is not signed by a trusted key", InvalidCodeErr, f.Name)
+}
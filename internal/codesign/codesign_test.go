@@ -0,0 +1,67 @@
+package codesign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/grussorusso/serverledge/internal/function"
+)
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	trustedKeys = []ed25519.PublicKey{pub}
+	defer func() { trustedKeys = nil; Strict = false }()
+
+	code := []byte("function package")
+	signed := &function.Function{
+		Name:            "f",
+		TarFunctionCode: base64.StdEncoding.EncodeToString(code),
+		CodeDigest:      Digest(code),
+		CodeSignature:   Sign(code, priv),
+	}
+	unsigned := &function.Function{Name: "f", TarFunctionCode: signed.TarFunctionCode}
+	untrusted := *signed
+	untrusted.CodeSignature = Sign(code, otherKey)
+	tampered := *signed
+	tampered.TarFunctionCode = base64.StdEncoding.EncodeToString([]byte("another package"))
+
+	for _, Strict = range []bool{false, true} {
+		if err := Verify(signed); err != nil {
+			t.Errorf("signed package rejected (strict: %v): %v", Strict, err)
+		}
+		if err := Verify(&tampered); !errors.Is(err, InvalidCodeErr) {
+			t.Errorf("tampered package accepted (strict: %v): %v", Strict, err)
+		}
+		if err := Verify(&function.Function{Name: "custom", CustomImage: "image"}); err != nil {
+			t.Errorf("function without code rejected (strict: %v): %v", Strict, err)
+		}
+	}
+
+	Strict = false
+	if err := Verify(unsigned); err != nil {
+		t.Errorf("unsigned package rejected: %v", err)
+	}
+	if err := Verify(&untrusted); !errors.Is(err, InvalidCodeErr) {
+		t.Errorf("package with untrusted signature accepted: %v", err)
+	}
+	trustedKeys = nil
+	if err := Verify(&untrusted); err != nil {
+		t.Errorf("signed package rejected without trusted keys: %v", err)
+	}
+	trustedKeys = []ed25519.PublicKey{pub}
+
+	Strict = true
+	if err := Verify(unsigned); !errors.Is(err, InvalidCodeErr) {
+		t.Errorf("unsigned package accepted in strict mode: %v", err)
+	}
+	if err := Verify(&untrusted); !errors.Is(err, InvalidCodeErr) {
+		t.Errorf("package with untrusted signature accepted in strict mode: %v", err)
+	}
+}
@@ -18,6 +18,11 @@ func Get(key string, defaultValue interface{}) interface{} {
 	}
 }
 
+// Set overrides the configured value for a given key.
+func Set(key string, value interface{}) {
+	viper.Set(key, value)
+}
+
 func GetInt(key string, defaultValue int) int {
 	if viper.IsSet(key) {
 		return viper.GetInt(key)
@@ -6,6 +6,12 @@ const ETCD_ADDRESS = "etcd.address"
 //exposed port for serverledge APIs
 const API_PORT = "api.port"
 
+// enables the gRPC API server (true/false)
+const API_GRPC_ENABLED = "api.grpc.enabled"
+
+// exposed port for the gRPC API (assumed to be the same on every node)
+const API_GRPC_PORT = "api.grpc.port"
+
 //REMOTE SERVER URL
 const CLOUD_URL = "cloud.server.url"
 
@@ -13,6 +19,40 @@ const CLOUD_URL = "cloud.server.url"
 // even if they are locally available (true/false).
 const FACTORY_REFRESH_IMAGES = "factory.images.refresh"
 
+// Container backend used to run functions
+// Possible values: "docker", "containerd", "process"
+const CONTAINER_FACTORY = "container.factory"
+
+// containerd socket address
+const CONTAINERD_ADDRESS = "container.containerd.address"
+
+// containerd namespace where containers are created
+const CONTAINERD_NAMESPACE = "container.containerd.namespace"
+
+// directory containing CNI plugin binaries (containerd backend)
+const CNI_BIN_DIR = "container.cni.bindir"
+
+// directory containing CNI network configurations (containerd backend)
+const CNI_CONF_DIR = "container.cni.confdir"
+
+// directory where function instances are created (process backend)
+const PROCESS_ROOT_DIR = "container.process.root"
+
+// directory containing the Executors of the supported runtimes (process backend)
+const PROCESS_EXECUTORS_DIR = "container.process.executors"
+
+// cgroup (v2) under which function instances are created (process backend)
+const PROCESS_CGROUP = "container.process.cgroup"
+
+// max. execution time (in seconds) of WebAssembly functions
+const WASM_TIMEOUT = "container.wasm.timeout"
+
+// user (uid:gid) running the Executors when Serverledge runs as root (process backend)
+const PROCESS_USER = "container.process.user"
+
+// comma-separated host directories made available (read-only) to the Executors, besides /usr, /lib, etc. (process backend)
+const PROCESS_MOUNTS = "container.process.mounts"
+
 // Amount of memory available for the container pool (in MB)
 const POOL_MEMORY_MB = "container.pool.memory"
 
@@ -52,6 +92,15 @@ const REGISTRATION_TTL = "registry.ttl"
 // port for udp status listener
 const LISTEN_UDP_PORT = "registry.udp.port"
 
+// secret shared by the nodes to authenticate status messages exchanged over UDP
+const REGISTRY_UDP_SECRET = "registry.udp.secret"
+
+// max. clock skew (in seconds) accepted in status messages exchanged over UDP
+const REGISTRY_UDP_MAX_SKEW = "registry.udp.maxskew"
+
+// max. status requests per second answered for each address (0 = no limit)
+const REGISTRY_UDP_RATE_LIMIT = "registry.udp.ratelimit"
+
 // enable metrics system
 const METRICS_ENABLED = "metrics.enabled"
 
@@ -64,3 +113,151 @@ const SCHEDULING_POLICY = "scheduler.policy"
 
 // Capacity of the queue (possibly) used by the scheduler
 const SCHEDULER_QUEUE_CAPACITY = "scheduler.queue.capacity"
+
+// Transport used to offload requests to other nodes
+// Possible values: "http", "grpc"
+const SCHEDULER_OFFLOADING_TRANSPORT = "scheduler.offloading.transport"
+
+// enables checkpoint/restore of function containers to speed up cold starts (true/false)
+const CONTAINER_SNAPSHOTS_ENABLED = "container.snapshots.enabled"
+
+// directory where container snapshots are stored
+const CONTAINER_SNAPSHOTS_DIR = "container.snapshots.dir"
+
+// enables distributed tracing with OpenTelemetry (true/false)
+const TRACING_ENABLED = "tracing.enabled"
+
+// exporter for tracing spans
+// Possible values: "otlp", "stdout", "file"
+const TRACING_EXPORTER = "tracing.exporter"
+
+// URL of the OTLP/HTTP collector endpoint (e.g., http://localhost:4318)
+const TRACING_OTLP_ENDPOINT = "tracing.otlp.endpoint"
+
+// file where spans are written by the "file" exporter
+const TRACING_FILE = "tracing.file"
+
+// fraction of requests traced (for requests without a sampled parent span)
+const TRACING_SAMPLING_RATIO = "tracing.sampling"
+
+// minimum level of logged messages
+// Possible values: "debug", "info", "warn", "error"
+const LOGGING_LEVEL = "logging.level"
+
+// format of log messages
+// Possible values: "text", "json"
+const LOGGING_FORMAT = "logging.format"
+
+// enables billing records for completed invocations (true/false)
+const BILLING_ENABLED = "billing.enabled"
+
+// where billing records are written
+// Possible values: "file", "etcd"
+const BILLING_SINK = "billing.sink"
+
+// file where billing records are appended by the "file" sink
+const BILLING_FILE = "billing.file"
+
+// retention time (in seconds) of billing records in Etcd (0 = forever)
+const BILLING_RETENTION = "billing.retention"
+
+// requires clients to authenticate with API keys or JWT tokens (true/false)
+const AUTH_ENABLED = "auth.enabled"
+
+// secret used to verify JWT bearer tokens (HS256); JWTs are rejected if empty
+const AUTH_JWT_SECRET = "auth.jwt.secret"
+
+// static token granting admin privileges (e.g., to issue the first API keys)
+const AUTH_ADMIN_TOKEN = "auth.admin.token"
+
+// token shared by the nodes to authenticate offloaded requests
+const AUTH_NODE_TOKEN = "auth.node.token"
+
+// token used by the CLI to authenticate with the API
+const CLI_TOKEN = "cli.token"
+
+// enforces quotas on the resources and request rates of namespaces and functions (true/false)
+const QUOTA_ENABLED = "quota.enabled"
+
+// default max. fraction of the node CPUs used by the invocations of a namespace
+const QUOTA_DEFAULT_CPU_SHARE = "quota.default.cpushare"
+
+// default max. fraction of the node memory used by the invocations of a namespace
+const QUOTA_DEFAULT_MEMORY_SHARE = "quota.default.memoryshare"
+
+// default max. requests per second for a namespace (across all the nodes)
+const QUOTA_DEFAULT_RATE = "quota.default.rate"
+
+// default burst size of the request rate limit of a namespace
+const QUOTA_DEFAULT_BURST = "quota.default.burst"
+
+// default max. number of functions in a namespace
+const QUOTA_DEFAULT_FUNCTIONS = "quota.default.functions"
+
+// interval (in seconds) between synchronizations of rate limits across nodes
+const QUOTA_SYNC_INTERVAL = "quota.sync.interval"
+
+// serves the REST and gRPC APIs over TLS, and connects to other nodes over TLS (true/false)
+const TLS_ENABLED = "tls.enabled"
+
+// certificate (PEM) of the node, used as both server and client certificate
+const TLS_CERT = "tls.cert"
+
+// private key (PEM) of the node certificate
+const TLS_KEY = "tls.key"
+
+// CA certificates (PEM) used to verify other nodes
+const TLS_CA = "tls.ca"
+
+// verification of client certificates issued by the CA
+// Possible values: "none", "optional", "required"
+const TLS_CLIENT_AUTH = "tls.clientauth"
+
+// client certificate (PEM) used to connect to Etcd
+const ETCD_TLS_CERT = "etcd.tls.cert"
+
+// private key (PEM) of the Etcd client certificate
+const ETCD_TLS_KEY = "etcd.tls.key"
+
+// CA certificates (PEM) used to verify Etcd; TLS is used to connect to Etcd if set
+const ETCD_TLS_CA = "etcd.tls.ca"
+
+// connects to the API over TLS (true/false)
+const CLI_TLS_ENABLED = "cli.tls.enabled"
+
+// CA certificates (PEM) used by the CLI to verify the API server
+const CLI_TLS_CA = "cli.tls.ca"
+
+// base64-encoded 256-bit key used to encrypt secrets (must be the same on every node)
+const SECRETS_KEY = "secrets.key"
+
+// file containing the base64-encoded key used to encrypt secrets (overrides secrets.key)
+const SECRETS_KEY_FILE = "secrets.keyfile"
+
+// hardens function containers with a read-only rootfs, no capabilities, a non-root user, etc. (true/false)
+const CONTAINER_HARDENING_ENABLED = "container.hardening.enabled"
+
+// user (uid:gid) running the function containers, unless they run as the image user
+const CONTAINER_HARDENING_USER = "container.hardening.user"
+
+// max. number of processes in a function container (0 = unlimited)
+const CONTAINER_HARDENING_PIDS = "container.hardening.pids"
+
+// size (in MB) of the tmpfs mounted on /tmp in containers with a read-only rootfs
+const CONTAINER_HARDENING_TMPFS = "container.hardening.tmpfs"
+
+// file containing the seccomp profile of function containers (default: the profile of the container runtime)
+const CONTAINER_HARDENING_SECCOMP = "container.hardening.seccomp"
+
+// name of the gVisor runtime in Docker or containerd (default: "runsc" or "io.containerd.runsc.v1")
+const CONTAINER_GVISOR_RUNTIME = "container.gvisor.runtime"
+
+// network mode of function containers which do not specify one
+// Possible values: "none", "internal", "full"
+const CONTAINER_NETWORK_DEFAULT = "container.network.default"
+
+// file containing the base64-encoded ed25519 public keys trusted to sign function packages (one per line)
+const CODE_SIGNING_KEYS = "code.signing.keys"
+
+// refuses functions whose packages are not signed with a trusted key (true/false)
+const CODE_SIGNING_STRICT = "code.signing.strict"
@@ -1,6 +1,9 @@
 package config
 
 type RemoteServerConf struct {
-	Host string
-	Port int
+	Host  string
+	Port  int
+	Token string // credentials for the API (optional)
+	TLS   bool   // connects to the API over TLS
+	CA    string // CA certificates (PEM) used to verify the server (optional)
 }
@@ -0,0 +1,49 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupV2Dir returns the directory of the cgroup v2 of a process.
+func cgroupV2Dir(pid int) (string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	return parseCgroupV2Path(string(data))
+}
+
+// parseCgroupV2Path returns the directory of the cgroup v2 listed in the
+// content of /proc/<pid>/cgroup.
+func parseCgroupV2Path(content string) (string, error) {
+	for _, line := range strings.Split(content, "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return filepath.Join("/sys/fs/cgroup", path), nil
+		}
+	}
+	return "", StatsNotAvailableErr
+}
+
+// readMemoryPeak reads the peak memory usage of a cgroup v2, which is only
+// available on Linux 5.19 or later.
+func readMemoryPeak(dir string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "memory.peak"))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// processMemoryPeak returns the peak memory usage of the cgroup v2 of a
+// process.
+func processMemoryPeak(pid int) (int64, error) {
+	dir, err := cgroupV2Dir(pid)
+	if err != nil {
+		return 0, err
+	}
+	return readMemoryPeak(dir)
+}
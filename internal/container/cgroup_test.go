@@ -0,0 +1,28 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryPeak(t *testing.T) {
+	dir, err := parseCgroupV2Path("1:name=systemd:/docker/abc\n0::/system.slice/docker-abc.scope\n")
+	if err != nil || dir != "/sys/fs/cgroup/system.slice/docker-abc.scope" {
+		t.Errorf("unexpected cgroup directory: %s (%v)", dir, err)
+	}
+	if _, err := parseCgroupV2Path("4:memory:/docker/abc\n"); err == nil {
+		t.Errorf("cgroup v2 directory found in cgroup v1 hierarchy")
+	}
+
+	dir = t.TempDir()
+	if _, err := readMemoryPeak(dir); !os.IsNotExist(err) {
+		t.Errorf("expected missing memory.peak, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory.peak"), []byte("1048576\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if peak, err := readMemoryPeak(dir); err != nil || peak != 1048576 {
+		t.Errorf("unexpected peak: %d (%v)", peak, err)
+	}
+}
@@ -5,50 +5,87 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/grussorusso/serverledge/internal/executor"
 )
 
+// executorClient is shared among invocations, so that connections to
+// Executors are kept alive and reused.
+var executorClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConns:        2500,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     5 * time.Minute,
+		DisableCompression:  true,
+	},
+}
+
+var executorAddrCache = make(map[ContainerID]string)
+var executorAddrMutex sync.RWMutex
+
 //NewContainer creates and starts a new container.
 func NewContainer(image, codeTar string, opts *ContainerOptions) (ContainerID, error) {
-	contID, err := cf.Create(image, opts)
+	contID, err := createWithCode(image, codeTar, opts)
 	if err != nil {
-		log.Printf("Failed container creation")
 		return "", err
 	}
 
-	if len(codeTar) > 0 {
-		decodedCode, _ := base64.StdEncoding.DecodeString(codeTar)
-		err = cf.CopyToContainer(contID, bytes.NewReader(decodedCode), "/app/")
-		if err != nil {
-			log.Printf("Failed code copy")
-			return "", err
-		}
+	err = getFactoryForImage(image).Start(contID)
+	if err != nil {
+		return "", err
 	}
 
-	err = cf.Start(contID)
+	return contID, nil
+}
+
+// createWithCode creates a new container and copies the function code into it.
+func createWithCode(image, codeTar string, opts *ContainerOptions) (ContainerID, error) {
+	f := getFactoryForImage(image)
+	contID, err := f.Create(image, opts)
 	if err != nil {
+		slog.Error("Failed container creation", "image", image, "err", err)
 		return "", err
 	}
 
+	if len(codeTar) > 0 {
+		decodedCode, err := base64.StdEncoding.DecodeString(codeTar)
+		if err != nil {
+			return "", fmt.Errorf("invalid function code: %v", err)
+		}
+		err = f.CopyToContainer(contID, bytes.NewReader(decodedCode), "/app/")
+		if err != nil {
+			slog.Error("Failed code copy", "container", contID, "err", err)
+			return "", err
+		}
+	}
+
 	return contID, nil
 }
 
 // Execute interacts with the Executor running in the container to invoke the
 // function through a HTTP request.
 func Execute(contID ContainerID, req *executor.InvocationRequest) (*executor.InvocationResult, time.Duration, error) {
-	ipAddr, err := cf.GetIPAddress(contID)
+	if invoker, ok := getFactory(contID).(Invoker); ok {
+		// the function runs within the node process
+		response, err := invoker.Invoke(contID, req)
+		return response, 0, err
+	}
+
+	executorAddr, err := getExecutorAddress(contID)
 	if err != nil {
 		return nil, 0, fmt.Errorf("Failed to retrieve IP address for container: %v", err)
 	}
 
 	postBody, _ := json.Marshal(req)
-	postBodyB := bytes.NewBuffer(postBody)
-	resp, waitDuration, err := sendPostRequestWithRetries(fmt.Sprintf("http://%s:%d/invoke", ipAddr,
-		executor.DEFAULT_EXECUTOR_PORT), postBodyB)
+	resp, waitDuration, err := sendPostRequestWithRetries(fmt.Sprintf("http://%s/invoke", executorAddr), postBody)
 	if err != nil || resp == nil {
 		return nil, waitDuration, fmt.Errorf("Request to executor failed: %v", err)
 	}
@@ -60,19 +97,59 @@ func Execute(contID ContainerID, req *executor.InvocationRequest) (*executor.Inv
 	if err != nil {
 		return nil, waitDuration, fmt.Errorf("Parsing executor response failed: %v", err)
 	}
+	// consume the rest of the body, so that the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
 
 	return response, waitDuration, nil
 }
 
+// getExecutorAddress returns the address (host:port) of the Executor running
+// in a container. Addresses are cached, to avoid querying the container
+// factory on every invocation.
+func getExecutorAddress(contID ContainerID) (string, error) {
+	executorAddrMutex.RLock()
+	addr, ok := executorAddrCache[contID]
+	executorAddrMutex.RUnlock()
+	if ok {
+		return addr, nil
+	}
+
+	ipAddr, err := cf.GetIPAddress(contID)
+	if err != nil {
+		return "", err
+	}
+	port := executor.DEFAULT_EXECUTOR_PORT
+	if pp, ok := cf.(ExecutorPortProvider); ok {
+		if port, err = pp.GetExecutorPort(contID); err != nil {
+			return "", err
+		}
+	}
+	addr = net.JoinHostPort(ipAddr, strconv.Itoa(port))
+
+	executorAddrMutex.Lock()
+	executorAddrCache[contID] = addr
+	executorAddrMutex.Unlock()
+
+	return addr, nil
+}
+
 func GetMemoryMB(id ContainerID) (int64, error) {
-	return cf.GetMemoryMB(id)
+	return getFactory(id).GetMemoryMB(id)
+}
+
+func GetStats(id ContainerID) (*Stats, error) {
+	return getFactory(id).GetStats(id)
 }
 
 func Destroy(id ContainerID) error {
-	return cf.Destroy(id)
+	executorAddrMutex.Lock()
+	delete(executorAddrCache, id)
+	executorAddrMutex.Unlock()
+
+	return getFactory(id).Destroy(id)
 }
 
-func sendPostRequestWithRetries(url string, body *bytes.Buffer) (*http.Response, time.Duration, error) {
+func sendPostRequestWithRetries(url string, body []byte) (*http.Response, time.Duration, error) {
 	const TIMEOUT_MILLIS = 30000
 	const MAX_BACKOFF_MILLIS = 500
 	var backoffMillis = 25
@@ -82,13 +159,13 @@ func sendPostRequestWithRetries(url string, body *bytes.Buffer) (*http.Response,
 	var err error
 
 	for totalWaitMillis < TIMEOUT_MILLIS {
-		resp, err := http.Post(url, "application/json", body)
+		resp, err := executorClient.Post(url, "application/json", bytes.NewReader(body))
 		if err == nil {
 			return resp, time.Duration(totalWaitMillis * int(time.Millisecond)), err
 		} else if attempts > 3 {
 			// It is common to have a failure after a cold start, so
 			// we avoid logging failures on the first attempt(s)
-			log.Printf("Invocation POST failed (attempt %d): %v", attempts, err)
+			slog.Warn("Invocation POST failed", "url", url, "attempt", attempts, "err", err)
 		}
 
 		time.Sleep(time.Duration(backoffMillis * int(time.Millisecond)))
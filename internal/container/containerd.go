@@ -0,0 +1,392 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	cgroupsv1 "github.com/containerd/cgroups/stats/v1"
+	cgroupsv2 "github.com/containerd/cgroups/v2/stats"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/archive"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/contrib/seccomp"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/oci"
+	"github.com/containerd/containerd/reference/docker"
+	gocni "github.com/containerd/go-cni"
+	"github.com/containerd/typeurl"
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/lithammer/shortuuid"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+type ContainerdFactory struct {
+	cli *containerd.Client
+	cni gocni.CNI
+	ctx context.Context
+
+	// IP addresses assigned by CNI to running containers
+	ipAddresses map[ContainerID]string
+	mutex       sync.RWMutex
+}
+
+func InitContainerdFactory() *ContainerdFactory {
+	address := config.GetString(config.CONTAINERD_ADDRESS, "/run/containerd/containerd.sock")
+	namespace := config.GetString(config.CONTAINERD_NAMESPACE, "serverledge")
+
+	cli, err := containerd.New(address)
+	if err != nil {
+		panic(err)
+	}
+
+	cni, err := gocni.New(
+		gocni.WithPluginDir([]string{config.GetString(config.CNI_BIN_DIR, "/opt/cni/bin")}),
+		gocni.WithPluginConfDir(config.GetString(config.CNI_CONF_DIR, "/etc/cni/net.d")),
+		gocni.WithMinNetworkCount(2))
+	if err != nil {
+		panic(err)
+	}
+	if err := cni.Load(gocni.WithLoNetwork, gocni.WithDefaultConf); err != nil {
+		panic(fmt.Errorf("could not load CNI configuration: %v", err))
+	}
+
+	ctx := namespaces.WithNamespace(context.Background(), namespace)
+	containerdFact := &ContainerdFactory{
+		cli:         cli,
+		cni:         cni,
+		ctx:         ctx,
+		ipAddresses: make(map[ContainerID]string),
+	}
+	cf = containerdFact
+	return containerdFact
+}
+
+// normalizeImageName converts Docker-style image names (e.g.,
+// "grussorusso/serverledge-python310") into fully qualified references.
+func normalizeImageName(image string) string {
+	named, err := docker.ParseDockerRef(image)
+	if err != nil {
+		return image
+	}
+	return named.String()
+}
+
+func (cf *ContainerdFactory) Create(image string, opts *ContainerOptions) (ContainerID, error) {
+	ref := normalizeImageName(image)
+	if !cf.HasImage(image) {
+		slog.Info("Pulling image", "image", ref)
+		_, err := cf.cli.Pull(cf.ctx, ref, containerd.WithPullUnpack)
+		if err != nil {
+			slog.Warn("Could not pull image", "image", ref, "err", err)
+			// we do not return here, as a stale copy of the image
+			// could still be available locally
+		} else {
+			slog.Info("Pulled image", "image", ref)
+			refreshedImages[image] = true
+		}
+	}
+
+	// networking is configured through CNI
+	if err := checkUnrestrictedNetwork(opts); err != nil {
+		return "", err
+	}
+
+	img, err := cf.cli.GetImage(cf.ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	specOpts := []oci.SpecOpts{
+		oci.WithImageConfig(img),
+		oci.WithMemoryLimit(uint64(opts.MemoryMB * 1048576)), // convert to bytes
+	}
+	if len(opts.Cmd) > 0 {
+		specOpts = append(specOpts, oci.WithProcessArgs(opts.Cmd...))
+	}
+	if len(opts.Env) > 0 {
+		specOpts = append(specOpts, oci.WithEnv(opts.Env))
+	}
+	if opts.CPUQuota > 0.0 {
+		specOpts = append(specOpts, oci.WithCPUCFS(int64(50000.0*opts.CPUQuota), 50000)) // 50ms period
+	}
+
+	if opts.Hardening != nil {
+		specOpts = append(specOpts, containerdHardening(opts.Hardening)...)
+	}
+
+	id := "serverledge-" + strings.ToLower(shortuuid.New())
+	contOpts := []containerd.NewContainerOpts{
+		containerd.WithImage(img),
+		containerd.WithNewSnapshot(id, img),
+		containerd.WithNewSpec(specOpts...),
+	}
+	if opts.Hardening != nil && opts.Hardening.GVisor {
+		contOpts = append(contOpts, containerd.WithRuntime(getGVisorRuntime("io.containerd.runsc.v1"), nil))
+	}
+	cont, err := cf.cli.NewContainer(cf.ctx, id, contOpts...)
+	if err != nil {
+		return "", err
+	}
+
+	slog.Debug("Created container", "container", cont.ID())
+	return cont.ID(), nil
+}
+
+// containerdHardening returns the spec options applying the security
+// settings of a new container. As the code of the function is copied into the
+// snapshot before starting the container, the rootfs can be read-only.
+func containerdHardening(h *Hardening) []oci.SpecOpts {
+	var specOpts []oci.SpecOpts
+	if h.ReadOnlyRootfs {
+		specOpts = append(specOpts, oci.WithRootFSReadonly(), oci.WithMounts([]specs.Mount{{
+			Destination: "/tmp",
+			Type:        "tmpfs",
+			Source:      "tmpfs",
+			Options:     []string{"nosuid", "nodev", "mode=1777", fmt.Sprintf("size=%dm", h.TmpfsMB)},
+		}}))
+	}
+	if h.User != "" {
+		specOpts = append(specOpts, oci.WithUser(h.User))
+	}
+	if h.DropCapabilities {
+		capabilities := make([]string, 0, len(h.Capabilities))
+		for _, c := range h.Capabilities {
+			capabilities = append(capabilities, capabilityName(c))
+		}
+		specOpts = append(specOpts, oci.WithCapabilities(capabilities))
+	}
+	if h.NoNewPrivileges {
+		specOpts = append(specOpts, oci.WithNoNewPrivileges)
+	}
+	if h.PidsLimit > 0 {
+		specOpts = append(specOpts, withPidsLimit(h.PidsLimit))
+	}
+	// the default profile depends on the capabilities, thus it must be
+	// set afterwards
+	switch h.SeccompProfile {
+	case SECCOMP_UNCONFINED:
+	case "":
+		specOpts = append(specOpts, seccomp.WithDefaultProfile())
+	default:
+		specOpts = append(specOpts, seccomp.WithProfile(h.SeccompProfile))
+	}
+	return specOpts
+}
+
+// withPidsLimit sets the max. number of processes in the container (like
+// oci.WithPidsLimit, which is only available on Linux).
+func withPidsLimit(limit int64) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+		if s.Linux == nil {
+			s.Linux = &specs.Linux{}
+		}
+		if s.Linux.Resources == nil {
+			s.Linux.Resources = &specs.LinuxResources{}
+		}
+		s.Linux.Resources.Pids = &specs.LinuxPids{Limit: limit}
+		return nil
+	}
+}
+
+// CopyToContainer extracts a tar archive into the container root filesystem.
+// It must be called before starting the container.
+func (cf *ContainerdFactory) CopyToContainer(contID ContainerID, content io.Reader, destPath string) error {
+	cont, err := cf.cli.LoadContainer(cf.ctx, contID)
+	if err != nil {
+		return err
+	}
+	info, err := cont.Info(cf.ctx)
+	if err != nil {
+		return err
+	}
+
+	mounts, err := cf.cli.SnapshotService(info.Snapshotter).Mounts(cf.ctx, info.SnapshotKey)
+	if err != nil {
+		return err
+	}
+
+	return mount.WithTempMount(cf.ctx, mounts, func(root string) error {
+		_, err := archive.Apply(cf.ctx, filepath.Join(root, destPath), content)
+		return err
+	})
+}
+
+func (cf *ContainerdFactory) Start(contID ContainerID) error {
+	cont, err := cf.cli.LoadContainer(cf.ctx, contID)
+	if err != nil {
+		return err
+	}
+
+	task, err := cont.NewTask(cf.ctx, cio.NullIO)
+	if err != nil {
+		return err
+	}
+
+	netns := fmt.Sprintf("/proc/%d/ns/net", task.Pid())
+	result, err := cf.cni.Setup(cf.ctx, contID, netns)
+	if err != nil {
+		task.Delete(cf.ctx, containerd.WithProcessKill)
+		return fmt.Errorf("network setup failed: %v", err)
+	}
+
+	ipAddr, err := getCNIAddress(result)
+	if err != nil {
+		cf.cni.Remove(cf.ctx, contID, netns)
+		task.Delete(cf.ctx, containerd.WithProcessKill)
+		return err
+	}
+	if err := task.Start(cf.ctx); err != nil {
+		cf.cni.Remove(cf.ctx, contID, netns)
+		task.Delete(cf.ctx, containerd.WithProcessKill)
+		return err
+	}
+
+	cf.mutex.Lock()
+	cf.ipAddresses[contID] = ipAddr
+	cf.mutex.Unlock()
+	return nil
+}
+
+func (cf *ContainerdFactory) Destroy(contID ContainerID) error {
+	cf.mutex.Lock()
+	delete(cf.ipAddresses, contID)
+	cf.mutex.Unlock()
+
+	cont, err := cf.cli.LoadContainer(cf.ctx, contID)
+	if err != nil {
+		return err
+	}
+
+	task, err := cont.Task(cf.ctx, nil)
+	if err == nil {
+		netns := fmt.Sprintf("/proc/%d/ns/net", task.Pid())
+		if err := cf.cni.Remove(cf.ctx, contID, netns); err != nil {
+			slog.Warn("Network teardown failed", "container", contID, "err", err)
+		}
+
+		exitCh, err := task.Wait(cf.ctx)
+		if err == nil && task.Kill(cf.ctx, syscall.SIGKILL) == nil {
+			<-exitCh
+		}
+		if _, err := task.Delete(cf.ctx, containerd.WithProcessKill); err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	return cont.Delete(cf.ctx, containerd.WithSnapshotCleanup)
+}
+
+func (cf *ContainerdFactory) HasImage(image string) bool {
+	_, err := cf.cli.GetImage(cf.ctx, normalizeImageName(image))
+	if err != nil {
+		return false
+	}
+
+	// We have the image, but we may need to refresh it
+	if config.GetBool(config.FACTORY_REFRESH_IMAGES, false) {
+		if refreshed, ok := refreshedImages[image]; !ok || !refreshed {
+			return false
+		}
+	}
+	return true
+}
+
+func (cf *ContainerdFactory) GetIPAddress(contID ContainerID) (string, error) {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+	ipAddr, ok := cf.ipAddresses[contID]
+	if !ok {
+		return "", fmt.Errorf("no IP address for container %s", contID)
+	}
+	return ipAddr, nil
+}
+
+func (cf *ContainerdFactory) GetStats(contID ContainerID) (*Stats, error) {
+	cont, err := cf.cli.LoadContainer(cf.ctx, contID)
+	if err != nil {
+		return nil, err
+	}
+	task, err := cont.Task(cf.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	metric, err := task.Metrics(cf.ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := typeurl.UnmarshalAny(metric.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch m := data.(type) {
+	case *cgroupsv1.Metrics:
+		if m.CPU == nil || m.CPU.Usage == nil || m.Memory == nil || m.Memory.Usage == nil {
+			return nil, StatsNotAvailableErr
+		}
+		return &Stats{
+			CPUTime:         time.Duration(m.CPU.Usage.Total),
+			PeakMemoryBytes: int64(m.Memory.Usage.Max),
+		}, nil
+	case *cgroupsv2.Metrics:
+		if m.CPU == nil || m.Memory == nil {
+			return nil, StatsNotAvailableErr
+		}
+		// the peak usage is not reported in the metrics of cgroup v2,
+		// but it can be read from the cgroup of the task
+		peak, err := processMemoryPeak(int(task.Pid()))
+		if err != nil {
+			peak = int64(m.Memory.Usage)
+		}
+		return &Stats{
+			CPUTime:         time.Duration(m.CPU.UsageUsec) * time.Microsecond,
+			PeakMemoryBytes: peak,
+		}, nil
+	default:
+		return nil, StatsNotAvailableErr
+	}
+}
+
+func (cf *ContainerdFactory) GetMemoryMB(contID ContainerID) (int64, error) {
+	cont, err := cf.cli.LoadContainer(cf.ctx, contID)
+	if err != nil {
+		return -1, err
+	}
+	spec, err := cont.Spec(cf.ctx)
+	if err != nil {
+		return -1, err
+	}
+	if spec.Linux == nil || spec.Linux.Resources == nil || spec.Linux.Resources.Memory == nil ||
+		spec.Linux.Resources.Memory.Limit == nil {
+		return -1, fmt.Errorf("no memory limit for container %s", contID)
+	}
+	return *spec.Linux.Resources.Memory.Limit / 1048576, nil
+}
+
+// getCNIAddress returns the IP address of the (non-loopback) container
+// interface.
+func getCNIAddress(result *gocni.Result) (string, error) {
+	for name, iface := range result.Interfaces {
+		if name == "lo" || !strings.HasPrefix(name, "eth") {
+			continue
+		}
+		for _, ipConfig := range iface.IPConfigs {
+			if ipConfig.IP.To4() != nil {
+				return ipConfig.IP.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no IP address assigned by CNI")
+}
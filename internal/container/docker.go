@@ -2,22 +2,46 @@ package container
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/function"
 	//	"github.com/docker/docker/pkg/stdcopy"
 )
 
 type DockerFactory struct {
 	cli *client.Client
 	ctx context.Context
+
+	// networks created (or found) by the factory
+	networks     map[string]bool
+	networkMutex sync.Mutex
+}
+
+// dockerNetworks contains the options of the networks used for each network
+// mode. Internal networks are not connected to external networks, while
+// containers in the "none" network cannot communicate with each other;
+// containers can be reached by the host in every case.
+var dockerNetworks = map[string]types.NetworkCreate{
+	function.NETWORK_NONE: {
+		Driver:   "bridge",
+		Internal: true,
+		Options:  map[string]string{"com.docker.network.bridge.enable_icc": "false"},
+	},
+	function.NETWORK_INTERNAL: {Driver: "bridge", Internal: true},
+	function.NETWORK_FULL:     {Driver: "bridge"},
 }
 
 func InitDockerContainerFactory() *DockerFactory {
@@ -27,24 +51,24 @@ func InitDockerContainerFactory() *DockerFactory {
 		panic(err)
 	}
 
-	dockerFact := &DockerFactory{cli, ctx}
+	dockerFact := &DockerFactory{cli: cli, ctx: ctx, networks: make(map[string]bool)}
 	cf = dockerFact
 	return dockerFact
 }
 
 func (cf *DockerFactory) Create(image string, opts *ContainerOptions) (ContainerID, error) {
 	if !cf.HasImage(image) {
-		log.Printf("Pulling image: %s", image)
+		slog.Info("Pulling image", "image", image)
 		pullResp, err := cf.cli.ImagePull(cf.ctx, image, types.ImagePullOptions{})
 		if err != nil {
-			log.Printf("Could not pull image: %s", image)
+			slog.Warn("Could not pull image", "image", image, "err", err)
 			// we do not return here, as a stale copy of the image
 			// could still be available locally
 		} else {
 			defer pullResp.Close()
 			// This seems to be necessary to wait for the image to be pulled:
 			io.Copy(ioutil.Discard, pullResp)
-			log.Printf("Pulled image: %s", image)
+			slog.Info("Pulled image", "image", image)
 			refreshedImages[image] = true
 		}
 	}
@@ -55,21 +79,84 @@ func (cf *DockerFactory) Create(image string, opts *ContainerOptions) (Container
 		contResources.CPUQuota = (int64)(50000.0 * opts.CPUQuota)
 	}
 
-	resp, err := cf.cli.ContainerCreate(cf.ctx, &container.Config{
+	contConfig := &container.Config{
 		Image: image,
 		Cmd:   opts.Cmd,
 		Env:   opts.Env,
 		Tty:   false,
-	}, &container.HostConfig{Resources: contResources}, nil, nil, "")
+	}
+	network, err := cf.getNetwork(opts.Network)
+	if err != nil {
+		return "", err
+	}
+	hostConfig := &container.HostConfig{Resources: contResources, NetworkMode: container.NetworkMode(network)}
+	if opts.Hardening != nil {
+		if err := applyDockerHardening(opts.Hardening, contConfig, hostConfig); err != nil {
+			return "", err
+		}
+	}
 
+	resp, err := cf.cli.ContainerCreate(cf.ctx, contConfig, hostConfig, nil, nil, "")
+	if err != nil {
+		return "", err
+	}
 	id := resp.ID
 
 	r, err := cf.cli.ContainerInspect(cf.ctx, id)
-	log.Printf("Container %s has name %s", id, r.Name)
+	slog.Debug("Created container", "container", id, "name", r.Name)
 
 	return id, err
 }
 
+// SupportsNetwork returns true for every known network mode.
+func (cf *DockerFactory) SupportsNetwork(mode string) bool {
+	_, ok := dockerNetworks[mode]
+	return ok
+}
+
+// getNetwork returns the network used for a network mode, creating it if
+// needed.
+func (cf *DockerFactory) getNetwork(mode string) (string, error) {
+	if mode == "" {
+		mode = function.NETWORK_FULL
+	}
+	options, ok := dockerNetworks[mode]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", NetworkNotSupportedErr, mode)
+	}
+	name := "serverledge-" + mode
+
+	cf.networkMutex.Lock()
+	defer cf.networkMutex.Unlock()
+	if cf.networks[name] {
+		return name, nil
+	}
+
+	network, err := cf.cli.NetworkInspect(cf.ctx, name, types.NetworkInspectOptions{})
+	if err == nil {
+		// the network may have been created by someone else
+		mismatch := network.Internal != options.Internal
+		for k, v := range options.Options {
+			mismatch = mismatch || network.Options[k] != v
+		}
+		if mismatch {
+			return "", fmt.Errorf("network %s exists, but its configuration does not match", name)
+		}
+	} else if client.IsErrNotFound(err) {
+		options.CheckDuplicate = true
+		options.Labels = map[string]string{"serverledge.network": mode}
+		if _, err := cf.cli.NetworkCreate(cf.ctx, name, options); err != nil {
+			return "", fmt.Errorf("could not create network %s: %w", name, err)
+		}
+		slog.Info("Created network", "network", name)
+	} else {
+		return "", err
+	}
+
+	cf.networks[name] = true
+	return name, nil
+}
+
 func (cf *DockerFactory) CopyToContainer(contID ContainerID, content io.Reader, destPath string) error {
 	return cf.cli.CopyToContainer(cf.ctx, contID, destPath, content, types.CopyToContainerOptions{})
 }
@@ -85,7 +172,46 @@ func (cf *DockerFactory) Start(contID ContainerID) error {
 func (cf *DockerFactory) Destroy(contID ContainerID) error {
 	// force set to true causes running container to be killed (and then
 	// removed)
-	return cf.cli.ContainerRemove(cf.ctx, contID, types.ContainerRemoveOptions{Force: true})
+	// volumes are removed as well (see applyDockerHardening)
+	return cf.cli.ContainerRemove(cf.ctx, contID, types.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
+}
+
+// applyDockerHardening sets the security options of a new container.
+func applyDockerHardening(h *Hardening, contConfig *container.Config, hostConfig *container.HostConfig) error {
+	if h.ReadOnlyRootfs {
+		hostConfig.ReadonlyRootfs = true
+		hostConfig.Tmpfs = map[string]string{"/tmp": fmt.Sprintf("rw,nosuid,nodev,size=%dm", h.TmpfsMB)}
+		// the code of the function is copied into an anonymous volume,
+		// as Docker refuses to copy files into a read-only rootfs
+		contConfig.Volumes = map[string]struct{}{"/app": {}}
+	}
+	contConfig.User = h.User
+	if h.DropCapabilities {
+		hostConfig.CapDrop = []string{"ALL"}
+		for _, c := range h.Capabilities {
+			hostConfig.CapAdd = append(hostConfig.CapAdd, capabilityName(c))
+		}
+	}
+	if h.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges")
+	}
+	if h.PidsLimit > 0 {
+		hostConfig.Resources.PidsLimit = &h.PidsLimit
+	}
+	if h.SeccompProfile == SECCOMP_UNCONFINED {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp=unconfined")
+	} else if h.SeccompProfile != "" {
+		// Docker expects the content of the profile, not its path
+		profile, err := os.ReadFile(h.SeccompProfile)
+		if err != nil {
+			return fmt.Errorf("could not read seccomp profile: %w", err)
+		}
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+string(profile))
+	}
+	if h.GVisor {
+		hostConfig.Runtime = getGVisorRuntime("runsc")
+	}
+	return nil
 }
 
 func (cf *DockerFactory) HasImage(image string) bool {
@@ -110,6 +236,12 @@ func (cf *DockerFactory) GetIPAddress(contID ContainerID) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	// containers are attached to a single network
+	for _, network := range contJson.NetworkSettings.Networks {
+		if network.IPAddress != "" {
+			return network.IPAddress, nil
+		}
+	}
 	return contJson.NetworkSettings.IPAddress, nil
 }
 
@@ -120,3 +252,53 @@ func (cf *DockerFactory) GetMemoryMB(contID ContainerID) (int64, error) {
 	}
 	return contJson.HostConfig.Memory / 1048576, nil
 }
+
+func (cf *DockerFactory) GetStats(contID ContainerID) (*Stats, error) {
+	resp, err := cf.cli.ContainerStatsOneShot(cf.ctx, contID)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var stats types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+
+	// max_usage is only reported with cgroup v1: with cgroup v2, the peak
+	// is read from the cgroup of the container, if available
+	peak := int64(stats.MemoryStats.MaxUsage)
+	if peak == 0 {
+		peak = int64(stats.MemoryStats.Usage)
+		if info, err := cf.cli.ContainerInspect(cf.ctx, contID); err == nil && info.State != nil {
+			if p, err := processMemoryPeak(info.State.Pid); err == nil {
+				peak = p
+			}
+		}
+	}
+	return &Stats{
+		CPUTime:         time.Duration(stats.CPUStats.CPUUsage.TotalUsage),
+		PeakMemoryBytes: peak,
+	}, nil
+}
+
+func (cf *DockerFactory) Checkpoint(contID ContainerID, snapshotID string) error {
+	return cf.cli.CheckpointCreate(cf.ctx, contID, types.CheckpointCreateOptions{
+		CheckpointID:  snapshotID,
+		CheckpointDir: getSnapshotsDir(),
+		Exit:          false,
+	})
+}
+
+func (cf *DockerFactory) Restore(contID ContainerID, snapshotID string) error {
+	return cf.cli.ContainerStart(cf.ctx, contID, types.ContainerStartOptions{
+		CheckpointID:  snapshotID,
+		CheckpointDir: getSnapshotsDir(),
+	})
+}
+
+func (cf *DockerFactory) DeleteSnapshot(snapshotID string) error {
+	// the container that has been checkpointed may not exist anymore, so
+	// we directly remove the checkpoint from the custom directory
+	return os.RemoveAll(filepath.Join(getSnapshotsDir(), snapshotID))
+}
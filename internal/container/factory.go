@@ -1,6 +1,14 @@
 package container
 
-import "io"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/executor"
+)
 
 // A Factory to create and manage container.
 type Factory interface {
@@ -11,17 +19,112 @@ type Factory interface {
 	HasImage(string) bool
 	GetIPAddress(ContainerID) (string, error)
 	GetMemoryMB(id ContainerID) (int64, error)
+	// GetStats returns the resources used by a container so far.
+	GetStats(ContainerID) (*Stats, error)
+}
+
+// Stats reports the resource usage of a container since its creation, as
+// accounted by its cgroup.
+type Stats struct {
+	CPUTime time.Duration // total CPU time (user + system)
+	// PeakMemoryBytes is the peak memory usage or, with cgroup v2 on
+	// kernels older than 5.19 (which lack memory.peak), the current usage
+	PeakMemoryBytes int64
 }
 
+var StatsNotAvailableErr = errors.New("resource usage stats not available")
+
 // ContainerOptions contains options for container creation.
 type ContainerOptions struct {
 	Cmd      []string
 	Env      []string
 	MemoryMB int64
 	CPUQuota float64
+	// Hardening is applied by Docker and containerd (nil = runtime
+	// defaults)
+	Hardening *Hardening
+	// Network is the network mode of the container (default:
+	// function.NETWORK_FULL); restricted modes are only supported by
+	// NetworkIsolator factories
+	Network string
 }
 
 type ContainerID = string
 
 // cf is the container factory for the node
 var cf Factory
+
+// getFactoryForImage returns the factory used to run containers of an image.
+func getFactoryForImage(image string) Factory {
+	if image == WASM_IMAGE && wasmFact != nil {
+		return wasmFact
+	}
+	return cf
+}
+
+// getFactory returns the factory managing a container.
+func getFactory(contID ContainerID) Factory {
+	if wasmFact != nil && wasmFact.hasInstance(contID) {
+		return wasmFact
+	}
+	return cf
+}
+
+// SetFactory replaces the container factory for the node (e.g., with a
+// FakeFactory in tests).
+func SetFactory(f Factory) {
+	cf = f
+}
+
+// InitContainerFactory initializes the container factory chosen in the
+// configuration, along with the factory for WebAssembly functions.
+func InitContainerFactory() (Factory, error) {
+	InitWasmFactory()
+
+	var f Factory
+	switch factory := config.GetString(config.CONTAINER_FACTORY, "docker"); factory {
+	case "docker":
+		f = InitDockerContainerFactory()
+	case "containerd":
+		f = InitContainerdFactory()
+	case "process":
+		f = InitProcessFactory()
+	default:
+		return nil, fmt.Errorf("unknown container factory: %s", factory)
+	}
+	if err := checkDefaultNetwork(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// An ExecutorPortProvider is a Factory whose Executors do not listen on the
+// default port (e.g., because they share the host network).
+type ExecutorPortProvider interface {
+	GetExecutorPort(ContainerID) (int, error)
+}
+
+// A NetworkIsolator is a Factory that can enforce restricted network modes
+// (e.g., function.NETWORK_NONE).
+type NetworkIsolator interface {
+	SupportsNetwork(mode string) bool
+}
+
+// An Invoker is a Factory that runs functions within the node process,
+// instead of sending requests to an Executor.
+type Invoker interface {
+	Invoke(ContainerID, *executor.InvocationRequest) (*executor.InvocationResult, error)
+}
+
+// A SnapshotFactory is a Factory that can checkpoint running containers and
+// start new containers from the resulting snapshots (e.g., using CRIU).
+type SnapshotFactory interface {
+	Factory
+	// Checkpoint takes a snapshot of a running container, which keeps
+	// running afterwards.
+	Checkpoint(contID ContainerID, snapshotID string) error
+	// Restore starts a newly created container from a snapshot, instead
+	// of starting it from scratch.
+	Restore(contID ContainerID, snapshotID string) error
+	DeleteSnapshot(snapshotID string) error
+}
@@ -0,0 +1,145 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/grussorusso/serverledge/internal/executor"
+)
+
+const conformanceHandler = `def handler(params, context):
+    return "Hello, {}".format(params["name"])
+`
+
+// testFactoryConformance checks the behavior that the rest of Serverledge
+// expects from every Factory, running a function in a new container.
+func testFactoryConformance(t *testing.T, f Factory) {
+	cf = f
+	runtime := RuntimeToInfo["python310"]
+
+	opts := &ContainerOptions{MemoryMB: 128, CPUQuota: 0.5, Hardening: DefaultHardening()}
+	contID, err := f.Create(runtime.Image, opts)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer Destroy(contID)
+
+	if !f.HasImage(runtime.Image) {
+		t.Errorf("HasImage returned false after Create")
+	}
+
+	if mem, err := f.GetMemoryMB(contID); err != nil {
+		t.Errorf("GetMemoryMB failed: %v", err)
+	} else if mem != 128 {
+		t.Errorf("GetMemoryMB returned %d, expected 128", mem)
+	}
+
+	if err := f.CopyToContainer(contID, codeArchive(t, "handler.py", conformanceHandler), "/app/"); err != nil {
+		t.Fatalf("CopyToContainer failed: %v", err)
+	}
+
+	if err := f.Start(contID); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if ip, err := f.GetIPAddress(contID); err != nil || ip == "" {
+		t.Fatalf("GetIPAddress failed: '%s' %v", ip, err)
+	}
+
+	result, _, err := Execute(contID, &executor.InvocationRequest{
+		Command:    runtime.InvocationCmd,
+		Params:     map[string]interface{}{"name": "Serverledge"},
+		Handler:    "handler.handler",
+		HandlerDir: "/app",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !result.Success || result.Result != `"Hello, Serverledge"` {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if err := Destroy(contID); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	if _, err := f.GetMemoryMB(contID); err == nil {
+		t.Errorf("GetMemoryMB succeeded on a destroyed container")
+	}
+}
+
+// codeArchive returns a tar archive containing a single file.
+func codeArchive(t *testing.T, name, content string) *bytes.Reader {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+	if err == nil {
+		_, err = tw.Write([]byte(content))
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestDockerFactoryConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Skipf("Docker not available: %v", err)
+	}
+	if _, err := cli.Ping(context.Background()); err != nil {
+		t.Skipf("Docker not available: %v", err)
+	}
+
+	testFactoryConformance(t, InitDockerContainerFactory())
+}
+
+func TestContainerdFactoryConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("containerd factory requires root privileges")
+	}
+	if _, err := os.Stat("/run/containerd/containerd.sock"); err != nil {
+		t.Skipf("containerd not available: %v", err)
+	}
+	if _, err := os.Stat("/etc/cni/net.d"); err != nil {
+		t.Skipf("CNI not configured: %v", err)
+	}
+
+	testFactoryConformance(t, InitContainerdFactory())
+}
+
+func TestProcessFactoryConformance(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+
+	f := InitProcessFactory()
+	// the interpreter may be installed outside of /usr (e.g., by pyenv)
+	f.mounts = []string{filepath.Dir(filepath.Dir(python))}
+	// the Executor is copied, so that no file is written in the source tree
+	executor, err := os.ReadFile("../../images/python310/executor.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.executorsDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(f.executorsDir, "executor.py"), executor, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testFactoryConformance(t, f)
+}
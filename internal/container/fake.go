@@ -0,0 +1,171 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/executor"
+)
+
+// FakeFactory is an in-memory Factory, which does not actually run
+// containers. Cold starts and invocations just take the configured delays,
+// so that the container pool and the scheduler can be tested and
+// benchmarked without Docker.
+type FakeFactory struct {
+	ColdStartDelay time.Duration // added to Start
+	ExecutionDelay time.Duration // added to every invocation
+	// ExecutionTime, if set, overrides ExecutionDelay for each invocation
+	ExecutionTime func(*executor.InvocationRequest) time.Duration
+	// Result is returned by every invocation
+	Result string
+	// FailInvocations makes invocations fail
+	FailInvocations bool
+
+	mutex      sync.Mutex
+	containers map[ContainerID]*ContainerOptions
+	cpuTime    map[ContainerID]time.Duration
+	nextID     int
+	created    int
+	destroyed  int
+}
+
+func NewFakeFactory() *FakeFactory {
+	return &FakeFactory{
+		Result:     `"OK"`,
+		containers: make(map[ContainerID]*ContainerOptions),
+		cpuTime:    make(map[ContainerID]time.Duration),
+	}
+}
+
+func (f *FakeFactory) Create(image string, opts *ContainerOptions) (ContainerID, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.nextID++
+	f.created++
+	contID := fmt.Sprintf("fake-%d", f.nextID)
+	optsCopy := *opts
+	f.containers[contID] = &optsCopy
+	return contID, nil
+}
+
+func (f *FakeFactory) CopyToContainer(contID ContainerID, content io.Reader, destPath string) error {
+	if !f.exists(contID) {
+		return fmt.Errorf("no such container: %s", contID)
+	}
+	return nil
+}
+
+func (f *FakeFactory) Start(contID ContainerID) error {
+	if !f.exists(contID) {
+		return fmt.Errorf("no such container: %s", contID)
+	}
+	time.Sleep(f.ColdStartDelay)
+	return nil
+}
+
+func (f *FakeFactory) Destroy(contID ContainerID) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, ok := f.containers[contID]; !ok {
+		return fmt.Errorf("no such container: %s", contID)
+	}
+	delete(f.containers, contID)
+	delete(f.cpuTime, contID)
+	f.destroyed++
+	return nil
+}
+
+func (f *FakeFactory) HasImage(image string) bool {
+	return true
+}
+
+func (f *FakeFactory) GetIPAddress(contID ContainerID) (string, error) {
+	if !f.exists(contID) {
+		return "", fmt.Errorf("no such container: %s", contID)
+	}
+	return "127.0.0.1", nil
+}
+
+func (f *FakeFactory) GetMemoryMB(contID ContainerID) (int64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	opts, ok := f.containers[contID]
+	if !ok {
+		return -1, fmt.Errorf("no such container: %s", contID)
+	}
+	return opts.MemoryMB, nil
+}
+
+// GetStats reports the time spent serving invocations as CPU time and the
+// memory limit as peak memory usage.
+func (f *FakeFactory) GetStats(contID ContainerID) (*Stats, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	opts, ok := f.containers[contID]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", contID)
+	}
+	return &Stats{CPUTime: f.cpuTime[contID], PeakMemoryBytes: opts.MemoryMB * 1048576}, nil
+}
+
+func (f *FakeFactory) Invoke(contID ContainerID, req *executor.InvocationRequest) (*executor.InvocationResult, error) {
+	if !f.exists(contID) {
+		return nil, fmt.Errorf("no such container: %s", contID)
+	}
+	execTime := f.ExecutionDelay
+	if f.ExecutionTime != nil {
+		execTime = f.ExecutionTime(req)
+	}
+	time.Sleep(execTime)
+
+	f.mutex.Lock()
+	f.cpuTime[contID] += execTime
+	f.mutex.Unlock()
+
+	if f.FailInvocations {
+		return &executor.InvocationResult{Success: false}, nil
+	}
+	return &executor.InvocationResult{Success: true, Result: f.Result}, nil
+}
+
+func (f *FakeFactory) exists(contID ContainerID) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	_, ok := f.containers[contID]
+	return ok
+}
+
+// Running returns the number of existing containers.
+func (f *FakeFactory) Running() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.containers)
+}
+
+// Created returns the number of containers created so far (i.e., cold starts).
+func (f *FakeFactory) Created() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.created
+}
+
+// Destroyed returns the number of containers destroyed so far.
+func (f *FakeFactory) Destroyed() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.destroyed
+}
+
+// Options returns the options a container has been created with (nil if
+// the container does not exist).
+func (f *FakeFactory) Options(contID ContainerID) *ContainerOptions {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.containers[contID]
+}
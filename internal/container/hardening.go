@@ -0,0 +1,61 @@
+package container
+
+import (
+	"strings"
+
+	"github.com/grussorusso/serverledge/internal/config"
+)
+
+// Hardening contains the security settings applied to a container, on top of
+// the defaults of the container runtime.
+type Hardening struct {
+	ReadOnlyRootfs bool  // mounts the root filesystem read-only
+	TmpfsMB        int64 // size of the tmpfs mounted on /tmp (with a read-only rootfs)
+	User           string
+	// DropCapabilities drops every Linux capability, except for those
+	// listed in Capabilities.
+	DropCapabilities bool
+	Capabilities     []string
+	NoNewPrivileges  bool
+	PidsLimit        int64 // max. number of processes (0 = unlimited)
+	// SeccompProfile is the file containing the seccomp profile; the
+	// default profile of the runtime is used if empty, while
+	// SECCOMP_UNCONFINED disables seccomp.
+	SeccompProfile string
+	GVisor         bool // runs the container with the gVisor runtime
+}
+
+const SECCOMP_UNCONFINED = "unconfined"
+
+// DefaultHardening returns the hardening settings applied to every function
+// container, or nil if hardening is disabled in the configuration.
+func DefaultHardening() *Hardening {
+	if !config.GetBool(config.CONTAINER_HARDENING_ENABLED, true) {
+		return nil
+	}
+	return &Hardening{
+		ReadOnlyRootfs:   true,
+		TmpfsMB:          int64(config.GetInt(config.CONTAINER_HARDENING_TMPFS, 64)),
+		User:             config.GetString(config.CONTAINER_HARDENING_USER, "65534:65534"),
+		DropCapabilities: true,
+		NoNewPrivileges:  true,
+		PidsLimit:        int64(config.GetInt(config.CONTAINER_HARDENING_PIDS, 256)),
+		SeccompProfile:   config.GetString(config.CONTAINER_HARDENING_SECCOMP, ""),
+	}
+}
+
+// getGVisorRuntime returns the name of the gVisor runtime in the
+// configuration, or the given default value.
+func getGVisorRuntime(defaultRuntime string) string {
+	return config.GetString(config.CONTAINER_GVISOR_RUNTIME, defaultRuntime)
+}
+
+// capabilityName returns the name of a capability with the "CAP_" prefix
+// (e.g., CAP_NET_RAW for NET_RAW).
+func capabilityName(capability string) string {
+	capability = strings.ToUpper(capability)
+	if strings.HasPrefix(capability, "CAP_") {
+		return capability
+	}
+	return "CAP_" + capability
+}
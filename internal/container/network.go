@@ -0,0 +1,62 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/function"
+)
+
+// networkModes lists the network modes, from the most restrictive one.
+var networkModes = []string{function.NETWORK_NONE, function.NETWORK_INTERNAL, function.NETWORK_FULL}
+
+var NetworkNotSupportedErr = errors.New("network mode not supported by the container factory")
+
+// DefaultNetworkMode returns the network mode of containers whose function
+// does not specify one.
+func DefaultNetworkMode() string {
+	return config.GetString(config.CONTAINER_NETWORK_DEFAULT, function.NETWORK_FULL)
+}
+
+// IsNetworkRelaxed returns true if a network mode is less restrictive than
+// the default one.
+func IsNetworkRelaxed(mode string) bool {
+	return networkRank(mode) > networkRank(DefaultNetworkMode())
+}
+
+func networkRank(mode string) int {
+	for i, m := range networkModes {
+		if m == mode {
+			return i
+		}
+	}
+	// unknown modes are treated as the least restrictive one
+	return len(networkModes)
+}
+
+// checkDefaultNetwork returns an error if the default network mode is unknown
+// or cannot be enforced by the factory, as every container would then fail
+// to be created.
+func checkDefaultNetwork(f Factory) error {
+	mode := DefaultNetworkMode()
+	if networkRank(mode) == len(networkModes) {
+		return fmt.Errorf("unknown network mode in %s: %s", config.CONTAINER_NETWORK_DEFAULT, mode)
+	}
+	if mode == function.NETWORK_FULL {
+		return nil
+	}
+	if ni, ok := f.(NetworkIsolator); ok && ni.SupportsNetwork(mode) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s (%s)", NetworkNotSupportedErr, mode, config.CONTAINER_NETWORK_DEFAULT)
+}
+
+// checkUnrestrictedNetwork returns an error if the container requires a
+// restricted network mode, for factories which cannot enforce it.
+func checkUnrestrictedNetwork(opts *ContainerOptions) error {
+	if opts.Network != "" && opts.Network != function.NETWORK_FULL {
+		return fmt.Errorf("%w: %s", NetworkNotSupportedErr, opts.Network)
+	}
+	return nil
+}
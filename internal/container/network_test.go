@@ -0,0 +1,33 @@
+package container
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/spf13/viper"
+)
+
+func TestCheckDefaultNetwork(t *testing.T) {
+	defer viper.Set(config.CONTAINER_NETWORK_DEFAULT, nil)
+	docker := &DockerFactory{}
+	process := &ProcessFactory{}
+
+	if err := checkDefaultNetwork(process); err != nil {
+		t.Errorf("default network mode rejected: %v", err)
+	}
+
+	viper.Set(config.CONTAINER_NETWORK_DEFAULT, function.NETWORK_INTERNAL)
+	if err := checkDefaultNetwork(docker); err != nil {
+		t.Errorf("restricted network mode rejected by Docker: %v", err)
+	}
+	if err := checkDefaultNetwork(process); !errors.Is(err, NetworkNotSupportedErr) {
+		t.Errorf("restricted network mode accepted by the process factory: %v", err)
+	}
+
+	viper.Set(config.CONTAINER_NETWORK_DEFAULT, "bogus")
+	if err := checkDefaultNetwork(docker); err == nil {
+		t.Errorf("unknown network mode accepted")
+	}
+}
@@ -0,0 +1,334 @@
+package container
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/lithammer/shortuuid"
+)
+
+// ProcessFactory runs Executors as plain processes, without containers.
+// Each instance has its own directory (where function code is unpacked) and
+// listens on a unique port. Where available, instances are isolated using
+// cgroups v2 and Linux namespaces.
+type ProcessFactory struct {
+	rootDir      string
+	executorsDir string
+	cgroup       string // empty if cgroups are not available
+	uid, gid     int    // user running the Executors, when sandboxed
+	mounts       []string
+
+	instances map[ContainerID]*processInstance
+	mutex     sync.Mutex
+}
+
+type processInstance struct {
+	dir       string
+	port      int
+	listener  *os.File // inherited by the Executor, nil once started
+	args      []string
+	opts      ContainerOptions
+	cmd       *exec.Cmd
+	cgroupDir string // empty if the instance has no cgroup
+}
+
+func InitProcessFactory() *ProcessFactory {
+	rootDir := config.GetString(config.PROCESS_ROOT_DIR, filepath.Join(os.TempDir(), "serverledge"))
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		panic(err)
+	}
+
+	processFact := &ProcessFactory{
+		rootDir:      rootDir,
+		executorsDir: config.GetString(config.PROCESS_EXECUTORS_DIR, "/opt/serverledge/executors"),
+		instances:    make(map[ContainerID]*processInstance),
+	}
+
+	user := config.GetString(config.PROCESS_USER, "65534:65534")
+	uid, gid, err := parseUser(user)
+	if err != nil {
+		panic(fmt.Errorf("invalid %s: %v", config.PROCESS_USER, err))
+	}
+	processFact.uid, processFact.gid = uid, gid
+	if mounts := config.GetString(config.PROCESS_MOUNTS, ""); mounts != "" {
+		processFact.mounts = strings.Split(mounts, ",")
+	}
+
+	cgroup := config.GetString(config.PROCESS_CGROUP, "/sys/fs/cgroup/serverledge")
+	if err := initCgroup(cgroup); err != nil {
+		slog.Warn("cgroups not available, resource limits will not be enforced", "err", err)
+	} else {
+		processFact.cgroup = cgroup
+	}
+
+	cf = processFact
+	return processFact
+}
+
+// executorCommand returns the command that runs the Executor for an image.
+// Only the images of the supported runtimes can be used.
+func (cf *ProcessFactory) executorCommand(image string) ([]string, bool) {
+	switch image {
+	case RuntimeToInfo["python310"].Image:
+		return []string{"python3", filepath.Join(cf.executorsDir, "executor.py")}, true
+	case RuntimeToInfo["nodejs17ng"].Image:
+		return []string{"node", filepath.Join(cf.executorsDir, "executor.js")}, true
+	case "grussorusso/serverledge-base":
+		return []string{filepath.Join(cf.executorsDir, "executor")}, true
+	default:
+		return nil, false
+	}
+}
+
+func (cf *ProcessFactory) Create(image string, opts *ContainerOptions) (ContainerID, error) {
+	args, ok := cf.executorCommand(image)
+	if !ok {
+		return "", fmt.Errorf("image not supported without containers: %s", image)
+	}
+	// instances share the network of the host
+	if err := checkUnrestrictedNetwork(opts); err != nil {
+		return "", err
+	}
+
+	id := "proc-" + shortuuid.New()
+	dir := filepath.Join(cf.rootDir, id)
+	if err := os.MkdirAll(filepath.Join(dir, "app"), 0755); err != nil {
+		return "", err
+	}
+
+	// the Executor inherits the listener, so that no other process can take
+	// its port in the meantime
+	listener, port, err := listenLoopback()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	cf.mutex.Lock()
+	cf.instances[id] = &processInstance{dir: dir, port: port, listener: listener, args: args, opts: *opts}
+	cf.mutex.Unlock()
+
+	return id, nil
+}
+
+func (cf *ProcessFactory) getInstance(contID ContainerID) (*processInstance, error) {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+	instance, ok := cf.instances[contID]
+	if !ok {
+		return nil, fmt.Errorf("no such instance: %s", contID)
+	}
+	return instance, nil
+}
+
+func (cf *ProcessFactory) CopyToContainer(contID ContainerID, content io.Reader, destPath string) error {
+	instance, err := cf.getInstance(contID)
+	if err != nil {
+		return err
+	}
+
+	return extractTar(content, filepath.Join(instance.dir, destPath))
+}
+
+func (cf *ProcessFactory) Start(contID ContainerID) error {
+	instance, err := cf.getInstance(contID)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(instance.args[0], instance.args[1:]...)
+	cmd.Dir = instance.dir
+	// the environment of the node (e.g., credentials) is not inherited
+	cmd.Env = append([]string{"PATH=" + os.Getenv("PATH"), "HOME=/tmp"}, instance.opts.Env...)
+	cmd.Env = append(cmd.Env,
+		"EXECUTOR_PORT="+strconv.Itoa(instance.port),
+		"EXECUTOR_LISTEN_FD=3",
+		// the Executors directory is shared by instances
+		"PYTHONDONTWRITEBYTECODE=1")
+	cmd.ExtraFiles = []*os.File{instance.listener}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	var cgroupDir string
+	if cf.cgroup != "" {
+		cgroupDir = filepath.Join(cf.cgroup, contID)
+		if err := createCgroup(cgroupDir, &instance.opts); err != nil {
+			slog.Warn("Could not create cgroup", "container", contID, "err", err)
+			cgroupDir = ""
+		}
+	}
+
+	chrooted, closeSandbox, err := cf.sandbox(cmd, instance.dir, cgroupDir)
+	if err != nil {
+		return err
+	}
+	defer closeSandbox()
+	if !chrooted {
+		cmd.Env = append(cmd.Env, "EXECUTOR_ROOT="+instance.dir)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	cf.mutex.Lock()
+	instance.cmd = cmd
+	instance.cgroupDir = cgroupDir
+	instance.listener.Close()
+	instance.listener = nil
+	cf.mutex.Unlock()
+
+	// reap the process when it terminates
+	go cmd.Wait()
+
+	return nil
+}
+
+func (cf *ProcessFactory) Destroy(contID ContainerID) error {
+	cf.mutex.Lock()
+	instance, ok := cf.instances[contID]
+	delete(cf.instances, contID)
+	cf.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("no such instance: %s", contID)
+	}
+
+	if instance.cmd != nil {
+		killProcessGroup(instance.cmd)
+	}
+	if instance.listener != nil {
+		instance.listener.Close()
+	}
+	if cf.cgroup != "" {
+		removeCgroup(filepath.Join(cf.cgroup, contID))
+	}
+
+	return os.RemoveAll(instance.dir)
+}
+
+func (cf *ProcessFactory) HasImage(image string) bool {
+	_, ok := cf.executorCommand(image)
+	return ok
+}
+
+func (cf *ProcessFactory) GetIPAddress(contID ContainerID) (string, error) {
+	if _, err := cf.getInstance(contID); err != nil {
+		return "", err
+	}
+	return "127.0.0.1", nil
+}
+
+func (cf *ProcessFactory) GetExecutorPort(contID ContainerID) (int, error) {
+	instance, err := cf.getInstance(contID)
+	if err != nil {
+		return 0, err
+	}
+	return instance.port, nil
+}
+
+func (cf *ProcessFactory) GetMemoryMB(contID ContainerID) (int64, error) {
+	instance, err := cf.getInstance(contID)
+	if err != nil {
+		return -1, err
+	}
+	return instance.opts.MemoryMB, nil
+}
+
+func (cf *ProcessFactory) GetStats(contID ContainerID) (*Stats, error) {
+	instance, err := cf.getInstance(contID)
+	if err != nil {
+		return nil, err
+	}
+
+	cf.mutex.Lock()
+	cgroupDir := instance.cgroupDir
+	cf.mutex.Unlock()
+	if cgroupDir == "" {
+		return nil, StatsNotAvailableErr
+	}
+	return readCgroupStats(cgroupDir)
+}
+
+// listenLoopback listens on a free TCP port on the loopback interface,
+// returning the file of the listener, which can be inherited by a process.
+func listenLoopback() (*os.File, int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		return nil, 0, err
+	}
+	return f, l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// parseUser parses a user in the uid:gid format.
+func parseUser(user string) (int, int, error) {
+	uid, gid, found := strings.Cut(user, ":")
+	if !found {
+		return 0, 0, fmt.Errorf("user must be in the uid:gid format: '%s'", user)
+	}
+	u, err := strconv.Atoi(uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid uid: '%s'", uid)
+	}
+	g, err := strconv.Atoi(gid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid gid: '%s'", gid)
+	}
+	return u, g, nil
+}
+
+// extractTar extracts a tar archive into dest, rejecting entries that would
+// be written outside of it.
+func extractTar(content io.Reader, dest string) error {
+	tr := tar.NewReader(content)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, hdr.Name)
+		if target != filepath.Clean(dest) && !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in archive: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			// links and special files are not supported
+			slog.Warn("Skipping archive entry", "entry", hdr.Name)
+		}
+	}
+}
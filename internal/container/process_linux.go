@@ -0,0 +1,273 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const cgroupCPUPeriod = 50000 // 50ms
+
+// initCgroup creates the (v2) cgroup under which function instances are
+// created, enabling the cpu and memory controllers for its children.
+func initCgroup(cgroup string) error {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err != nil {
+		return fmt.Errorf("cgroup v2 not mounted")
+	}
+	if err := os.MkdirAll(cgroup, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cgroup, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644)
+}
+
+func createCgroup(dir string, opts *ContainerOptions) error {
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+
+	if opts.MemoryMB > 0 {
+		limit := strconv.FormatInt(opts.MemoryMB*1048576, 10)
+		if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(limit), 0644); err != nil {
+			return err
+		}
+	}
+	if opts.CPUQuota > 0.0 {
+		limit := fmt.Sprintf("%d %d", int64(cgroupCPUPeriod*opts.CPUQuota), cgroupCPUPeriod)
+		if err := os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(limit), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeCgroup(dir string) {
+	// the cgroup can be removed only after its processes have terminated
+	for i := 0; i < 10; i++ {
+		if err := os.Remove(dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// readCgroupStats reads the CPU time and the peak memory usage of a cgroup.
+func readCgroupStats(dir string) (*Stats, error) {
+	cpuStat, err := os.ReadFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	for _, line := range strings.Split(string(cpuStat), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usec, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			stats.CPUTime = time.Duration(usec) * time.Microsecond
+		}
+	}
+
+	// memory.peak is not available on older kernels
+	stats.PeakMemoryBytes, err = readMemoryPeak(dir)
+	if os.IsNotExist(err) {
+		var memory []byte
+		if memory, err = os.ReadFile(filepath.Join(dir, "memory.current")); err != nil {
+			return nil, err
+		}
+		stats.PeakMemoryBytes, err = strconv.ParseInt(strings.TrimSpace(string(memory)), 10, 64)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// sandbox configures the Executor process to run in its own process group
+// and cgroup (if any). When running as root, the process also gets new mount,
+// PID, IPC and UTS namespaces, where the Executor is started by sandboxInit
+// in a chroot of the instance directory, as an unprivileged user; the network
+// namespace is shared with the host, and the Executor accepts connections on
+// the inherited loopback listener. The returned boolean tells whether the
+// process is chrooted.
+// The returned function must be called after the process has been started.
+func (cf *ProcessFactory) sandbox(cmd *exec.Cmd, root string, cgroupDir string) (bool, func(), error) {
+	attr := &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGKILL}
+	chrooted := os.Geteuid() == 0
+	if chrooted {
+		attr.Cloneflags = syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+
+		args := []string{sandboxInitArg, root, fmt.Sprintf("%d:%d", cf.uid, cf.gid)}
+		args = append(args, sandboxMounts...)
+		args = append(args, cf.executorsDir)
+		args = append(args, cf.mounts...)
+		args = append(args, "--", cmd.Path)
+		cmd.Args = append(args, cmd.Args[1:]...)
+		cmd.Path = "/proc/self/exe"
+	}
+
+	closeFn := func() {}
+	if cgroupDir != "" {
+		fd, err := syscall.Open(cgroupDir, syscall.O_DIRECTORY|syscall.O_RDONLY, 0)
+		if err != nil {
+			return false, nil, err
+		}
+		attr.UseCgroupFD = true
+		attr.CgroupFD = fd
+		closeFn = func() { syscall.Close(fd) }
+	}
+
+	cmd.SysProcAttr = attr
+	return chrooted, closeFn, nil
+}
+
+// sandboxInitArg is the name (i.e., argv[0]) with which Serverledge
+// re-executes itself to run sandboxInit.
+const sandboxInitArg = "serverledge-sandbox"
+
+// sandboxMounts are the host paths made available (read-only) to Executors,
+// i.e., interpreters, libraries and basic configuration files.
+var sandboxMounts = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/usr",
+	"/etc/hosts", "/etc/resolv.conf", "/etc/nsswitch.conf", "/etc/passwd", "/etc/group",
+	"/etc/localtime", "/etc/ld.so.cache", "/etc/ssl", "/etc/ca-certificates"}
+
+func init() {
+	if len(os.Args) > 0 && os.Args[0] == sandboxInitArg {
+		os.Exit(sandboxInit(os.Args[1:]))
+	}
+}
+
+// sandboxInit runs as the first process in the namespaces of an instance.
+// It prepares the filesystem of the instance and runs the Executor in it.
+// Arguments are the instance directory, the user of the Executor, the host
+// paths to mount and, after "--", the Executor command.
+func sandboxInit(args []string) int {
+	sep := slices.Index(args, "--")
+	if sep < 2 || sep == len(args)-1 {
+		fmt.Fprintln(os.Stderr, "sandbox: invalid arguments")
+		return 2
+	}
+	root, user, mounts, command := args[0], args[1], args[2:sep], args[sep+1:]
+	uid, gid, err := parseUser(user)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 2
+	}
+	if err := mountRoot(root, mounts); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 1
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = "/"
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{os.NewFile(3, "listener")}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Chroot:     root,
+		Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}},
+		Pdeathsig:  syscall.SIGKILL,
+	}
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// mountRoot prepares the root filesystem of an instance: host paths are
+// bind-mounted read-only, while /proc (showing the PID namespace of the
+// instance only), /dev and /tmp are mounted anew. Mounts are not visible
+// outside of the mount namespace of the instance.
+func mountRoot(root string, mounts []string) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("could not make mounts private: %v", err)
+	}
+	if err := mountFS("proc", filepath.Join(root, "proc"), syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return err
+	}
+	if err := mountFS("tmpfs", filepath.Join(root, "tmp"), syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return err
+	}
+	if err := mountFS("tmpfs", filepath.Join(root, "dev"), syscall.MS_NOSUID|syscall.MS_NOEXEC, "mode=755"); err != nil {
+		return err
+	}
+	// host paths are mounted over the file systems above, so that they are
+	// not hidden even if they are under /tmp or /dev
+	for _, path := range mounts {
+		if err := bindMount(path, root, syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV); err != nil {
+			return fmt.Errorf("could not mount %s: %v", path, err)
+		}
+	}
+	for _, dev := range []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"} {
+		if err := bindMount(dev, root, syscall.MS_NOSUID|syscall.MS_NOEXEC); err != nil {
+			return fmt.Errorf("could not mount %s: %v", dev, err)
+		}
+	}
+	return nil
+}
+
+func mountFS(fstype string, target string, flags uintptr, data string) error {
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount(fstype, target, fstype, flags, data); err != nil {
+		return fmt.Errorf("could not mount %s: %v", target, err)
+	}
+	return nil
+}
+
+// bindMount mounts a host path at the same path under root, with the given
+// flags. Missing paths are skipped, while symbolic links (e.g., /bin on
+// some distributions) are replicated.
+func bindMount(path string, root string, flags uintptr) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	target := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(link, target); err != nil && !os.IsExist(err) {
+			return err
+		}
+		return nil
+	}
+	if info.IsDir() {
+		err = os.MkdirAll(target, 0755)
+	} else {
+		err = os.WriteFile(target, nil, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.Mount(path, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return err
+	}
+	// flags of bind mounts can only be changed by remounting
+	return syscall.Mount("", target, "", syscall.MS_BIND|syscall.MS_REMOUNT|flags, "")
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+package container
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// cgroups and namespaces are only available on Linux: processes are run
+// without isolation and resource limits.
+
+func initCgroup(cgroup string) error {
+	return fmt.Errorf("cgroups not supported on this platform")
+}
+
+func createCgroup(dir string, opts *ContainerOptions) error {
+	return fmt.Errorf("cgroups not supported on this platform")
+}
+
+func removeCgroup(dir string) {}
+
+func readCgroupStats(dir string) (*Stats, error) {
+	return nil, StatsNotAvailableErr
+}
+
+func (cf *ProcessFactory) sandbox(cmd *exec.Cmd, root string, cgroupDir string) (bool, func(), error) {
+	return false, func() {}, nil
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
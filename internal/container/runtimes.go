@@ -8,10 +8,15 @@ type RuntimeInfo struct {
 
 const CUSTOM_RUNTIME = "custom"
 
+// WASM_IMAGE identifies functions run by the embedded WebAssembly runtime,
+// instead of a container image.
+const WASM_IMAGE = "serverledge-wasm"
+
 var refreshedImages = map[string]bool{}
 
 var RuntimeToInfo = map[string]RuntimeInfo{
 	"python310":  RuntimeInfo{"grussorusso/serverledge-python310", []string{"python", "/entrypoint.py"}},
 	"nodejs17":   RuntimeInfo{"grussorusso/serverledge-nodejs17", []string{"node", "/entrypoint.js"}},
 	"nodejs17ng": RuntimeInfo{"grussorusso/serverledge-nodejs17ng", []string{}},
+	"wasm":       RuntimeInfo{WASM_IMAGE, []string{}},
 }
@@ -0,0 +1,192 @@
+package container
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/config"
+)
+
+type snapshotState int
+
+const (
+	snapshotInProgress snapshotState = iota
+	snapshotReady
+	snapshotFailed
+)
+
+// snapshots keeps track of the snapshots taken on this node
+var snapshots = make(map[string]snapshotState)
+var snapshotsMutex sync.Mutex
+
+// getSnapshotFactory returns the container factory for an image, if snapshots
+// are enabled and supported by the factory.
+func getSnapshotFactory(image string) (SnapshotFactory, bool) {
+	if !config.GetBool(config.CONTAINER_SNAPSHOTS_ENABLED, false) {
+		return nil, false
+	}
+	sf, ok := getFactoryForImage(image).(SnapshotFactory)
+	return sf, ok
+}
+
+func getSnapshotsDir() string {
+	return config.GetString(config.CONTAINER_SNAPSHOTS_DIR, "/var/lib/serverledge/snapshots")
+}
+
+// snapshotIDFor returns the ID of the snapshot associated with key. Keys
+// are hashed, so that distinct keys never share a snapshot and IDs only
+// contain characters accepted by every factory.
+func snapshotIDFor(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "serverledge-" + hex.EncodeToString(sum[:])
+}
+
+// NewContainerFromSnapshot creates and starts a new container, restoring it
+// from the snapshot associated with key (e.g., the function name) if
+// available. Otherwise, the container is started from scratch and
+// checkpointed as soon as its Executor is ready, so that later cold starts
+// can be served by restoring it. As the snapshot is taken synchronously, its
+// cost is paid by the first cold start.
+// If snapshots are disabled or not supported by the container factory, this
+// is equivalent to NewContainer.
+// The returned flag reports whether the container has been restored from a
+// snapshot.
+func NewContainerFromSnapshot(key, image, codeTar string, opts *ContainerOptions) (ContainerID, bool, error) {
+	sf, ok := getSnapshotFactory(image)
+	if !ok {
+		contID, err := NewContainer(image, codeTar, opts)
+		return contID, false, err
+	}
+
+	snapshotID := snapshotIDFor(key)
+	snapshotsMutex.Lock()
+	state, found := snapshots[snapshotID]
+	if !found {
+		snapshots[snapshotID] = snapshotInProgress
+	}
+	snapshotsMutex.Unlock()
+
+	if found && state == snapshotReady {
+		contID, err := restoreContainer(sf, snapshotID, image, codeTar, opts)
+		if err == nil {
+			return contID, true, nil
+		}
+
+		slog.Warn("Restore from snapshot failed", "snapshot", snapshotID, "err", err)
+		setSnapshotState(snapshotID, snapshotFailed)
+		contID, err = NewContainer(image, codeTar, opts)
+		return contID, false, err
+	} else if found {
+		// snapshot not available (yet)
+		contID, err := NewContainer(image, codeTar, opts)
+		return contID, false, err
+	}
+
+	contID, err := NewContainer(image, codeTar, opts)
+	if err != nil {
+		snapshotsMutex.Lock()
+		delete(snapshots, snapshotID)
+		snapshotsMutex.Unlock()
+		return "", false, err
+	}
+
+	if err := checkpointContainer(sf, contID, snapshotID); err != nil {
+		slog.Warn("Could not create snapshot", "snapshot", snapshotID, "err", err)
+		setSnapshotState(snapshotID, snapshotFailed)
+	} else {
+		setSnapshotState(snapshotID, snapshotReady)
+	}
+
+	return contID, false, nil
+}
+
+// DeleteSnapshot removes the snapshot associated with key, if any.
+func DeleteSnapshot(key string) {
+	if !config.GetBool(config.CONTAINER_SNAPSHOTS_ENABLED, false) {
+		return
+	}
+	sf, ok := cf.(SnapshotFactory)
+	if !ok {
+		return
+	}
+
+	snapshotID := snapshotIDFor(key)
+	snapshotsMutex.Lock()
+	defer snapshotsMutex.Unlock()
+	if _, found := snapshots[snapshotID]; !found {
+		return
+	}
+	delete(snapshots, snapshotID)
+
+	if err := sf.DeleteSnapshot(snapshotID); err != nil {
+		slog.Warn("Could not delete snapshot", "snapshot", snapshotID, "err", err)
+	}
+}
+
+func setSnapshotState(snapshotID string, state snapshotState) {
+	snapshotsMutex.Lock()
+	defer snapshotsMutex.Unlock()
+	if _, found := snapshots[snapshotID]; found {
+		snapshots[snapshotID] = state
+	}
+}
+
+func restoreContainer(sf SnapshotFactory, snapshotID, image, codeTar string, opts *ContainerOptions) (ContainerID, error) {
+	contID, err := createWithCode(image, codeTar, opts)
+	if err != nil {
+		return "", err
+	}
+
+	err = sf.Restore(contID, snapshotID)
+	if err != nil {
+		sf.Destroy(contID)
+		return "", err
+	}
+
+	return contID, nil
+}
+
+func checkpointContainer(sf SnapshotFactory, contID ContainerID, snapshotID string) error {
+	if err := waitForExecutor(contID); err != nil {
+		return err
+	}
+
+	// a stale snapshot may exist (e.g., before the node was restarted)
+	sf.DeleteSnapshot(snapshotID)
+
+	return sf.Checkpoint(contID, snapshotID)
+}
+
+// waitForExecutor waits until the Executor in a container accepts
+// connections. Connections are closed before returning, as established
+// connections would prevent the container from being checkpointed.
+func waitForExecutor(contID ContainerID) error {
+	const TIMEOUT = 30 * time.Second
+	backoff := 10 * time.Millisecond
+
+	addr, err := getExecutorAddress(contID)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(TIMEOUT)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+
+		time.Sleep(backoff)
+		if backoff < 500*time.Millisecond {
+			backoff *= 2
+		}
+	}
+
+	return fmt.Errorf("executor not ready after %v", TIMEOUT)
+}
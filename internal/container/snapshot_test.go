@@ -0,0 +1,18 @@
+package container
+
+import "testing"
+
+func TestSnapshotIDFor(t *testing.T) {
+	keys := []string{"a/b@0", "a_b@0", "f@1@0", "f_1@0", "f@1"}
+	ids := make(map[string]string)
+	for _, key := range keys {
+		id := snapshotIDFor(key)
+		if other, found := ids[id]; found {
+			t.Errorf("keys '%s' and '%s' share snapshot %s", key, other, id)
+		}
+		ids[id] = key
+		if id != snapshotIDFor(key) {
+			t.Errorf("snapshot ID of '%s' is not stable", key)
+		}
+	}
+}
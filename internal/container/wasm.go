@@ -0,0 +1,260 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/executor"
+)
+
+const wasmPageSize = 65536
+const wasmMaxPages = 65536 // 4 GB
+
+// WasmFactory runs WebAssembly (WASI) functions within the node process,
+// using wazero. Each instance has its own runtime, whose memory is limited
+// according to the function memory demand. Modules are compiled once and
+// shared among instances through a compilation cache.
+type WasmFactory struct {
+	rootDir string
+	cache   wazero.CompilationCache
+	ctx     context.Context
+	timeout time.Duration // max. execution time of an invocation
+
+	instances map[ContainerID]*wasmInstance
+	mutex     sync.RWMutex
+}
+
+type wasmInstance struct {
+	dir     string
+	opts    ContainerOptions
+	runtime wazero.Runtime
+
+	modules map[string]wazero.CompiledModule // compiled modules by handler
+	mutex   sync.Mutex
+}
+
+// wasmFact is used for functions with the wasm runtime, regardless of the
+// node container factory
+var wasmFact *WasmFactory
+
+func InitWasmFactory() *WasmFactory {
+	rootDir := filepath.Join(config.GetString(config.PROCESS_ROOT_DIR, filepath.Join(os.TempDir(), "serverledge")), "wasm")
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		panic(err)
+	}
+
+	wasmFact = &WasmFactory{
+		rootDir:   rootDir,
+		cache:     wazero.NewCompilationCache(),
+		ctx:       context.Background(),
+		timeout:   time.Duration(config.GetFloat(config.WASM_TIMEOUT, 60) * float64(time.Second)),
+		instances: make(map[ContainerID]*wasmInstance),
+	}
+	return wasmFact
+}
+
+func (wf *WasmFactory) hasInstance(contID ContainerID) bool {
+	wf.mutex.RLock()
+	defer wf.mutex.RUnlock()
+	_, ok := wf.instances[contID]
+	return ok
+}
+
+func (wf *WasmFactory) getInstance(contID ContainerID) (*wasmInstance, error) {
+	wf.mutex.RLock()
+	defer wf.mutex.RUnlock()
+	instance, ok := wf.instances[contID]
+	if !ok {
+		return nil, fmt.Errorf("no such instance: %s", contID)
+	}
+	return instance, nil
+}
+
+func (wf *WasmFactory) Create(image string, opts *ContainerOptions) (ContainerID, error) {
+	id := "wasm-" + shortuuid.New()
+	dir := filepath.Join(wf.rootDir, id)
+	if err := os.MkdirAll(filepath.Join(dir, "app"), 0755); err != nil {
+		return "", err
+	}
+
+	wf.mutex.Lock()
+	wf.instances[id] = &wasmInstance{dir: dir, opts: *opts, modules: make(map[string]wazero.CompiledModule)}
+	wf.mutex.Unlock()
+
+	return id, nil
+}
+
+func (wf *WasmFactory) CopyToContainer(contID ContainerID, content io.Reader, destPath string) error {
+	instance, err := wf.getInstance(contID)
+	if err != nil {
+		return err
+	}
+
+	return extractTar(content, filepath.Join(instance.dir, destPath))
+}
+
+func (wf *WasmFactory) Start(contID ContainerID) error {
+	instance, err := wf.getInstance(contID)
+	if err != nil {
+		return err
+	}
+
+	rConfig := wazero.NewRuntimeConfig().
+		WithCompilationCache(wf.cache).
+		WithCloseOnContextDone(true)
+	if pages := instance.opts.MemoryMB * 1048576 / wasmPageSize; pages > 0 && pages < wasmMaxPages {
+		rConfig = rConfig.WithMemoryLimitPages(uint32(pages))
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(wf.ctx, rConfig)
+	if _, err := wasi_snapshot_preview1.Instantiate(wf.ctx, runtime); err != nil {
+		runtime.Close(wf.ctx)
+		return err
+	}
+
+	instance.runtime = runtime
+	return nil
+}
+
+func (wf *WasmFactory) Destroy(contID ContainerID) error {
+	wf.mutex.Lock()
+	instance, ok := wf.instances[contID]
+	delete(wf.instances, contID)
+	wf.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("no such instance: %s", contID)
+	}
+
+	if instance.runtime != nil {
+		instance.runtime.Close(wf.ctx)
+	}
+	return os.RemoveAll(instance.dir)
+}
+
+func (wf *WasmFactory) HasImage(image string) bool {
+	return image == WASM_IMAGE
+}
+
+func (wf *WasmFactory) GetIPAddress(contID ContainerID) (string, error) {
+	return "", errors.New("WebAssembly functions run within the node process")
+}
+
+// GetStats is not supported, as modules run within the node process.
+func (wf *WasmFactory) GetStats(contID ContainerID) (*Stats, error) {
+	return nil, StatsNotAvailableErr
+}
+
+func (wf *WasmFactory) GetMemoryMB(contID ContainerID) (int64, error) {
+	instance, err := wf.getInstance(contID)
+	if err != nil {
+		return -1, err
+	}
+	return instance.opts.MemoryMB, nil
+}
+
+// getModule returns the compiled module for a handler (i.e., the path of a
+// .wasm file within the function code). Handlers must not refer to files
+// outside the code directory.
+func (instance *wasmInstance) getModule(ctx context.Context, handlerDir, handler string) (wazero.CompiledModule, error) {
+	if !filepath.IsLocal(handler) {
+		return nil, fmt.Errorf("invalid handler: %s", handler)
+	}
+	modulePath := path.Join(handlerDir, handler)
+
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	if compiled, ok := instance.modules[modulePath]; ok {
+		return compiled, nil
+	}
+
+	code, err := os.ReadFile(filepath.Join(instance.dir, modulePath))
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := instance.runtime.CompileModule(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	instance.modules[modulePath] = compiled
+	return compiled, nil
+}
+
+// Invoke runs the function as a WASI command. The function reads its
+// JSON-encoded parameters (or its raw payload, if any) from the standard
+// input and writes its result on the standard output. The function code is
+// available (read-only) in /app. Functions running longer than the timeout
+// of the factory are terminated.
+func (wf *WasmFactory) Invoke(contID ContainerID, req *executor.InvocationRequest) (*executor.InvocationResult, error) {
+	instance, err := wf.getInstance(contID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(wf.ctx, wf.timeout)
+	defer cancel()
+
+	compiled, err := instance.getModule(ctx, req.HandlerDir, req.Handler)
+	if err != nil {
+		return nil, fmt.Errorf("could not load module %s: %v", req.Handler, err)
+	}
+
+	var stdin []byte
+	if req.Payload != nil {
+		stdin = req.Payload
+	} else if req.Params != nil {
+		stdin, _ = json.Marshal(req.Params)
+	}
+	var stdout, stderr bytes.Buffer
+
+	modConfig := wazero.NewModuleConfig().
+		WithName(""). // allows concurrent invocations
+		WithArgs(req.Handler).
+		WithStdin(bytes.NewReader(stdin)).
+		WithStdout(&stdout).
+		WithStderr(&stderr).
+		WithFSConfig(wazero.NewFSConfig().WithReadOnlyDirMount(filepath.Join(instance.dir, "app"), "/app")).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithEnv("CONTENT_TYPE", req.ContentType)
+	for _, env := range instance.opts.Env {
+		if k, v, ok := strings.Cut(env, "="); ok {
+			modConfig = modConfig.WithEnv(k, v)
+		}
+	}
+	for k, v := range req.Env {
+		modConfig = modConfig.WithEnv(k, v)
+	}
+
+	mod, err := instance.runtime.InstantiateModule(ctx, compiled, modConfig)
+	if mod != nil {
+		mod.Close(wf.ctx)
+	}
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == sys.ExitCodeDeadlineExceeded {
+		slog.Warn("Function timed out", "handler", req.Handler, "timeout", wf.timeout)
+		return &executor.InvocationResult{Success: false}, nil
+	}
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 0) {
+		slog.Warn("Function failed", "handler", req.Handler, "err", err, "stderr", stderr.String())
+		return &executor.InvocationResult{Success: false}, nil
+	}
+
+	return &executor.InvocationResult{Success: true, Result: stdout.String()}, nil
+}
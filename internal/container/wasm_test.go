@@ -0,0 +1,50 @@
+package container
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/executor"
+)
+
+// loopModule is a WASI command that never terminates, i.e.:
+// (module (func (export "_start") (loop (br 0))))
+var loopModule = string([]byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+	0x03, 0x02, 0x01, 0x00,
+	0x07, 0x0a, 0x01, 0x06, '_', 's', 't', 'a', 'r', 't', 0x00, 0x00,
+	0x0a, 0x09, 0x01, 0x07, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b,
+})
+
+func TestWasmInvoke(t *testing.T) {
+	wf := InitWasmFactory()
+	wf.timeout = 100 * time.Millisecond
+
+	contID, err := wf.Create(WASM_IMAGE, &ContainerOptions{MemoryMB: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wf.Destroy(contID)
+	if err := wf.CopyToContainer(contID, codeArchive(t, "loop.wasm", loopModule), "/app/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wf.Start(contID); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	result, err := wf.Invoke(contID, &executor.InvocationRequest{Handler: "loop.wasm", HandlerDir: "/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || time.Since(start) > 5*time.Second {
+		t.Errorf("function not terminated after the timeout: %+v", result)
+	}
+
+	for _, handler := range []string{"../loop.wasm", "../../app/loop.wasm", "/etc/passwd"} {
+		if _, err := wf.Invoke(contID, &executor.InvocationRequest{Handler: handler, HandlerDir: "/app"}); err == nil {
+			t.Errorf("handler %s accepted", handler)
+		}
+	}
+}
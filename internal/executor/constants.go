@@ -1,3 +1,44 @@
 package executor
 
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
 const DEFAULT_EXECUTOR_PORT = 8080
+
+// GetExecutorPort returns the port the Executor listens on, which can be set
+// through the EXECUTOR_PORT environment variable (e.g., when multiple
+// Executors run on the same host without containers).
+func GetExecutorPort() int {
+	if port, err := strconv.Atoi(os.Getenv("EXECUTOR_PORT")); err == nil {
+		return port
+	}
+	return DEFAULT_EXECUTOR_PORT
+}
+
+// Listen returns the listener of the Executor. If the EXECUTOR_LISTEN_FD
+// environment variable is set, the listening socket inherited with that
+// descriptor is used; otherwise, the Executor listens on the port returned
+// by GetExecutorPort, on the address in EXECUTOR_HOST (by default, on every
+// interface, so that the Executor can be reached in a container).
+func Listen() (net.Listener, error) {
+	if fd, err := strconv.Atoi(os.Getenv("EXECUTOR_LISTEN_FD")); err == nil {
+		return net.FileListener(os.NewFile(uintptr(fd), "listener"))
+	}
+	return net.Listen("tcp", fmt.Sprintf("%s:%d", os.Getenv("EXECUTOR_HOST"), GetExecutorPort()))
+}
+
+// resolvePath maps a path in the container filesystem (e.g., /app) to the
+// host filesystem, if the Executor runs in a directory set through the
+// EXECUTOR_ROOT environment variable instead of a container.
+func resolvePath(path string) string {
+	root := os.Getenv("EXECUTOR_ROOT")
+	if root == "" || path == "" {
+		return path
+	}
+	return filepath.Join(root, path)
+}
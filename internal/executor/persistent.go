@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// PERSISTENT_MODE is the value of EXECUTOR_MODE that enables the long-lived
+// process mode. In this mode, function processes are started on demand and
+// kept alive across invocations, so that the function code is loaded only
+// once. For each invocation, the Executor writes the InvocationRequest
+// (JSON-encoded, on a single line) to the standard input of an idle process,
+// and reads the InvocationResult (JSON-encoded, on a single line) from its
+// standard output. Each process serves one request at a time: a new process
+// is started when concurrent requests arrive and all the processes are busy.
+const PERSISTENT_MODE = "persistent"
+
+type persistentProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// idle processes, indexed by command
+var idleProcesses = make(map[string][]*persistentProcess)
+var idleProcessesMutex sync.Mutex
+
+func startPersistentProcess(cmd []string) (*persistentProcess, error) {
+	execCmd := exec.Command(cmd[0], cmd[1:]...)
+	execCmd.Stderr = os.Stderr
+	stdin, err := execCmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := execCmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := execCmd.Start(); err != nil {
+		return nil, err
+	}
+	log.Printf("Started persistent process: %s", strings.Join(cmd, " "))
+
+	return &persistentProcess{cmd: execCmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+// acquirePersistentProcess returns an idle process for a command, starting
+// a new one if needed.
+func acquirePersistentProcess(cmd []string) (*persistentProcess, error) {
+	key := strings.Join(cmd, " ")
+
+	idleProcessesMutex.Lock()
+	if procs := idleProcesses[key]; len(procs) > 0 {
+		p := procs[len(procs)-1]
+		idleProcesses[key] = procs[:len(procs)-1]
+		idleProcessesMutex.Unlock()
+		return p, nil
+	}
+	idleProcessesMutex.Unlock()
+
+	return startPersistentProcess(cmd)
+}
+
+func releasePersistentProcess(cmd []string, p *persistentProcess) {
+	key := strings.Join(cmd, " ")
+
+	idleProcessesMutex.Lock()
+	defer idleProcessesMutex.Unlock()
+	idleProcesses[key] = append(idleProcesses[key], p)
+}
+
+// kill terminates a process that is not working properly.
+func (p *persistentProcess) kill() {
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
+
+func invokePersistentProcess(cmd []string, req *InvocationRequest) (*InvocationResult, error) {
+	p, err := acquirePersistentProcess(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("could not start process: %v", err)
+	}
+
+	reqLine, err := json.Marshal(req)
+	if err != nil {
+		releasePersistentProcess(cmd, p)
+		return nil, err
+	}
+	reqLine = append(reqLine, '\n')
+	if _, err := p.stdin.Write(reqLine); err != nil {
+		p.kill()
+		return nil, fmt.Errorf("could not write request: %v", err)
+	}
+
+	respLine, err := p.stdout.ReadBytes('\n')
+	if err != nil {
+		p.kill()
+		return nil, fmt.Errorf("could not read result: %v", err)
+	}
+	releasePersistentProcess(cmd, p)
+
+	result := &InvocationResult{}
+	if err := json.Unmarshal(respLine, result); err != nil {
+		return nil, fmt.Errorf("invalid result: %v", err)
+	}
+	return result, nil
+}
@@ -1,19 +1,26 @@
 package executor
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"io/ioutil"
 )
 
-const resultFile = "/tmp/_executor_result.json"
-const paramsFile = "/tmp/_executor.params"
+// Names of the files used to exchange data with the function process. Files
+// are created in a per-request directory, so that multiple requests can be
+// served concurrently.
+const resultFileName = "_executor_result.json"
+const resultContentTypeFileName = "_executor_result.ctype"
+const paramsFileName = "_executor.params"
+const payloadFileName = "_executor.payload"
 
 func readExecutionResult(resultFile string) string {
 	content, err := ioutil.ReadFile(resultFile)
@@ -25,6 +32,17 @@ func readExecutionResult(resultFile string) string {
 	return string(content)
 }
 
+// readResultContentType returns the content type optionally written by the
+// function, or an empty string if the function did not write any.
+func readResultContentType(contentTypeFile string) string {
+	content, err := ioutil.ReadFile(contentTypeFile)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(content))
+}
+
 func InvokeHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse request
 	reqDecoder := json.NewDecoder(r.Body)
@@ -35,14 +53,50 @@ func InvokeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set environment variables
-	os.Setenv("RESULT_FILE", resultFile)
-	os.Setenv("HANDLER", req.Handler)
-	os.Setenv("HANDLER_DIR", req.HandlerDir)
+	cmd, err := getCommand(req)
+	if err != nil {
+		log.Printf("Invalid request!")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if os.Getenv("EXECUTOR_MODE") == PERSISTENT_MODE {
+		// the function process serves multiple invocations
+		resp, err := invokePersistentProcess(cmd, req)
+		if err != nil {
+			log.Printf("Invocation failed: %v", err)
+			resp = &InvocationResult{Success: false}
+		}
+		writeInvocationResult(w, resp)
+		return
+	}
+
+	// Prepare the files for this request
+	reqDir, err := os.MkdirTemp("", "invocation-")
+	if err != nil {
+		log.Printf("Could not create request directory: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(reqDir)
+
+	resultFile := filepath.Join(reqDir, resultFileName)
+	resultContentTypeFile := filepath.Join(reqDir, resultContentTypeFileName)
+
+	// Set environment variables (for the function process only)
+	env := append(os.Environ(),
+		"RESULT_FILE="+resultFile,
+		"RESULT_CONTENT_TYPE_FILE="+resultContentTypeFile,
+		"HANDLER="+req.Handler,
+		"HANDLER_DIR="+resolvePath(req.HandlerDir))
+	for k, v := range req.Env {
+		env = append(env, k+"="+v)
+	}
 	params := req.Params
 	if params == nil {
-		os.Setenv("PARAMS_FILE", "")
+		env = append(env, "PARAMS_FILE=")
 	} else {
+		paramsFile := filepath.Join(reqDir, paramsFileName)
 		paramsB, _ := json.Marshal(req.Params)
 		err := os.WriteFile(paramsFile, paramsB, 0644)
 		if err != nil {
@@ -50,38 +104,68 @@ func InvokeHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		os.Setenv("PARAMS_FILE", paramsFile)
+		env = append(env, "PARAMS_FILE="+paramsFile)
 	}
 
-	// Exec handler process
-	cmd := req.Command
-	if cmd == nil || len(cmd) < 1 {
-		// this request is either invalid or uses a custom runtime
-		// in the latter case, we find the command in the env
-		customCmd, ok := os.LookupEnv("CUSTOM_CMD")
-		if !ok {
-			log.Printf("Invalid request!")
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	// Raw payload (if any) is provided both as a file and on stdin
+	if req.Payload == nil {
+		env = append(env, "PAYLOAD_FILE=", "CONTENT_TYPE=")
+	} else {
+		payloadFile := filepath.Join(reqDir, payloadFileName)
+		err := os.WriteFile(payloadFile, req.Payload, 0644)
+		if err != nil {
+			log.Printf("Could not write payload to %s", payloadFile)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		cmd = strings.Split(customCmd, " ")
+		env = append(env, "PAYLOAD_FILE="+payloadFile, "CONTENT_TYPE="+req.ContentType)
 	}
 
+	// Exec handler process
 	var resp *InvocationResult
 	execCmd := exec.Command(cmd[0], cmd[1:]...)
+	execCmd.Env = env
+	if req.Payload != nil {
+		execCmd.Stdin = bytes.NewReader(req.Payload)
+	}
 	out, err := execCmd.CombinedOutput()
 	if err != nil {
 		log.Printf("cmd.Run() failed with %s\n", err)
 		fmt.Printf("Function output:\n%s\n", string(out)) // TODO: do something with output
 		resp = &InvocationResult{Success: false}
+	} else if contentType := readResultContentType(resultContentTypeFile); contentType != "" {
+		// the function chose to return binary output
+		rawResult, _ := ioutil.ReadFile(resultFile)
+		resp = &InvocationResult{Success: true, RawResult: rawResult, ContentType: contentType}
+		fmt.Printf("Function output:\n%s\n", string(out)) // TODO: do something with output
 	} else {
 		result := readExecutionResult(resultFile)
 
-		resp = &InvocationResult{true, result}
+		resp = &InvocationResult{Success: true, Result: result}
 		fmt.Printf("Function output:\n%s\n", string(out)) // TODO: do something with output
 	}
 
+	writeInvocationResult(w, resp)
+}
+
+// getCommand returns the command to run to serve a request.
+func getCommand(req *InvocationRequest) ([]string, error) {
+	cmd := req.Command
+	if cmd == nil || len(cmd) < 1 {
+		// this request is either invalid or uses a custom runtime
+		// in the latter case, we find the command in the env
+		customCmd, ok := os.LookupEnv("CUSTOM_CMD")
+		if !ok {
+			return nil, fmt.Errorf("no command specified")
+		}
+
+		cmd = strings.Split(customCmd, " ")
+	}
+
+	return cmd, nil
+}
+
+func writeInvocationResult(w http.ResponseWriter, resp *InvocationResult) {
 	w.Header().Set("Content-Type", "application/json")
 	respBody, _ := json.Marshal(resp)
 	w.Write(respBody)
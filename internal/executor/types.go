@@ -5,9 +5,20 @@ type InvocationRequest struct {
 	Params     map[string]interface{}
 	Handler    string
 	HandlerDir string
+	// Payload carries raw (non-JSON) input, if any, whose content type is
+	// given by ContentType.
+	Payload     []byte
+	ContentType string
+	// Env contains environment variables for this invocation only (e.g.,
+	// the trace context, as TRACEPARENT and TRACESTATE).
+	Env map[string]string `json:",omitempty"`
 }
 
 type InvocationResult struct {
 	Success bool
 	Result  string
+	// RawResult is set instead of Result when the function returns binary
+	// output, labeled with ContentType.
+	RawResult   []byte
+	ContentType string
 }
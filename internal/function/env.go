@@ -0,0 +1,69 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var InvalidEnvErr = errors.New("invalid environment variable")
+
+// reservedEnv contains variables set by the Executors, which cannot be
+// overridden by functions. Variables starting with EXECUTOR_ are reserved
+// as well.
+var reservedEnv = map[string]bool{
+	"HANDLER":                  true,
+	"HANDLER_DIR":              true,
+	"PARAMS_FILE":              true,
+	"PAYLOAD_FILE":             true,
+	"CONTENT_TYPE":             true,
+	"RESULT_FILE":              true,
+	"RESULT_CONTENT_TYPE_FILE": true,
+	"CONTEXT":                  true,
+}
+
+// ValidateEnvName checks that an environment variable can be set for a
+// function.
+func ValidateEnvName(name string) error {
+	if !validEnvName.MatchString(name) {
+		return fmt.Errorf("%w: bad name '%s'", InvalidEnvErr, name)
+	}
+	if reservedEnv[name] || strings.HasPrefix(name, "EXECUTOR_") {
+		return fmt.Errorf("%w: '%s' is reserved", InvalidEnvErr, name)
+	}
+	return nil
+}
+
+// ValidateEnv checks the environment variables of the function, including
+// those holding its secrets.
+func (f *Function) ValidateEnv() error {
+	for name, value := range f.Env {
+		if err := ValidateEnvName(name); err != nil {
+			return err
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("%w: bad value for '%s'", InvalidEnvErr, name)
+		}
+	}
+	for _, ref := range f.Secrets {
+		if err := ValidateEnvName(ref.EnvName()); err != nil {
+			return err
+		}
+		if _, ok := f.Env[ref.EnvName()]; ok {
+			return fmt.Errorf("%w: '%s' is also used for secret '%s'", InvalidEnvErr, ref.EnvName(), ref.Name)
+		}
+	}
+	return nil
+}
+
+// EnvList returns the environment variables of the function as a sorted
+// list of "NAME=value" entries.
+func (f *Function) EnvList() []string {
+	env := make([]string, 0, len(f.Env))
+	for name, value := range f.Env {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
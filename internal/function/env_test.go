@@ -0,0 +1,27 @@
+package function
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	f := &Function{Name: "f", Env: map[string]string{"B": "2", "A": "1"}}
+	if err := f.ValidateEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if env := f.EnvList(); len(env) != 2 || env[0] != "A=1" || env[1] != "B=2" {
+		t.Errorf("unexpected environment: %v", env)
+	}
+
+	for _, name := range []string{"", "1A", "A-B", "HANDLER", "EXECUTOR_PORT"} {
+		if err := ValidateEnvName(name); !errors.Is(err, InvalidEnvErr) {
+			t.Errorf("invalid variable accepted: '%s'", name)
+		}
+	}
+
+	f.Secrets = []SecretRef{{Name: "a"}}
+	if err := f.ValidateEnv(); !errors.Is(err, InvalidEnvErr) {
+		t.Errorf("variable used for both a value and a secret: %v", err)
+	}
+}
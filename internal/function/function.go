@@ -3,11 +3,11 @@ package function
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/grussorusso/serverledge/internal/cache"
 	"github.com/grussorusso/serverledge/utils"
-	clientv3 "go.etcd.io/etcd/client/v3"
 	"golang.org/x/net/context"
 )
 
@@ -20,6 +20,24 @@ type Function struct {
 	Handler         string  // example: "module.function_name"
 	TarFunctionCode string  // input is .tar
 	CustomImage     string  // used if custom runtime is chosen
+	// MaxConcurrencyPerInstance is the max. number of concurrent invocations
+	// served by a single container (default: 1).
+	MaxConcurrencyPerInstance int
+	Owner                     string // user or team charged for invocations
+	// Secrets exposed to the function as environment variables
+	Secrets []SecretRef `json:",omitempty"`
+	// Env contains environment variables set in the containers of the
+	// function
+	Env map[string]string `json:",omitempty"`
+	// Version is incremented every time the function is updated
+	Version int64 `json:",omitempty"`
+	// Isolation overrides the default hardening of the containers
+	Isolation *Isolation `json:",omitempty"`
+	// CodeDigest is the digest of TarFunctionCode (e.g., "sha256:<hex>")
+	CodeDigest string `json:",omitempty"`
+	// CodeSignature is the base64-encoded ed25519 signature of
+	// TarFunctionCode
+	CodeSignature string `json:",omitempty"`
 }
 
 func (f Function) getEtcdKey() string {
@@ -32,6 +50,7 @@ func getEtcdKey(funcName string) string {
 
 //GetFunction retrieves a Function given its name.
 func GetFunction(name string) (*Function, bool) {
+	name = CanonicalName(name)
 
 	val, found := getFromCache(name)
 	if !found {
@@ -49,6 +68,21 @@ func GetFunction(name string) (*Function, bool) {
 
 }
 
+// GetLatestFunction retrieves the latest definition of a Function from Etcd,
+// bypassing the cache (e.g., before updating it).
+func GetLatestFunction(name string) (*Function, bool) {
+	return getFromEtcd(CanonicalName(name))
+}
+
+// GetMaxConcurrencyPerInstance returns the max. number of concurrent
+// invocations that can be served by a container.
+func (f *Function) GetMaxConcurrencyPerInstance() int {
+	if f.MaxConcurrencyPerInstance < 1 {
+		return 1
+	}
+	return f.MaxConcurrencyPerInstance
+}
+
 func (f *Function) String() string {
 	return f.Name
 }
@@ -67,18 +101,18 @@ func getFromCache(name string) (*Function, bool) {
 }
 
 func getFromEtcd(name string) (*Function, bool) {
-	cli, err := utils.GetEtcdClient()
+	store, err := utils.GetKVStore()
 	if err != nil {
 		return nil, false
 	}
 	ctx, _ := context.WithTimeout(context.Background(), 1*time.Second)
-	getResponse, err := cli.Get(ctx, getEtcdKey(name))
-	if err != nil || len(getResponse.Kvs) < 1 {
+	value, found, err := store.Get(ctx, getEtcdKey(name))
+	if err != nil || !found {
 		return nil, false
 	}
 
 	var f Function
-	err = json.Unmarshal(getResponse.Kvs[0].Value, &f)
+	err = json.Unmarshal(value, &f)
 	if err != nil {
 		return nil, false
 	}
@@ -87,7 +121,7 @@ func getFromEtcd(name string) (*Function, bool) {
 }
 
 func (f *Function) SaveToEtcd() error {
-	cli, err := utils.GetEtcdClient()
+	store, err := utils.GetKVStore()
 	if err != nil {
 		return err
 	}
@@ -97,7 +131,7 @@ func (f *Function) SaveToEtcd() error {
 	if err != nil {
 		return fmt.Errorf("Could not marshal function: %v", err)
 	}
-	_, err = cli.Put(ctx, f.getEtcdKey(), string(payload))
+	err = store.Put(ctx, f.getEtcdKey(), payload, 0)
 	if err != nil {
 		return fmt.Errorf("Failed Put: %v", err)
 	}
@@ -110,14 +144,14 @@ func (f *Function) SaveToEtcd() error {
 
 // Delete removes a function from Etcd and the local cache.
 func (f *Function) Delete() error {
-	cli, err := utils.GetEtcdClient()
+	store, err := utils.GetKVStore()
 	if err != nil {
 		return err
 	}
 	ctx := context.TODO()
 
-	dresp, err := cli.Delete(ctx, f.getEtcdKey())
-	if err != nil || dresp.Deleted != 1 {
+	deleted, err := store.Delete(ctx, f.getEtcdKey())
+	if err != nil || !deleted {
 		return fmt.Errorf("Failed Delete: %v", err)
 	}
 
@@ -128,21 +162,22 @@ func (f *Function) Delete() error {
 }
 
 func GetAll() ([]string, error) {
-	cli, err := utils.GetEtcdClient()
+	store, err := utils.GetKVStore()
 	if err != nil {
 		return nil, err
 	}
 	ctx := context.TODO()
 
-	resp, err := cli.Get(ctx, "/function", clientv3.WithPrefix())
+	values, err := store.GetWithPrefix(ctx, "/function")
 	if err != nil {
 		return nil, err
 	}
 
-	functions := make([]string, len(resp.Kvs))
-	for i, s := range resp.Kvs {
-		functions[i] = string(s.Key)[len("/function/"):]
+	functions := make([]string, 0, len(values))
+	for key := range values {
+		functions = append(functions, key[len("/function/"):])
 	}
+	sort.Strings(functions)
 
 	return functions, nil
 }
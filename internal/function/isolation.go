@@ -0,0 +1,58 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Isolation overrides the hardening applied by default to the containers of
+// a function, e.g., for functions that need to write to their filesystem or
+// to run as root.
+type Isolation struct {
+	WritableRootfs bool     `json:",omitempty"` // does not mount the root filesystem read-only
+	ImageUser      bool     `json:",omitempty"` // runs as the user of the image (possibly root)
+	Capabilities   []string `json:",omitempty"` // Linux capabilities kept, e.g., NET_RAW
+	PidsLimit      int64    `json:",omitempty"` // max. number of processes (0 = node default, -1 = unlimited)
+	Unconfined     bool     `json:",omitempty"` // disables seccomp
+	GVisor         bool     `json:",omitempty"` // runs the containers with gVisor (runsc)
+	// Network is the network mode of the containers (default: node
+	// configuration)
+	Network string `json:",omitempty"`
+}
+
+// Network modes of function containers
+const (
+	NETWORK_NONE     = "none"     // the containers can only be reached by the node
+	NETWORK_INTERNAL = "internal" // the containers can reach other functions and the node
+	NETWORK_FULL     = "full"     // unrestricted network access
+)
+
+var InvalidIsolationErr = errors.New("invalid isolation settings")
+
+var validCapability = regexp.MustCompile(`^(CAP_)?[A-Z][A-Z_]*$`)
+
+// Validate checks the capabilities, the pids limit and the network mode.
+func (i *Isolation) Validate() error {
+	for _, c := range i.Capabilities {
+		if !validCapability.MatchString(c) {
+			return fmt.Errorf("%w: bad capability '%s'", InvalidIsolationErr, c)
+		}
+	}
+	if i.PidsLimit < -1 {
+		return fmt.Errorf("%w: bad pids limit %d", InvalidIsolationErr, i.PidsLimit)
+	}
+	switch i.Network {
+	case "", NETWORK_NONE, NETWORK_INTERNAL, NETWORK_FULL:
+	default:
+		return fmt.Errorf("%w: unknown network mode '%s'", InvalidIsolationErr, i.Network)
+	}
+	return nil
+}
+
+// Relaxes returns true if the settings weaken the default hardening, with
+// the exception of the pids limit and of the network mode, which depend on
+// the node configuration.
+func (i *Isolation) Relaxes() bool {
+	return i.WritableRootfs || i.ImageUser || len(i.Capabilities) > 0 || i.Unconfined || i.PidsLimit < 0
+}
@@ -0,0 +1,52 @@
+package function
+
+import (
+	"errors"
+	"strings"
+)
+
+// DEFAULT_NAMESPACE contains the functions whose name is not qualified by a
+// namespace.
+const DEFAULT_NAMESPACE = "default"
+
+var InvalidNameErr = errors.New("invalid name: expected [<namespace>/]<name>")
+
+// SplitName splits a name in the form "[<namespace>/]<name>" (e.g., a
+// function name or a request ID) into its namespace and base name.
+func SplitName(name string) (namespace string, baseName string) {
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return DEFAULT_NAMESPACE, name
+}
+
+// QualifiedName joins a namespace and a base name. Names in the default
+// namespace are not qualified, so that they are the same as before
+// namespaces were introduced.
+func QualifiedName(namespace string, baseName string) string {
+	if namespace == "" || namespace == DEFAULT_NAMESPACE {
+		return baseName
+	}
+	return namespace + "/" + baseName
+}
+
+// CanonicalName returns the name used to identify a function (e.g.,
+// "default/f" becomes "f").
+func CanonicalName(name string) string {
+	return QualifiedName(SplitName(name))
+}
+
+// ValidateName checks that a name is in the form "[<namespace>/]<name>".
+func ValidateName(name string) error {
+	namespace, baseName := SplitName(name)
+	if namespace == "" || baseName == "" || strings.ContainsRune(baseName, '/') {
+		return InvalidNameErr
+	}
+	return nil
+}
+
+// Namespace returns the namespace the function belongs to.
+func (f *Function) Namespace() string {
+	namespace, _ := SplitName(f.Name)
+	return namespace
+}
@@ -0,0 +1,17 @@
+package function
+
+import "testing"
+
+func TestNames(t *testing.T) {
+	for name, expected := range map[string]string{"f": "f", "default/f": "f", "team-a/f": "team-a/f"} {
+		if canonical := CanonicalName(name); canonical != expected {
+			t.Errorf("canonical name of %s: %s, expected %s", name, canonical, expected)
+		}
+	}
+
+	for _, name := range []string{"", "/f", "team-a/", "team-a/f/g"} {
+		if ValidateName(name) == nil {
+			t.Errorf("invalid name accepted: '%s'", name)
+		}
+	}
+}
@@ -1,20 +1,29 @@
 package function
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"time"
+
+	"github.com/grussorusso/serverledge/internal/logging"
 )
 
 //Request represents a single function invocation.
 type Request struct {
-	ReqId      string
-	Fun        *Function
-	Params     map[string]interface{}
-	Arrival    time.Time
-	ExecReport ExecutionReport
+	ReqId       string
+	Fun         *Function
+	Params      map[string]interface{}
+	Payload     []byte // raw (non-JSON) input, if any
+	ContentType string // content type of Payload
+	Arrival     time.Time
+	ExecReport  ExecutionReport
 	RequestQoS
 	CanDoOffloading bool
 	Async           bool
+	Ctx             context.Context // carries the tracing span of the request
+	Offloaded       bool            // the request was offloaded by another node
+	logger          *slog.Logger
 }
 
 type RequestQoS struct {
@@ -24,12 +33,19 @@ type RequestQoS struct {
 
 type ExecutionReport struct {
 	Result         string
+	RawResult      []byte `json:",omitempty"` // binary result, if the function chose a ContentType
+	ContentType    string `json:",omitempty"`
 	ResponseTime   float64
 	IsWarmStart    bool
+	Restored       bool `json:",omitempty"` // cold start served by restoring a snapshot
 	InitTime       float64
 	OffloadLatency float64
 	Duration       float64
 	SchedAction    string
+	// Resource usage, if measured
+	CPUSeconds   float64 `json:",omitempty"`
+	PeakMemoryMB float64 `json:",omitempty"`
+	GBSeconds    float64 `json:",omitempty"`
 }
 
 type Response struct {
@@ -42,7 +58,21 @@ type AsyncResponse struct {
 }
 
 func (r *Request) String() string {
-	return fmt.Sprintf("Rq-%s", r.Fun.Name, r.ReqId)
+	return fmt.Sprintf("Rq-%s", r.ReqId)
+}
+
+// SetLogger creates the logger of r, which tags messages with the ID and the
+// function of the request. It must be called whenever they are set.
+func (r *Request) SetLogger() {
+	r.logger = logging.ForRequest(r.ReqId, r.Fun.Name)
+}
+
+// Logger returns the logger of r.
+func (r *Request) Logger() *slog.Logger {
+	if r.logger == nil {
+		return logging.ForRequest(r.ReqId, r.Fun.Name)
+	}
+	return r.logger
 }
 
 type ServiceClass int64
@@ -52,3 +82,14 @@ const (
 	HIGH_PERFORMANCE               = 1
 	HIGH_AVAILABILITY              = 2
 )
+
+func (c ServiceClass) String() string {
+	switch c {
+	case HIGH_PERFORMANCE:
+		return "performance"
+	case HIGH_AVAILABILITY:
+		return "availability"
+	default:
+		return "low"
+	}
+}
@@ -0,0 +1,35 @@
+package function
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/grussorusso/serverledge/internal/logging"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	r := &Request{ReqId: "req-1", Fun: &Function{Name: "fib"}}
+	r.SetLogger()
+	if r.Logger() != r.Logger() {
+		t.Errorf("logger created on every call")
+	}
+
+	// pooled requests are reused for other invocations
+	r.ReqId = "req-2"
+	r.SetLogger()
+	r.Logger().Info("New invocation request")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a single JSON line, got %q", buf.String())
+	}
+	if line[logging.REQUEST_ID_KEY] != "req-2" || line[logging.FUNCTION_KEY] != "fib" {
+		t.Errorf("unexpected request attributes: %v", line)
+	}
+}
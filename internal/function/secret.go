@@ -0,0 +1,50 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A SecretRef exposes a secret (stored encrypted in the secrets store) to a
+// function. Only the reference is part of the function definition.
+type SecretRef struct {
+	Name string // secret in the namespace of the function
+	Env  string `json:",omitempty"` // environment variable holding the value (default: derived from Name)
+}
+
+var InvalidSecretErr = errors.New("invalid secret")
+
+var validSecretName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,128}$`)
+var validEnvName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+var invalidEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// ValidateSecretName checks that a secret name only contains letters,
+// digits, '_', '.' and '-'.
+func ValidateSecretName(name string) error {
+	if !validSecretName.MatchString(name) {
+		return fmt.Errorf("%w: bad name '%s'", InvalidSecretErr, name)
+	}
+	return nil
+}
+
+// EnvName returns the environment variable holding the secret, e.g.,
+// DB_PASSWORD for the secret "db-password".
+func (r SecretRef) EnvName() string {
+	if r.Env != "" {
+		return r.Env
+	}
+	return invalidEnvChars.ReplaceAllString(strings.ToUpper(r.Name), "_")
+}
+
+// Validate checks the secret name and the environment variable.
+func (r SecretRef) Validate() error {
+	if err := ValidateSecretName(r.Name); err != nil {
+		return err
+	}
+	if !validEnvName.MatchString(r.EnvName()) {
+		return fmt.Errorf("%w: bad environment variable '%s' for secret '%s'", InvalidSecretErr, r.EnvName(), r.Name)
+	}
+	return nil
+}
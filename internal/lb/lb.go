@@ -10,6 +10,7 @@ import (
 
 	"github.com/grussorusso/serverledge/internal/config"
 	"github.com/grussorusso/serverledge/internal/registration"
+	"github.com/grussorusso/serverledge/utils"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -30,12 +31,31 @@ func StartReverseProxy(e *echo.Echo, region string) {
 	log.Printf("Initializing with %d targets.", len(targets))
 	balancer := newBalancer(targets)
 	currentTargets = targets
-	e.Use(middleware.Proxy(balancer))
+	proxyConfig := middleware.ProxyConfig{Balancer: balancer}
+	if utils.TLSEnabled() {
+		// the node certificate is not presented to the targets, as proxied
+		// requests come from clients and not from nodes
+		tlsConfig, err := utils.NewClientTLSConfig("", "", config.GetString(config.TLS_CA, ""))
+		if err != nil {
+			log.Fatalf("Invalid TLS configuration: %v", err)
+		}
+		proxyConfig.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+	e.Use(middleware.ProxyWithConfig(proxyConfig))
 
 	go updateTargets(balancer, region)
 
 	portNumber := config.GetInt(config.API_PORT, 1323)
-	if err := e.Start(fmt.Sprintf(":%d", portNumber)); err != nil && err != http.ErrServerClosed {
+	if utils.TLSEnabled() {
+		e.TLSServer.Addr = fmt.Sprintf(":%d", portNumber)
+		if e.TLSServer.TLSConfig, err = utils.ServerTLSConfig(); err != nil {
+			log.Fatalf("Invalid TLS configuration: %v", err)
+		}
+		err = e.StartServer(e.TLSServer)
+	} else {
+		err = e.Start(fmt.Sprintf(":%d", portNumber))
+	}
+	if err != nil && err != http.ErrServerClosed {
 		e.Logger.Fatal("shutting down the server")
 	}
 }
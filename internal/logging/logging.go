@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/grussorusso/serverledge/internal/config"
+)
+
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+)
+
+// Attribute keys used to correlate log lines about the same request
+const (
+	REQUEST_ID_KEY = "reqId"
+	FUNCTION_KEY   = "function"
+)
+
+// level can be changed at runtime, without replacing the logger
+var level = new(slog.LevelVar)
+
+// Init configures the default logger according to the configuration.
+// Messages logged through the standard "log" package are emitted by the same
+// logger, at INFO level.
+func Init() error {
+	lvl, err := ParseLevel(config.GetString(config.LOGGING_LEVEL, "info"))
+	if err != nil {
+		return err
+	}
+	level.Set(lvl)
+
+	handler, err := newHandler(config.GetString(config.LOGGING_FORMAT, FORMAT_TEXT), os.Stderr)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func newHandler(format string, w io.Writer) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case FORMAT_TEXT:
+		return slog.NewTextHandler(w, opts), nil
+	case FORMAT_JSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) to a slog.Level.
+func ParseLevel(name string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(name)); err != nil {
+		return lvl, fmt.Errorf("unknown log level: %s", name)
+	}
+	return lvl, nil
+}
+
+// SetLevel changes the minimum level of logged messages.
+func SetLevel(name string) error {
+	lvl, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(lvl)
+	slog.Info("Log level changed", "level", lvl.String())
+	return nil
+}
+
+// GetLevel returns the name of the current minimum level.
+func GetLevel() string {
+	return level.Level().String()
+}
+
+// ForRequest returns a logger that tags every message with the request ID and
+// the function name.
+func ForRequest(reqId string, funcName string) *slog.Logger {
+	return slog.With(REQUEST_ID_KEY, reqId, FUNCTION_KEY, funcName)
+}
+
+// Fatal logs an error message and terminates the process.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestSetLevel(t *testing.T) {
+	defer level.Set(slog.LevelInfo)
+
+	if err := SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	if GetLevel() != "DEBUG" {
+		t.Errorf("unexpected level: %s", GetLevel())
+	}
+
+	if err := SetLevel("verbose"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if GetLevel() != "DEBUG" {
+		t.Errorf("level changed after invalid update: %s", GetLevel())
+	}
+}
+
+func TestRequestAttributes(t *testing.T) {
+	defer level.Set(slog.LevelInfo)
+	defer slog.SetDefault(slog.Default())
+
+	var buf bytes.Buffer
+	handler, err := newHandler(FORMAT_JSON, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.SetDefault(slog.New(handler))
+	level.Set(slog.LevelWarn)
+
+	ForRequest("req-1", "fib").Info("filtered")
+	ForRequest("req-1", "fib").Warn("Cold start failed")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a single JSON line, got %q", buf.String())
+	}
+	if line[REQUEST_ID_KEY] != "req-1" || line[FUNCTION_KEY] != "fib" {
+		t.Errorf("missing request attributes: %v", line)
+	}
+}
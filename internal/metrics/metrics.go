@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"fmt"
 	"log"
 
 	"net/http"
@@ -31,8 +32,16 @@ func Init() {
 
 	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
 		EnableOpenMetrics: true})
-	http.Handle("/metrics", handler)
-	http.ListenAndServe(":2112", nil)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	host := config.GetString(config.METRICS_PROMETHEUS_HOST, "")
+	port := config.GetInt(config.METRICS_PROMETHEUS_PORT, 2112)
+	addr := fmt.Sprintf("%s:%d", host, port)
+	log.Printf("Exposing metrics at %s/metrics\n", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Metrics server stopped: %v", err)
+	}
 }
 
 // Global metrics
@@ -47,9 +56,45 @@ var (
 		Buckets: durationBuckets,
 	},
 		[]string{"node", "function"})
+	Starts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sedge_starts_total",
+		Help: "The total number of invocations served locally, by start type (cold or warm)",
+	}, []string{"node", "function", "start"})
+	InitTimes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sedge_inittime",
+		Help:    "Time from arrival to the start of the execution, by start type (cold or warm)",
+		Buckets: initTimeBuckets,
+	},
+		[]string{"node", "function", "start"})
+	ResponseTimes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sedge_responsetime",
+		Help:    "Response time of completed requests, by QoS class",
+		Buckets: responseTimeBuckets,
+	},
+		[]string{"node", "class"})
+	QueueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sedge_queue_length",
+		Help: "Number of requests in the scheduler queue",
+	}, []string{"node"})
+	QueueWaitTimes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sedge_queue_wait",
+		Help:    "Time spent by requests in the scheduler queue",
+		Buckets: responseTimeBuckets,
+	},
+		[]string{"node"})
+	DroppedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sedge_dropped_total",
+		Help: "The total number of dropped requests, by reason",
+	}, []string{"node", "function", "reason"})
+	OffloadedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sedge_offloaded_total",
+		Help: "The total number of offloaded requests, by target node",
+	}, []string{"node", "function", "target"})
 )
 
 var durationBuckets = []float64{0.002, 0.005, 0.010, 0.02, 0.03, 0.05, 0.1, 0.15, 0.3, 0.6, 1.0}
+var initTimeBuckets = []float64{0.001, 0.005, 0.010, 0.05, 0.1, 0.25, 0.5, 1.0, 2.0, 5.0, 10.0}
+var responseTimeBuckets = []float64{0.005, 0.010, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0}
 
 func AddCompletedInvocation(funcName string) {
 	CompletedInvocations.With(prometheus.Labels{"function": funcName, "node": nodeIdentifier}).Inc()
@@ -58,7 +103,91 @@ func AddFunctionDurationValue(funcName string, duration float64) {
 	ExecutionTimes.With(prometheus.Labels{"function": funcName, "node": nodeIdentifier}).Observe(duration)
 }
 
+func startType(warmStart bool) string {
+	if warmStart {
+		return "warm"
+	}
+	return "cold"
+}
+
+// AddStart records a cold or warm start, along with the initialization time.
+func AddStart(funcName string, warmStart bool, initTime float64) {
+	labels := prometheus.Labels{"function": funcName, "node": nodeIdentifier, "start": startType(warmStart)}
+	Starts.With(labels).Inc()
+	InitTimes.With(labels).Observe(initTime)
+}
+
+func AddResponseTime(class string, responseTime float64) {
+	ResponseTimes.With(prometheus.Labels{"class": class, "node": nodeIdentifier}).Observe(responseTime)
+}
+
+func SetQueueLength(length int) {
+	QueueLength.With(prometheus.Labels{"node": nodeIdentifier}).Set(float64(length))
+}
+
+func AddQueueWait(wait float64) {
+	QueueWaitTimes.With(prometheus.Labels{"node": nodeIdentifier}).Observe(wait)
+}
+
+func AddDroppedRequest(funcName string, reason string) {
+	DroppedRequests.With(prometheus.Labels{"function": funcName, "node": nodeIdentifier, "reason": reason}).Inc()
+}
+
+func AddOffloadedRequest(funcName string, target string) {
+	OffloadedRequests.With(prometheus.Labels{"function": funcName, "node": nodeIdentifier, "target": target}).Inc()
+}
+
+// nodeCollector exposes the status of the node resources and of the
+// container pools, read at each scrape.
+type nodeCollector struct {
+	containers *prometheus.Desc
+	cpus       *prometheus.Desc
+	memory     *prometheus.Desc
+}
+
+func newNodeCollector() *nodeCollector {
+	return &nodeCollector{
+		containers: prometheus.NewDesc("sedge_containers",
+			"Number of containers for each function, by state (warm or busy)",
+			[]string{"node", "function", "state"}, nil),
+		cpus: prometheus.NewDesc("sedge_available_cpus",
+			"CPUs available for function execution",
+			[]string{"node"}, nil),
+		memory: prometheus.NewDesc("sedge_available_memory_mb",
+			"Memory (MB) available for new containers",
+			[]string{"node"}, nil),
+	}
+}
+
+func (c *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.containers
+	ch <- c.cpus
+	ch <- c.memory
+}
+
+func (c *nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	for funcName, status := range node.ContainerStatus() {
+		ch <- prometheus.MustNewConstMetric(c.containers, prometheus.GaugeValue, float64(status.Warm), nodeIdentifier, funcName, "warm")
+		ch <- prometheus.MustNewConstMetric(c.containers, prometheus.GaugeValue, float64(status.Busy), nodeIdentifier, funcName, "busy")
+	}
+
+	node.Resources.RLock()
+	cpus := node.Resources.AvailableCPUs
+	memory := node.Resources.AvailableMemMB
+	node.Resources.RUnlock()
+	ch <- prometheus.MustNewConstMetric(c.cpus, prometheus.GaugeValue, cpus, nodeIdentifier)
+	ch <- prometheus.MustNewConstMetric(c.memory, prometheus.GaugeValue, float64(memory), nodeIdentifier)
+}
+
 func registerGlobalMetrics() {
 	registry.MustRegister(CompletedInvocations)
 	registry.MustRegister(ExecutionTimes)
+	registry.MustRegister(Starts)
+	registry.MustRegister(InitTimes)
+	registry.MustRegister(ResponseTimes)
+	registry.MustRegister(QueueLength)
+	registry.MustRegister(QueueWaitTimes)
+	registry.MustRegister(DroppedRequests)
+	registry.MustRegister(OffloadedRequests)
+	registry.MustRegister(newNodeCollector())
 }
@@ -0,0 +1,48 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+// A Clock provides the current time to the container pool (e.g., to check
+// for expired containers). It can be replaced in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var clock Clock = systemClock{}
+
+// SetClock replaces the clock used by the node.
+func SetClock(c Clock) {
+	clock = c
+}
+
+// ManualClock is a Clock that only moves forward when explicitly advanced.
+type ManualClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -4,29 +4,56 @@ import (
 	"container/list"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/grussorusso/serverledge/internal/codesign"
 	"github.com/grussorusso/serverledge/internal/config"
 	"github.com/grussorusso/serverledge/internal/container"
 	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/secrets"
 )
 
 type ContainerPool struct {
-	busy  *list.List // list of ContainerID
+	busy  *list.List // list of *busyContainer
 	ready *list.List // list of warmContainer
+	// max. number of concurrent requests served by a container
+	maxConcurrency int
+	// version of the function definition used by the containers
+	version int64
 }
 
 type warmContainer struct {
 	Expiration int64
 	contID     container.ContainerID
+	cpuDemand  float64 // CPU demand of the function when the container was created
+}
+
+// busyContainer is a container serving at least one request.
+type busyContainer struct {
+	contID    container.ContainerID
+	inFlight  int     // number of requests being served
+	stale     bool    // created for an older version of the function
+	restored  bool    // restored from a snapshot and not yet released
+	cpuDemand float64 // CPU acquired for the container, released when idle
 }
 
 var NoWarmFoundErr = errors.New("no warm container is available")
 
 // getFunctionPool retrieves (or creates) the container pool for a function.
+// If the function has been updated, containers created for the previous
+// version are not used anymore.
 func getFunctionPool(f *function.Function) *ContainerPool {
 	if fp, ok := Resources.ContainerPools[f.Name]; ok {
+		if f.Version > fp.version {
+			slog.Info("Function updated, dismissing containers", "function", f.Name, "version", f.Version)
+			fp.version = f.Version
+			fp.maxConcurrency = f.GetMaxConcurrencyPerInstance()
+			for elem := fp.busy.Front(); elem != nil; elem = elem.Next() {
+				elem.Value.(*busyContainer).stale = true
+			}
+			destroyContainers(fp.drainReadyContainers())
+		}
 		return fp
 	}
 
@@ -35,6 +62,24 @@ func getFunctionPool(f *function.Function) *ContainerPool {
 	return fp
 }
 
+// getSharedContainer returns a busy container that can serve an additional
+// request (if any), incrementing its in-flight count.
+func (fp *ContainerPool) getSharedContainer() (container.ContainerID, bool) {
+	if fp.maxConcurrency <= 1 {
+		return "", false
+	}
+
+	for elem := fp.busy.Front(); elem != nil; elem = elem.Next() {
+		busy := elem.Value.(*busyContainer)
+		if !busy.stale && busy.inFlight < fp.maxConcurrency {
+			busy.inFlight++
+			return busy.contID, true
+		}
+	}
+
+	return "", false
+}
+
 func (fp *ContainerPool) getWarmContainer() (container.ContainerID, bool) {
 	// TODO: picking most-recent / least-recent container might be better?
 	elem := fp.ready.Front()
@@ -43,27 +88,53 @@ func (fp *ContainerPool) getWarmContainer() (container.ContainerID, bool) {
 	}
 
 	fp.ready.Remove(elem)
-	contID := elem.Value.(warmContainer).contID
-	fp.putBusyContainer(contID)
+	warmed := elem.Value.(warmContainer)
+	fp.putBusyContainer(warmed.contID, fp.version, false, warmed.cpuDemand)
 
-	return contID, true
+	return warmed.contID, true
 }
 
-func (fp *ContainerPool) putBusyContainer(contID container.ContainerID) {
-	fp.busy.PushBack(contID)
+func (fp *ContainerPool) putBusyContainer(contID container.ContainerID, version int64, restored bool, cpuDemand float64) {
+	fp.busy.PushBack(&busyContainer{contID: contID, inFlight: 1, stale: version < fp.version, restored: restored, cpuDemand: cpuDemand})
 }
 
-func (fp *ContainerPool) putReadyContainer(contID container.ContainerID, expiration int64) {
+func (fp *ContainerPool) putReadyContainer(contID container.ContainerID, expiration int64, cpuDemand float64) {
 	fp.ready.PushBack(warmContainer{
 		contID:     contID,
 		Expiration: expiration,
+		cpuDemand:  cpuDemand,
 	})
 }
 
+// findBusyContainer returns the element of the busy list associated with a
+// container (if any).
+func (fp *ContainerPool) findBusyContainer(contID container.ContainerID) *list.Element {
+	for elem := fp.busy.Front(); elem != nil; elem = elem.Next() {
+		if elem.Value.(*busyContainer).contID == contID {
+			return elem
+		}
+	}
+	return nil
+}
+
+// availableSlots returns the number of additional requests the pool can serve
+// without starting new containers. Stale containers do not accept requests.
+func (fp *ContainerPool) availableSlots() int {
+	slots := fp.ready.Len() * fp.maxConcurrency
+	for elem := fp.busy.Front(); elem != nil; elem = elem.Next() {
+		if busy := elem.Value.(*busyContainer); !busy.stale {
+			slots += fp.maxConcurrency - busy.inFlight
+		}
+	}
+	return slots
+}
+
 func newFunctionPool(f *function.Function) *ContainerPool {
 	fp := &ContainerPool{}
 	fp.busy = list.New()
 	fp.ready = list.New()
+	fp.maxConcurrency = f.GetMaxConcurrencyPerInstance()
+	fp.version = f.Version
 
 	return fp
 }
@@ -108,6 +179,8 @@ func releaseResources(cpuDemand float64, memDemand int64) {
 // AcquireWarmContainer acquires a warm container for a given function (if any).
 // A warm container is in running/paused state and has already been initialized
 // with the function code.
+// If the function allows concurrent invocations within a container, a busy
+// container may be returned as well.
 // The acquired container is already in the busy pool.
 // The function returns an error if either:
 // (i) the warm container does not exist
@@ -117,46 +190,67 @@ func AcquireWarmContainer(f *function.Function) (container.ContainerID, error) {
 	defer Resources.Unlock()
 
 	fp := getFunctionPool(f)
-	contID, found := fp.getWarmContainer()
-	if !found {
+	if contID, found := fp.getSharedContainer(); found {
+		// resources have been already acquired for this container
+		return contID, nil
+	}
+
+	elem := fp.ready.Front()
+	if elem == nil {
 		return "", NoWarmFoundErr
 	}
 
-	if !acquireResources(f.CPUDemand, 0, false) {
-		//log.Printf("Not enough CPU to start a warm container for %s", f)
+	if !acquireResources(elem.Value.(warmContainer).cpuDemand, 0, false) {
+		slog.Debug("Not enough CPU to start a warm container", "function", f.Name)
 		return "", OutOfResourcesErr
 	}
 
-	//log.Printf("Acquired resources for warm container. Now: %v", Resources)
+	contID, _ := fp.getWarmContainer()
+
+	slog.Debug("Acquired resources for warm container", "function", f.Name, "container", contID, "resources", Resources.String())
 	return contID, nil
 }
 
-// ReleaseContainer puts a container in the ready pool for a function.
+// ReleaseContainer notifies the completion of a request served by a
+// container. When the container is not serving any other request, it is put
+// in the ready pool for the function.
 func ReleaseContainer(contID container.ContainerID, f *function.Function) {
 	// setup Expiration as time duration from now
 	d := time.Duration(config.GetInt(config.CONTAINER_EXPIRATION_TIME, 600)) * time.Second
-	expTime := time.Now().Add(d).UnixNano()
+	expTime := clock.Now().Add(d).UnixNano()
 
 	Resources.Lock()
 	defer Resources.Unlock()
 
 	fp := getFunctionPool(f)
 
-	// we must update the busy list by removing this element
-	elem := fp.busy.Front()
-	for ok := elem != nil; ok; ok = elem != nil {
-		if elem.Value.(container.ContainerID) == contID {
-			fp.busy.Remove(elem) // delete the element from the busy list
-			break
+	// the CPU acquired for the container is released, as the function
+	// may have been updated in the meantime
+	cpuDemand := f.CPUDemand
+	elem := fp.findBusyContainer(contID)
+	if elem != nil {
+		busy := elem.Value.(*busyContainer)
+		busy.inFlight--
+		if busy.inFlight > 0 {
+			// the container is still serving other requests
+			return
+		}
+		fp.busy.Remove(elem) // delete the element from the busy list
+		cpuDemand = busy.cpuDemand
+
+		if busy.stale {
+			memory, _ := container.GetMemoryMB(contID)
+			releaseResources(cpuDemand, memory)
+			destroyContainers([]container.ContainerID{contID})
+			return
 		}
-		elem = elem.Next()
 	}
 
-	fp.putReadyContainer(contID, expTime)
+	fp.putReadyContainer(contID, expTime, cpuDemand)
 
-	releaseResources(f.CPUDemand, 0)
+	releaseResources(cpuDemand, 0)
 
-	//log.Printf("Released resources. Now: %v", Resources)
+	slog.Debug("Released resources", "function", f.Name, "container", contID, "resources", Resources.String())
 }
 
 // NewContainer creates and starts a new container for the given function.
@@ -165,12 +259,12 @@ func ReleaseContainer(contID container.ContainerID, f *function.Function) {
 func NewContainer(fun *function.Function) (container.ContainerID, error) {
 	Resources.Lock()
 	if !acquireResources(fun.CPUDemand, fun.MemoryMB, true) {
-		//log.Printf("Not enough resources for the new container.")
+		slog.Debug("Not enough resources for the new container", "function", fun.Name)
 		Resources.Unlock()
 		return "", OutOfResourcesErr
 	}
 
-	//log.Printf("Acquired resources for new container. Now: %v", Resources)
+	slog.Debug("Acquired resources for new container", "function", fun.Name, "resources", Resources.String())
 	Resources.Unlock()
 
 	return NewContainerWithAcquiredResources(fun)
@@ -186,19 +280,41 @@ func NewContainerWithAcquiredResources(fun *function.Function) (container.Contai
 	} else {
 		runtime, ok := container.RuntimeToInfo[fun.Runtime]
 		if !ok {
-			log.Printf("Unknown runtime: %s", fun.Runtime)
+			slog.Error("Unknown runtime", "function", fun.Name, "runtime", fun.Runtime)
 			return "", fmt.Errorf("Invalid runtime: %s", fun.Runtime)
 		}
 		image = runtime.Image
 	}
 
-	contID, err := container.NewContainer(image, fun.TarFunctionCode, &container.ContainerOptions{
-		MemoryMB: fun.MemoryMB,
-		CPUQuota: fun.CPUDemand,
-	})
+	opts := &container.ContainerOptions{
+		MemoryMB:  fun.MemoryMB,
+		CPUQuota:  fun.CPUDemand,
+		Hardening: containerHardening(fun),
+		Network:   networkMode(fun),
+	}
+	var contID container.ContainerID
+	var restored bool
+	var secretsEnv []string
+	// the code is checked again, as it could have been modified after the
+	// function was created (e.g., in Etcd)
+	err := codesign.Verify(fun)
+	if err == nil {
+		if secretsEnv, err = secrets.Env(fun); err != nil {
+			err = fmt.Errorf("could not retrieve secrets: %w", err)
+		}
+	}
+	if err == nil {
+		opts.Env = append(fun.EnvList(), secretsEnv...)
+		if len(fun.Secrets) > 0 {
+			// snapshots would store the values of the secrets on disk
+			contID, err = container.NewContainer(image, fun.TarFunctionCode, opts)
+		} else {
+			contID, restored, err = container.NewContainerFromSnapshot(SnapshotKey(fun), image, fun.TarFunctionCode, opts)
+		}
+	}
 
 	if err != nil {
-		log.Printf("Failed container creation: %v", err)
+		slog.Error("Failed container creation", "function", fun.Name, "err", err)
 	}
 
 	Resources.Lock()
@@ -209,11 +325,71 @@ func NewContainerWithAcquiredResources(fun *function.Function) (container.Contai
 	}
 
 	fp := getFunctionPool(fun)
-	fp.putBusyContainer(contID) // We immediately mark it as busy
+	fp.putBusyContainer(contID, fun.Version, restored, fun.CPUDemand) // We immediately mark it as busy
 
 	return contID, nil
 }
 
+// IsRestored reports whether a newly created container, which has not been
+// released yet, has been restored from a snapshot.
+func IsRestored(contID container.ContainerID, f *function.Function) bool {
+	Resources.RLock()
+	defer Resources.RUnlock()
+
+	fp, ok := Resources.ContainerPools[f.Name]
+	if !ok {
+		return false
+	}
+	elem := fp.findBusyContainer(contID)
+	return elem != nil && elem.Value.(*busyContainer).restored
+}
+
+// SnapshotKey returns the key identifying the container snapshots of a
+// version of a function. As the version always follows the last '@', keys
+// of different functions or versions are distinct.
+func SnapshotKey(fun *function.Function) string {
+	return fmt.Sprintf("%s@%d", fun.Name, fun.Version)
+}
+
+// containerHardening returns the hardening settings for the containers of a
+// function, applying its isolation settings to the defaults of the node.
+func containerHardening(fun *function.Function) *container.Hardening {
+	h := container.DefaultHardening()
+	iso := fun.Isolation
+	if iso == nil {
+		return h
+	}
+	if h == nil {
+		// hardening is disabled, but gVisor can still be requested
+		if !iso.GVisor {
+			return nil
+		}
+		h = &container.Hardening{}
+	}
+
+	h.ReadOnlyRootfs = h.ReadOnlyRootfs && !iso.WritableRootfs
+	if iso.ImageUser {
+		h.User = ""
+	}
+	h.Capabilities = iso.Capabilities
+	if iso.PidsLimit != 0 {
+		h.PidsLimit = max(iso.PidsLimit, 0)
+	}
+	if iso.Unconfined {
+		h.SeccompProfile = container.SECCOMP_UNCONFINED
+	}
+	h.GVisor = iso.GVisor
+	return h
+}
+
+// networkMode returns the network mode of the containers of a function.
+func networkMode(fun *function.Function) string {
+	if fun.Isolation != nil && fun.Isolation.Network != "" {
+		return fun.Isolation.Network
+	}
+	return container.DefaultNetworkMode()
+}
+
 type itemToDismiss struct {
 	contID container.ContainerID
 	pool   *ContainerPool
@@ -272,7 +448,7 @@ cleanup: // second phase, cleanup
 // DeleteExpiredContainer is called by the container cleaner
 // Deletes expired warm container
 func DeleteExpiredContainer() {
-	now := time.Now().UnixNano()
+	now := clock.Now().UnixNano()
 
 	Resources.Lock()
 	defer Resources.Unlock()
@@ -284,13 +460,13 @@ func DeleteExpiredContainer() {
 			if now > warmed.Expiration {
 				temp := elem
 				elem = elem.Next()
-				log.Printf("cleaner: Removing container %s\n", warmed.contID)
+				slog.Info("Removing expired container", "container", warmed.contID)
 				pool.ready.Remove(temp) // remove the expired element
 
 				memory, _ := container.GetMemoryMB(warmed.contID)
 				releaseResources(0, memory)
 				container.Destroy(warmed.contID)
-				log.Printf("Released resources. Now: %v", Resources)
+				slog.Debug("Released resources", "resources", Resources.String())
 			} else {
 				elem = elem.Next()
 			}
@@ -310,6 +486,13 @@ func ShutdownWarmContainersFor(f *function.Function) {
 		return
 	}
 
+	destroyContainers(fp.drainReadyContainers())
+}
+
+// drainReadyContainers removes all the warm containers from the pool,
+// releasing their memory, and returns them.
+// The function is NOT thread-safe.
+func (fp *ContainerPool) drainReadyContainers() []container.ContainerID {
 	containersToDelete := make([]container.ContainerID, 0)
 
 	elem := fp.ready.Front()
@@ -317,21 +500,25 @@ func ShutdownWarmContainersFor(f *function.Function) {
 		warmed := elem.Value.(warmContainer)
 		temp := elem
 		elem = elem.Next()
-		log.Printf("Removing container with ID %s\n", warmed.contID)
+		slog.Info("Removing container", "container", warmed.contID)
 		fp.ready.Remove(temp)
 
 		memory, _ := container.GetMemoryMB(warmed.contID)
 		Resources.AvailableMemMB += memory
 		containersToDelete = append(containersToDelete, warmed.contID)
 	}
+	return containersToDelete
+}
 
+// destroyContainers destroys containers asynchronously.
+func destroyContainers(containersToDelete []container.ContainerID) {
 	go func(contIDs []container.ContainerID) {
 		for _, contID := range contIDs {
 			// No need to update available resources here
 			if err := container.Destroy(contID); err != nil {
-				log.Printf("An error occurred while deleting %s: %v", contID, err)
+				slog.Error("Could not delete container", "container", contID, "err", err)
 			} else {
-				log.Printf("Deleted %s", contID)
+				slog.Info("Deleted container", "container", contID)
 			}
 		}
 	}(containersToDelete)
@@ -342,13 +529,13 @@ func ShutdownAllContainers() {
 	Resources.Lock()
 	defer Resources.Unlock()
 
-	for fun, pool := range Resources.ContainerPools {
+	for _, pool := range Resources.ContainerPools {
 		elem := pool.ready.Front()
 		for ok := elem != nil; ok; ok = elem != nil {
 			warmed := elem.Value.(warmContainer)
 			temp := elem
 			elem = elem.Next()
-			log.Printf("Removing container with ID %s\n", warmed.contID)
+			slog.Info("Removing container", "container", warmed.contID)
 			pool.ready.Remove(temp)
 
 			memory, _ := container.GetMemoryMB(warmed.contID)
@@ -356,32 +543,51 @@ func ShutdownAllContainers() {
 			Resources.AvailableMemMB += memory
 		}
 
-		function, _ := function.GetFunction(fun)
-
 		elem = pool.busy.Front()
 		for ok := elem != nil; ok; ok = elem != nil {
-			contID := elem.Value.(container.ContainerID)
+			busy := elem.Value.(*busyContainer)
+			contID := busy.contID
 			temp := elem
 			elem = elem.Next()
-			log.Printf("Removing container with ID %s\n", contID)
-			pool.ready.Remove(temp)
+			slog.Info("Removing container", "container", contID)
+			pool.busy.Remove(temp)
 
 			memory, _ := container.GetMemoryMB(contID)
 			container.Destroy(contID)
 			Resources.AvailableMemMB += memory
-			Resources.AvailableCPUs += function.CPUDemand
+			Resources.AvailableCPUs += busy.cpuDemand
 		}
 	}
 }
 
 // WarmStatus foreach function returns the corresponding number of warm container available
+// (i.e., the number of requests that can be served by warm containers)
 func WarmStatus() map[string]int {
 	Resources.RLock()
 	defer Resources.RUnlock()
 	warmPool := make(map[string]int)
 	for funcName, pool := range Resources.ContainerPools {
-		warmPool[funcName] = pool.ready.Len()
+		warmPool[funcName] = pool.availableSlots()
 	}
 
 	return warmPool
 }
+
+// PoolStatus reports the number of idle and busy containers of a function.
+type PoolStatus struct {
+	Warm int
+	Busy int
+}
+
+// ContainerStatus returns the number of idle (warm) and busy containers for
+// each function.
+func ContainerStatus() map[string]PoolStatus {
+	Resources.RLock()
+	defer Resources.RUnlock()
+	status := make(map[string]PoolStatus, len(Resources.ContainerPools))
+	for funcName, pool := range Resources.ContainerPools {
+		status[funcName] = PoolStatus{Warm: pool.ready.Len(), Busy: pool.busy.Len()}
+	}
+
+	return status
+}
@@ -0,0 +1,427 @@
+package node
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/container"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/spf13/viper"
+)
+
+// setupPool resets the node resources and installs a fake container factory.
+func setupPool(t testing.TB, memMB int64, cpus float64) *container.FakeFactory {
+	f := container.NewFakeFactory()
+	container.SetFactory(f)
+
+	Resources.Lock()
+	Resources.AvailableMemMB = memMB
+	Resources.AvailableCPUs = cpus
+	Resources.DropCount = 0
+	Resources.ContainerPools = make(map[string]*ContainerPool)
+	Resources.Unlock()
+
+	return f
+}
+
+func newTestFunction(name string, memMB int64, cpu float64) *function.Function {
+	return &function.Function{Name: name, Runtime: "python310", MemoryMB: memMB, CPUDemand: cpu}
+}
+
+func availableResources() (int64, float64) {
+	Resources.RLock()
+	defer Resources.RUnlock()
+	return Resources.AvailableMemMB, Resources.AvailableCPUs
+}
+
+func TestWarmContainerReuse(t *testing.T) {
+	f := setupPool(t, 1024, 4.0)
+	fun := newTestFunction("reuse", 256, 1.0)
+
+	if _, err := AcquireWarmContainer(fun); !errors.Is(err, NoWarmFoundErr) {
+		t.Fatalf("expected NoWarmFoundErr, got %v", err)
+	}
+
+	contID, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem, cpus := availableResources(); mem != 768 || cpus != 3.0 {
+		t.Errorf("unexpected resources after cold start: %d MB, %f CPUs", mem, cpus)
+	}
+
+	ReleaseContainer(contID, fun)
+	if mem, cpus := availableResources(); mem != 768 || cpus != 4.0 {
+		t.Errorf("unexpected resources after release: %d MB, %f CPUs", mem, cpus)
+	}
+	if WarmStatus()[fun.Name] != 1 {
+		t.Errorf("expected 1 warm container, got %d", WarmStatus()[fun.Name])
+	}
+
+	warmID, err := AcquireWarmContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warmID != contID {
+		t.Errorf("expected container %s, got %s", contID, warmID)
+	}
+	if f.Created() != 1 {
+		t.Errorf("expected 1 container, got %d", f.Created())
+	}
+}
+
+func TestWarmContainerRequiresCPU(t *testing.T) {
+	setupPool(t, 1024, 1.0)
+	fun := newTestFunction("cpu", 128, 1.0)
+
+	contID, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ReleaseContainer(contID, fun)
+
+	// another function keeps the only CPU busy
+	if !AcquireResources(1.0, 0, false) {
+		t.Fatal("could not acquire CPU")
+	}
+	if _, err := AcquireWarmContainer(fun); !errors.Is(err, OutOfResourcesErr) {
+		t.Fatalf("expected OutOfResourcesErr, got %v", err)
+	}
+	if WarmStatus()[fun.Name] != 1 {
+		t.Errorf("the warm container should still be available")
+	}
+}
+
+func TestConcurrentInvocationsPerContainer(t *testing.T) {
+	f := setupPool(t, 1024, 4.0)
+	fun := newTestFunction("shared", 256, 1.0)
+	fun.MaxConcurrencyPerInstance = 2
+
+	contID, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sharedID, err := AcquireWarmContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sharedID != contID {
+		t.Errorf("expected shared container %s, got %s", contID, sharedID)
+	}
+	if _, cpus := availableResources(); cpus != 3.0 {
+		t.Errorf("CPU should be acquired once per container, available: %f", cpus)
+	}
+
+	if _, err := AcquireWarmContainer(fun); !errors.Is(err, NoWarmFoundErr) {
+		t.Fatalf("expected NoWarmFoundErr, got %v", err)
+	}
+
+	ReleaseContainer(contID, fun)
+	if WarmStatus()[fun.Name] != 1 {
+		t.Errorf("expected 1 available slot, got %d", WarmStatus()[fun.Name])
+	}
+	ReleaseContainer(contID, fun)
+	if WarmStatus()[fun.Name] != 2 {
+		t.Errorf("expected 2 available slots, got %d", WarmStatus()[fun.Name])
+	}
+	if f.Created() != 1 {
+		t.Errorf("expected 1 container, got %d", f.Created())
+	}
+}
+
+func TestJanitorRemovesExpiredContainers(t *testing.T) {
+	f := setupPool(t, 1024, 4.0)
+	clk := NewManualClock(time.Now())
+	SetClock(clk)
+	defer SetClock(systemClock{})
+	viper.Set(config.CONTAINER_EXPIRATION_TIME, 60)
+	defer viper.Set(config.CONTAINER_EXPIRATION_TIME, nil)
+
+	fun := newTestFunction("expiring", 256, 1.0)
+	contID, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ReleaseContainer(contID, fun)
+
+	clk.Advance(30 * time.Second)
+	DeleteExpiredContainer()
+	if f.Running() != 1 {
+		t.Fatalf("container removed before expiration")
+	}
+
+	clk.Advance(31 * time.Second)
+	DeleteExpiredContainer()
+	if f.Running() != 0 {
+		t.Fatalf("expired container not removed")
+	}
+	if mem, _ := availableResources(); mem != 1024 {
+		t.Errorf("memory not released: %d MB available", mem)
+	}
+}
+
+func TestIdleContainersDismissedForNewOnes(t *testing.T) {
+	f := setupPool(t, 512, 4.0)
+	funA := newTestFunction("a", 512, 1.0)
+	funB := newTestFunction("b", 256, 1.0)
+
+	contID, err := NewContainer(funA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewContainer(funB); !errors.Is(err, OutOfResourcesErr) {
+		t.Fatalf("busy containers must not be dismissed, got %v", err)
+	}
+
+	ReleaseContainer(contID, funA)
+	if _, err := NewContainer(funB); err != nil {
+		t.Fatalf("idle container not dismissed: %v", err)
+	}
+	if f.Destroyed() != 1 {
+		t.Errorf("expected 1 destroyed container, got %d", f.Destroyed())
+	}
+	if mem, _ := availableResources(); mem != 256 {
+		t.Errorf("unexpected available memory: %d MB", mem)
+	}
+}
+
+func BenchmarkAcquireReleaseWarmContainer(b *testing.B) {
+	setupPool(b, 1024, 4.0)
+	fun := newTestFunction("bench", 256, 1.0)
+	contID, err := NewContainer(fun)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ReleaseContainer(contID, fun)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		contID, err := AcquireWarmContainer(fun)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ReleaseContainer(contID, fun)
+	}
+}
+
+func TestFunctionUpdate(t *testing.T) {
+	f := setupPool(t, 1024, 4.0)
+	fun := newTestFunction("update", 256, 1.0)
+	fun.Env = map[string]string{"GREETING": "hello"}
+
+	contID, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := f.Options(contID).Env; len(env) != 1 || env[0] != "GREETING=hello" {
+		t.Errorf("unexpected container environment: %v", env)
+	}
+	busyID, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ReleaseContainer(contID, fun)
+
+	// containers of the previous version are not reused anymore
+	updated := *fun
+	updated.Env = map[string]string{"GREETING": "ciao"}
+	updated.Version = 1
+	if _, err := AcquireWarmContainer(&updated); !errors.Is(err, NoWarmFoundErr) {
+		t.Fatalf("expected NoWarmFoundErr, got %v", err)
+	}
+	ReleaseContainer(busyID, fun)
+	if WarmStatus()[fun.Name] != 0 {
+		t.Errorf("expected no warm containers, got %d", WarmStatus()[fun.Name])
+	}
+	if mem, cpus := availableResources(); mem != 1024 || cpus != 4.0 {
+		t.Errorf("resources not released: %d MB, %f CPUs", mem, cpus)
+	}
+
+	newID, err := NewContainer(&updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := f.Options(newID).Env; len(env) != 1 || env[0] != "GREETING=ciao" {
+		t.Errorf("unexpected container environment: %v", env)
+	}
+
+	// versions must not share snapshots with functions named after them
+	named := *fun
+	named.Name = fun.Name + "@1"
+	if SnapshotKey(&updated) == SnapshotKey(&named) {
+		t.Errorf("'%s' and '%s' share snapshot key %s", named.Name, updated.Name, SnapshotKey(&named))
+	}
+}
+
+func TestFunctionUpdateReleasesAcquiredCPU(t *testing.T) {
+	setupPool(t, 1024, 4.0)
+	fun := newTestFunction("updatecpu", 256, 1.0)
+	fun.MaxConcurrencyPerInstance = 2
+
+	contID, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if WarmStatus()[fun.Name] != 1 {
+		t.Errorf("expected 1 available slot, got %d", WarmStatus()[fun.Name])
+	}
+
+	updated := *fun
+	updated.CPUDemand = 0.5
+	updated.Version = 1
+	if _, err := AcquireWarmContainer(&updated); !errors.Is(err, NoWarmFoundErr) {
+		t.Fatalf("expected NoWarmFoundErr, got %v", err)
+	}
+	// the stale container cannot serve requests of the new version
+	if WarmStatus()[fun.Name] != 0 {
+		t.Errorf("expected no available slots, got %d", WarmStatus()[fun.Name])
+	}
+
+	newID, err := NewContainer(&updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ReleaseContainer(contID, &updated)
+	ReleaseContainer(newID, &updated)
+	if mem, cpus := availableResources(); mem != 768 || cpus != 4.0 {
+		t.Errorf("resources not released: %d MB, %f CPUs", mem, cpus)
+	}
+
+	if _, err := AcquireWarmContainer(&updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, cpus := availableResources(); cpus != 3.5 {
+		t.Errorf("unexpected CPUs acquired for the warm container, available: %f", cpus)
+	}
+}
+
+func TestContainerHardening(t *testing.T) {
+	f := setupPool(t, 1024, 4.0)
+	fun := newTestFunction("hardened", 128, 0.0)
+
+	contID, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := f.Options(contID).Hardening
+	if h == nil || !h.ReadOnlyRootfs || !h.DropCapabilities || !h.NoNewPrivileges || h.User == "" || h.PidsLimit <= 0 {
+		t.Errorf("containers not hardened by default: %+v", h)
+	}
+	if network := f.Options(contID).Network; network != function.NETWORK_FULL {
+		t.Errorf("unexpected default network mode: %s", network)
+	}
+
+	fun.Isolation = &function.Isolation{ImageUser: true, Capabilities: []string{"NET_RAW"}, PidsLimit: -1, GVisor: true}
+	h = containerHardening(fun)
+	if !h.ReadOnlyRootfs || h.User != "" || len(h.Capabilities) != 1 || h.PidsLimit != 0 || !h.GVisor {
+		t.Errorf("isolation settings not applied: %+v", h)
+	}
+
+	viper.Set(config.CONTAINER_HARDENING_ENABLED, false)
+	defer viper.Set(config.CONTAINER_HARDENING_ENABLED, nil)
+	if h := containerHardening(fun); h == nil || !h.GVisor || h.ReadOnlyRootfs {
+		t.Errorf("unexpected settings with hardening disabled: %+v", h)
+	}
+}
+
+func TestNetworkMode(t *testing.T) {
+	f := setupPool(t, 1024, 4.0)
+	viper.Set(config.CONTAINER_NETWORK_DEFAULT, function.NETWORK_INTERNAL)
+	defer viper.Set(config.CONTAINER_NETWORK_DEFAULT, nil)
+
+	fun := newTestFunction("network", 128, 0.0)
+	contID, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network := f.Options(contID).Network; network != function.NETWORK_INTERNAL {
+		t.Errorf("unexpected network mode: %s", network)
+	}
+
+	fun.Isolation = &function.Isolation{Network: function.NETWORK_NONE}
+	if network := networkMode(fun); network != function.NETWORK_NONE {
+		t.Errorf("unexpected network mode: %s", network)
+	}
+	if container.IsNetworkRelaxed(function.NETWORK_NONE) || !container.IsNetworkRelaxed(function.NETWORK_FULL) {
+		t.Errorf("network modes not compared with the default one")
+	}
+}
+
+// snapshotFactory is a fake factory supporting snapshots. The Executor of
+// every container is reachable at the address of listener.
+type snapshotFactory struct {
+	*container.FakeFactory
+	listener net.Listener
+	restores int
+}
+
+func (f *snapshotFactory) GetExecutorPort(contID container.ContainerID) (int, error) {
+	return f.listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+func (f *snapshotFactory) Checkpoint(contID container.ContainerID, snapshotID string) error {
+	return nil
+}
+
+func (f *snapshotFactory) Restore(contID container.ContainerID, snapshotID string) error {
+	f.restores++
+	return nil
+}
+
+func (f *snapshotFactory) DeleteSnapshot(snapshotID string) error {
+	return nil
+}
+
+func TestRestoredFromSnapshot(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	setupPool(t, 1024, 4.0)
+	f := &snapshotFactory{FakeFactory: container.NewFakeFactory(), listener: listener}
+	container.SetFactory(f)
+	viper.Set(config.CONTAINER_SNAPSHOTS_ENABLED, true)
+	defer viper.Set(config.CONTAINER_SNAPSHOTS_ENABLED, nil)
+
+	fun := newTestFunction("restored", 128, 0.0)
+	first, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsRestored(first, fun) {
+		t.Errorf("container %s restored before the snapshot was taken", first)
+	}
+
+	second, err := NewContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsRestored(second, fun) || f.restores != 1 {
+		t.Errorf("container %s not restored from snapshot (%d restores)", second, f.restores)
+	}
+
+	// containers are only reported as restored for their first request
+	ReleaseContainer(second, fun)
+	warm, err := AcquireWarmContainer(fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warm != second || IsRestored(warm, fun) {
+		t.Errorf("warm container %s reported as restored", warm)
+	}
+}
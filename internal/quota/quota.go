@@ -0,0 +1,220 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/utils"
+)
+
+var QuotaExceededErr = errors.New("quota exceeded")
+
+// A Quota limits the resources used by a namespace (i.e., a tenant) or by a
+// function. Zero values mean no limit.
+type Quota struct {
+	// max. fraction of the node CPUs and memory used by concurrent
+	// invocations
+	CPUShare    float64 `json:",omitempty"`
+	MemoryShare float64 `json:",omitempty"`
+	// max. requests per second (token bucket), across all the nodes
+	RequestsPerSecond float64 `json:",omitempty"`
+	Burst             int     `json:",omitempty"` // bucket size (default: max(1, RequestsPerSecond))
+	// max. number of functions (only for namespaces)
+	MaxFunctions int `json:",omitempty"`
+}
+
+// Kinds of quota
+const (
+	KIND_NAMESPACE = "namespace"
+	KIND_FUNCTION  = "function"
+)
+
+var Enabled bool
+
+// defaultQuota applies to namespaces without a quota of their own
+var defaultQuota Quota
+
+// Quotas are cached to avoid hitting Etcd on every request: changes may take
+// up to quotaCacheTTL to be enforced.
+const quotaCacheTTL = 10 * time.Second
+
+type cachedQuota struct {
+	quota   *Quota // nil if not set
+	fetched time.Time
+}
+
+var quotaCache = make(map[string]cachedQuota)
+var quotaCacheMutex sync.Mutex
+
+// Init configures quotas according to the configuration.
+func Init() error {
+	Enabled = config.GetBool(config.QUOTA_ENABLED, false)
+	if !Enabled {
+		return nil
+	}
+
+	defaultQuota = Quota{
+		CPUShare:          config.GetFloat(config.QUOTA_DEFAULT_CPU_SHARE, 0.0),
+		MemoryShare:       config.GetFloat(config.QUOTA_DEFAULT_MEMORY_SHARE, 0.0),
+		RequestsPerSecond: config.GetFloat(config.QUOTA_DEFAULT_RATE, 0.0),
+		Burst:             config.GetInt(config.QUOTA_DEFAULT_BURST, 0),
+		MaxFunctions:      config.GetInt(config.QUOTA_DEFAULT_FUNCTIONS, 0),
+	}
+	if err := defaultQuota.Validate(); err != nil {
+		return fmt.Errorf("invalid default quota: %v", err)
+	}
+
+	interval := time.Duration(config.GetInt(config.QUOTA_SYNC_INTERVAL, 2)) * time.Second
+	go syncRates(interval)
+
+	slog.Info("Quotas enabled", "default", defaultQuota)
+	return nil
+}
+
+// Validate checks that the values of the quota are in range.
+func (q *Quota) Validate() error {
+	if q.CPUShare < 0.0 || q.CPUShare > 1.0 || q.MemoryShare < 0.0 || q.MemoryShare > 1.0 {
+		return fmt.Errorf("shares must be in [0,1]")
+	}
+	if q.RequestsPerSecond < 0.0 || q.Burst < 0 || q.MaxFunctions < 0 {
+		return fmt.Errorf("negative limit")
+	}
+	return nil
+}
+
+func getEtcdKey(kind string, name string) string {
+	return fmt.Sprintf("/quota/%s/%s", kind, name)
+}
+
+// Get returns the quota set for a namespace or a function (nil if not set).
+func Get(kind string, name string) (*Quota, error) {
+	key := getEtcdKey(kind, name)
+	quotaCacheMutex.Lock()
+	cached, ok := quotaCache[key]
+	quotaCacheMutex.Unlock()
+	if ok && time.Since(cached.fetched) < quotaCacheTTL {
+		return cached.quota, nil
+	}
+
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	value, found, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var q *Quota
+	if found {
+		q = &Quota{}
+		if err := json.Unmarshal(value, q); err != nil {
+			return nil, err
+		}
+	}
+
+	quotaCacheMutex.Lock()
+	quotaCache[key] = cachedQuota{quota: q, fetched: time.Now()}
+	quotaCacheMutex.Unlock()
+	return q, nil
+}
+
+// Set sets the quota of a namespace or a function.
+func Set(kind string, name string, q *Quota) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+
+	key := getEtcdKey(kind, name)
+	if err := store.Put(context.Background(), key, payload, 0); err != nil {
+		return err
+	}
+	quotaCacheMutex.Lock()
+	quotaCache[key] = cachedQuota{quota: q, fetched: time.Now()}
+	quotaCacheMutex.Unlock()
+	return nil
+}
+
+// Delete removes the quota of a namespace or a function, returning false if
+// it was not set.
+func Delete(kind string, name string) (bool, error) {
+	key := getEtcdKey(kind, name)
+	quotaCacheMutex.Lock()
+	delete(quotaCache, key)
+	quotaCacheMutex.Unlock()
+
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return false, err
+	}
+	return store.Delete(context.Background(), key)
+}
+
+// quotasFor returns the quotas applying to a function: the quota of its
+// namespace (or the default one) and its own quota (if any).
+func quotasFor(f *function.Function) (nsQuota *Quota, funQuota *Quota) {
+	nsQuota, err := Get(KIND_NAMESPACE, f.Namespace())
+	if err != nil {
+		slog.Warn("Could not retrieve quota", "namespace", f.Namespace(), "err", err)
+	}
+	if nsQuota == nil {
+		nsQuota = &defaultQuota
+	}
+
+	funQuota, err = Get(KIND_FUNCTION, f.Name)
+	if err != nil {
+		slog.Warn("Could not retrieve quota", "function", f.Name, "err", err)
+	}
+	return nsQuota, funQuota
+}
+
+// CheckFunctionCount returns QuotaExceededErr if no more functions can be
+// created in a namespace.
+func CheckFunctionCount(namespace string) error {
+	if !Enabled {
+		return nil
+	}
+
+	q, err := Get(KIND_NAMESPACE, namespace)
+	if err != nil {
+		return err
+	}
+	if q == nil {
+		q = &defaultQuota
+	}
+	if q.MaxFunctions == 0 {
+		return nil
+	}
+
+	all, err := function.GetAll()
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, name := range all {
+		if ns, _ := function.SplitName(name); ns == namespace {
+			count++
+		}
+	}
+	if count >= q.MaxFunctions {
+		return fmt.Errorf("%w: at most %d functions in namespace '%s'", QuotaExceededErr, q.MaxFunctions, namespace)
+	}
+	return nil
+}
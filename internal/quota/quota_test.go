@@ -0,0 +1,121 @@
+package quota
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/utils"
+)
+
+func setupQuotas(t *testing.T) {
+	utils.SetKVStore(utils.NewMemoryKVStore())
+	Enabled = true
+	defaultQuota = Quota{}
+	SetCapacity(4.0, 4096)
+	t.Cleanup(func() {
+		Enabled = false
+		quotaCache = make(map[string]cachedQuota)
+		inUse = make(map[string]*resources)
+		buckets = make(map[string]*tokenBucket)
+		arrivals = make(map[string]int)
+		shares = make(map[string]float64)
+	})
+}
+
+func TestTokenBucket(t *testing.T) {
+	var b tokenBucket
+	t0 := time.Now()
+	for i := 0; i < 2; i++ {
+		if !b.take(t0, 1.0, 2.0) {
+			t.Fatalf("request %d within the burst rejected", i)
+		}
+	}
+	if b.take(t0, 1.0, 2.0) {
+		t.Errorf("request exceeding the burst accepted")
+	}
+	if !b.take(t0.Add(time.Second), 1.0, 2.0) {
+		t.Errorf("request rejected after refill")
+	}
+}
+
+func TestResourceShares(t *testing.T) {
+	setupQuotas(t)
+	if err := Set(KIND_NAMESPACE, "team-a", &Quota{CPUShare: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &function.Function{Name: "team-a/f", CPUDemand: 1.0, MemoryMB: 128}
+	g := &function.Function{Name: "team-b/g", CPUDemand: 1.0, MemoryMB: 128}
+	if !Acquire(f) || !Acquire(f) {
+		t.Fatalf("requests within the quota rejected")
+	}
+	if Acquire(f) {
+		t.Errorf("request exceeding the quota accepted")
+	}
+	if !Acquire(g) {
+		t.Errorf("request of another namespace rejected")
+	}
+
+	Release(f)
+	if !Acquire(f) {
+		t.Errorf("request rejected after release")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	setupQuotas(t)
+	if err := Set(KIND_FUNCTION, "f", &Quota{RequestsPerSecond: 1.0, Burst: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &function.Function{Name: "f"}
+	for i := 0; i < 3; i++ {
+		if err := AllowRequest(f); err != nil {
+			t.Fatalf("request %d within the burst rejected: %v", i, err)
+		}
+	}
+	if err := AllowRequest(f); !errors.Is(err, QuotaExceededErr) {
+		t.Errorf("request exceeding the rate limit accepted")
+	}
+}
+
+func TestRateLimitRejectionsConsumeNoTokens(t *testing.T) {
+	setupQuotas(t)
+	if err := Set(KIND_NAMESPACE, "ns", &Quota{RequestsPerSecond: 1.0, Burst: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(KIND_FUNCTION, "ns/f", &Quota{RequestsPerSecond: 1.0, Burst: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &function.Function{Name: "ns/f"}
+	g := &function.Function{Name: "ns/g"}
+	if err := AllowRequest(f); err != nil {
+		t.Fatalf("request within the limits rejected: %v", err)
+	}
+	// rejected by the function limit: the namespace token is kept
+	if err := AllowRequest(f); !errors.Is(err, QuotaExceededErr) {
+		t.Fatalf("request exceeding the function rate limit accepted")
+	}
+	if err := AllowRequest(g); err != nil {
+		t.Errorf("request within the namespace limit rejected: %v", err)
+	}
+	if arrivals[namespaceKey(f)] != 2 || arrivals[functionKey(f)] != 1 {
+		t.Errorf("rejected requests counted as arrivals: %v", arrivals)
+	}
+}
+
+func TestComputeShares(t *testing.T) {
+	local := map[string]float64{"a": 3.0}
+	all := []map[string]float64{local, {"a": 1.0, "b": 2.0}}
+
+	shares := computeShares(local, all)
+	if shares["a"] != 0.75 {
+		t.Errorf("share of a: %f, expected 0.75", shares["a"])
+	}
+	if shares["b"] != 0.5 {
+		t.Errorf("share of b: %f, expected 0.5", shares["b"])
+	}
+}
@@ -0,0 +1,188 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/node"
+	"github.com/grussorusso/serverledge/utils"
+)
+
+// tokenBucket limits the rate of requests, allowing bursts up to its size.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens accumulated since the last refill, returning true
+// if a token is available.
+func (b *tokenBucket) refill(now time.Time, rate float64, burst float64) bool {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	return b.tokens >= 1.0
+}
+
+// take refills the bucket according to the elapsed time and takes a token,
+// if available.
+func (b *tokenBucket) take(now time.Time, rate float64, burst float64) bool {
+	if !b.refill(now, rate, burst) {
+		return false
+	}
+	b.tokens -= 1.0
+	return true
+}
+
+// Rate limits apply to the whole cluster: every node enforces a share of the
+// limit, proportional to the rate of requests it admits. Nodes periodically
+// publish their rates in Etcd (under /quota/rates/) to compute their shares,
+// instead of updating shared counters on every request.
+var buckets = make(map[string]*tokenBucket)
+var arrivals = make(map[string]int) // admitted since the last synchronization
+var shares = make(map[string]float64)
+var rateMutex sync.Mutex
+
+// AllowRequest checks whether a new request for a function complies with the
+// rate limits of the function and of its namespace, returning
+// QuotaExceededErr otherwise.
+func AllowRequest(f *function.Function) error {
+	if !Enabled {
+		return nil
+	}
+	nsQuota, funQuota := quotasFor(f)
+
+	rateMutex.Lock()
+	defer rateMutex.Unlock()
+	now := time.Now()
+	// tokens are taken only if both the limits are met
+	limited := make([]string, 0, 2)
+	for _, l := range []struct {
+		key   string
+		quota *Quota
+	}{{namespaceKey(f), nsQuota}, {functionKey(f), funQuota}} {
+		if l.quota == nil || l.quota.RequestsPerSecond <= 0.0 {
+			continue
+		}
+
+		share, ok := shares[l.key]
+		if !ok {
+			share = 1.0
+		}
+		burst := float64(l.quota.Burst)
+		if burst == 0.0 {
+			burst = math.Max(1.0, l.quota.RequestsPerSecond)
+		}
+
+		bucket, ok := buckets[l.key]
+		if !ok {
+			bucket = &tokenBucket{}
+			buckets[l.key] = bucket
+		}
+		if !bucket.refill(now, share*l.quota.RequestsPerSecond, math.Max(1.0, share*burst)) {
+			return fmt.Errorf("%w: rate limit of %s", QuotaExceededErr, l.key)
+		}
+		limited = append(limited, l.key)
+	}
+
+	for _, key := range limited {
+		buckets[key].tokens -= 1.0
+		arrivals[key]++
+	}
+	return nil
+}
+
+func ratesEtcdKey(nodeId string) string {
+	return fmt.Sprintf("/quota/rates/%s", nodeId)
+}
+
+// syncRates periodically publishes the local rates of requests and updates
+// the share of the rate limits enforced by this node.
+func syncRates(interval time.Duration) {
+	for range time.Tick(interval) {
+		rateMutex.Lock()
+		rates := make(map[string]float64, len(arrivals))
+		for key, n := range arrivals {
+			rates[key] = float64(n) / interval.Seconds()
+		}
+		arrivals = make(map[string]int)
+		rateMutex.Unlock()
+
+		allRates, err := exchangeRates(rates, 3*interval)
+		if err != nil {
+			slog.Warn("Could not synchronize rate limits", "err", err)
+			continue
+		}
+
+		newShares := computeShares(rates, allRates)
+		rateMutex.Lock()
+		shares = newShares
+		rateMutex.Unlock()
+	}
+}
+
+// exchangeRates publishes the local rates and retrieves the rates of all the
+// nodes (including this one).
+func exchangeRates(rates map[string]float64, ttl time.Duration) ([]map[string]float64, error) {
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ttl)
+	defer cancel()
+
+	payload, err := json.Marshal(rates)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Put(ctx, ratesEtcdKey(node.NodeIdentifier), payload, ttl); err != nil {
+		return nil, err
+	}
+
+	values, err := store.GetWithPrefix(ctx, "/quota/rates/")
+	if err != nil {
+		return nil, err
+	}
+	allRates := make([]map[string]float64, 0, len(values))
+	for _, value := range values {
+		var nodeRates map[string]float64
+		if err := json.Unmarshal(value, &nodeRates); err != nil {
+			return nil, err
+		}
+		allRates = append(allRates, nodeRates)
+	}
+	return allRates, nil
+}
+
+// computeShares computes the share of each rate limit enforced by this node,
+// given the local rates and the rates of all the nodes. Nodes that have not
+// received requests recently get an equal share, so that they can serve new
+// requests until the next synchronization.
+func computeShares(local map[string]float64, all []map[string]float64) map[string]float64 {
+	totals := make(map[string]float64)
+	nodes := make(map[string]int)
+	for _, nodeRates := range all {
+		for key, rate := range nodeRates {
+			totals[key] += rate
+			nodes[key]++
+		}
+	}
+
+	shares := make(map[string]float64, len(totals))
+	for key, total := range totals {
+		if rate, ok := local[key]; ok && total > 0.0 {
+			shares[key] = rate / total
+		} else {
+			shares[key] = 1.0 / float64(nodes[key]+1)
+		}
+	}
+	return shares
+}
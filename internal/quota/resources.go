@@ -0,0 +1,107 @@
+package quota
+
+import (
+	"sync"
+
+	"github.com/grussorusso/serverledge/internal/function"
+)
+
+// resources held by the concurrent invocations of a namespace or a function
+type resources struct {
+	cpus  float64
+	memMB float64
+}
+
+var capacity resources
+var inUse = make(map[string]*resources)
+var inUseMutex sync.Mutex
+
+// SetCapacity sets the node resources that quota shares refer to.
+func SetCapacity(cpus float64, memMB int64) {
+	inUseMutex.Lock()
+	defer inUseMutex.Unlock()
+	capacity = resources{cpus: cpus, memMB: float64(memMB)}
+}
+
+func namespaceKey(f *function.Function) string {
+	return KIND_NAMESPACE + ":" + f.Namespace()
+}
+
+func functionKey(f *function.Function) string {
+	return KIND_FUNCTION + ":" + f.Name
+}
+
+// demand returns the resources required by an invocation of a function. The
+// memory of containers serving concurrent invocations is split among them.
+func demand(f *function.Function) resources {
+	return resources{
+		cpus:  f.CPUDemand,
+		memMB: float64(f.MemoryMB) / float64(f.GetMaxConcurrencyPerInstance()),
+	}
+}
+
+func fits(used *resources, d resources, q *Quota) bool {
+	if q == nil {
+		return true
+	}
+	if q.CPUShare > 0.0 && used.cpus+d.cpus > q.CPUShare*capacity.cpus {
+		return false
+	}
+	if q.MemoryShare > 0.0 && used.memMB+d.memMB > q.MemoryShare*capacity.memMB {
+		return false
+	}
+	return true
+}
+
+func getInUse(key string) *resources {
+	used, ok := inUse[key]
+	if !ok {
+		used = &resources{}
+		inUse[key] = used
+	}
+	return used
+}
+
+// Acquire reserves the share of node resources needed to serve an invocation
+// of a function, returning false if the quota of the function or of its
+// namespace would be exceeded. Resources must be given back with Release.
+func Acquire(f *function.Function) bool {
+	if !Enabled {
+		return true
+	}
+	nsQuota, funQuota := quotasFor(f)
+	d := demand(f)
+
+	inUseMutex.Lock()
+	defer inUseMutex.Unlock()
+	nsUsed := getInUse(namespaceKey(f))
+	funUsed := getInUse(functionKey(f))
+	if !fits(nsUsed, d, nsQuota) || !fits(funUsed, d, funQuota) {
+		return false
+	}
+
+	for _, used := range []*resources{nsUsed, funUsed} {
+		used.cpus += d.cpus
+		used.memMB += d.memMB
+	}
+	return true
+}
+
+// Release gives back the resources reserved by Acquire.
+func Release(f *function.Function) {
+	if !Enabled {
+		return
+	}
+	d := demand(f)
+
+	inUseMutex.Lock()
+	defer inUseMutex.Unlock()
+	for _, key := range []string{namespaceKey(f), functionKey(f)} {
+		used := getInUse(key)
+		used.cpus -= d.cpus
+		used.memMB -= d.memMB
+		if used.cpus <= 0.0 && used.memMB <= 0.0 {
+			delete(inUse, key)
+		}
+	}
+}
@@ -1,18 +1,37 @@
 package registration
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"net/url"
 	"time"
 
 	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/logging"
 	"github.com/grussorusso/serverledge/internal/node"
 	"github.com/grussorusso/serverledge/utils"
 )
 
+// statusRequestTimeout is the max. time waited for a status reply
+const statusRequestTimeout = 2 * time.Second
+
+var statusAuth *udpAuth
+var statusReplay *replayFilter   // used by the status server only
+var statusLimiter *addressLimiter // used by the status server only
+
+// initStatusProtocol configures the authentication of status messages.
+func initStatusProtocol() {
+	maxSkew := time.Duration(config.GetInt(config.REGISTRY_UDP_MAX_SKEW, 30)) * time.Second
+	statusAuth = &udpAuth{secret: []byte(config.GetString(config.REGISTRY_UDP_SECRET, "")), maxSkew: maxSkew}
+	statusReplay = newReplayFilter(2 * maxSkew)
+	statusLimiter = newAddressLimiter(config.GetFloat(config.REGISTRY_UDP_RATE_LIMIT, 10.0))
+	if len(statusAuth.secret) == 0 {
+		slog.Warn("Status messages exchanged over UDP are not authenticated", "key", config.REGISTRY_UDP_SECRET)
+	}
+}
+
 //UDPStatusServer listen for incoming request from other edge-nodes which want to retrieve the status of this server
 // this listener should be called asynchronously in the main function
 func UDPStatusServer() {
@@ -22,15 +41,15 @@ func UDPStatusServer() {
 	udpAddr, err := net.ResolveUDPAddr("udp", address)
 
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Could not start the UDP status server", "err", err)
 	}
 	// setup listener for incoming UDP connection
 	udpConn, err := net.ListenUDP("udp", udpAddr)
 
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Could not start the UDP status server", "err", err)
 	}
-	log.Printf("UDP server up and listening on port %d", port)
+	slog.Info("UDP server up and listening", "port", port)
 	defer udpConn.Close()
 
 	for {
@@ -40,29 +59,51 @@ func UDPStatusServer() {
 
 }
 
+// handleUDPConnection answers a status request, if authentic and within the
+// rate limit. Invalid requests are silently dropped.
 func handleUDPConnection(conn *net.UDPConn) {
-	buffer := make([]byte, 1024)
+	buffer := make([]byte, maxRequestSize+1)
 
-	_, addr, err := conn.ReadFromUDP(buffer)
+	n, addr, err := conn.ReadFromUDP(buffer)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	if !statusLimiter.allow(addr.IP.String(), now) {
+		slog.Debug("Status request rate limit exceeded", "addr", addr.String())
+		return
+	}
+	req, err := statusAuth.parseRequest(buffer[:n], now)
 	if err != nil {
+		slog.Debug("Dropping status request", "addr", addr.String(), "err", err)
+		return
+	}
+	if !statusReplay.accept(req.Nonce, now) {
+		slog.Debug("Dropping replayed status request", "addr", addr.String())
 		return
 	}
+
 	//retrieve the current status
-	msgStatus, err := getCurrentStatusInformation()
+	status, err := getCurrentStatusInformation()
 	if err != nil {
-		log.Println(err)
-		msgStatus = []byte("")
+		slog.Warn("Could not get status information", "err", err)
+		return
+	}
+	msg, err := json.Marshal(statusAuth.newReply(req, status, time.Now()))
+	if err != nil || len(msg) > maxReplySize {
+		slog.Warn("Could not prepare status information", "size", len(msg), "err", err)
+		return
 	}
 	//send the infos back to the client edge-node
-	_, err = conn.WriteToUDP(msgStatus, addr)
+	_, err = conn.WriteToUDP(msg, addr)
 	if err != nil {
-		log.Println(err)
+		slog.Warn("Could not send status information", "addr", addr.String(), "err", err)
 	}
 }
 
 func getCurrentStatusInformation() (status []byte, err error) {
 	portNumber := config.GetInt("api.port", 1323)
-	url := fmt.Sprintf("http://%s:%d", utils.GetIpAddress().String(), portNumber)
+	url := utils.NodeUrl(utils.GetIpAddress().String(), portNumber)
 	response := StatusInformation{
 		Url:                     url,
 		AvailableWarmContainers: node.WarmStatus(),
@@ -82,42 +123,57 @@ func statusInfoRequest(hostname string) (info *StatusInformation, duration time.
 
 	remoteAddr, err := net.ResolveUDPAddr("udp", address)
 	if err != nil {
-		log.Printf("Unreachable server %s", address)
+		slog.Debug("Unreachable server", "addr", address, "err", err)
 		return nil, 0
 	}
 
 	udpConn, err := net.DialUDP("udp", nil, remoteAddr)
 	if err != nil {
-		log.Println(err)
+		slog.Debug("Could not contact server", "addr", address, "err", err)
 		return nil, 0
 	}
 	defer udpConn.Close()
 
-	// write a message to server, here 1 byte is enough
-	message := []byte("A")
+	req, err := statusAuth.newRequest(time.Now())
+	if err != nil {
+		slog.Warn("Could not prepare status request", "err", err)
+		return nil, 0
+	}
+	message, err := json.Marshal(req)
+	if err != nil {
+		slog.Warn("Could not prepare status request", "err", err)
+		return nil, 0
+	}
 	sendingTime := time.Now()
 	_, err = udpConn.Write(message)
 	if err != nil {
-		log.Println(err)
+		slog.Debug("Could not contact server", "addr", address, "err", err)
 		return nil, 0
 	}
 
 	// receive message from server
-	buffer := make([]byte, 1024)
-	_, _, err = udpConn.ReadFromUDP(buffer)
+	buffer := make([]byte, maxReplySize+1)
+	_ = udpConn.SetReadDeadline(sendingTime.Add(statusRequestTimeout))
+	n, err := udpConn.Read(buffer)
 	if err != nil {
-		log.Println(err)
+		slog.Debug("No status received from server", "addr", address, "err", err)
 		return nil, 0
 	}
 
 	rtt := time.Now().Sub(sendingTime)
-	buffer = bytes.Trim(buffer, "\x00")
-	//unmarshal result
-	var result StatusInformation
-	err = json.Unmarshal(buffer, &result)
+	result, err := statusAuth.parseReply(buffer[:n], req, time.Now())
 	if err != nil {
-		fmt.Println("Can not unmarshal JSON")
+		slog.Warn("Invalid status received from server", "addr", address, "err", err)
+		return nil, 0
+	}
+	// the reply must describe the server that was contacted
+	if u, err := url.Parse(result.Url); err != nil || u.Hostname() != hostname {
+		slog.Warn("Invalid status received from server", "addr", address, "url", result.Url)
+		return nil, 0
+	}
+	if !Reg.Client.GetCoordinate().IsCompatibleWith(&result.Coordinates) {
+		slog.Warn("Invalid status received from server", "addr", address, "err", "incompatible coordinates")
 		return nil, 0
 	}
-	return &result, rtt
+	return result, rtt
 }
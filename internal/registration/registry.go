@@ -2,11 +2,12 @@ package registration
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"time"
 
 	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/logging"
 	"github.com/grussorusso/serverledge/utils"
 	"github.com/lithammer/shortuuid"
 	_ "go.etcd.io/etcd/client/v3"
@@ -27,7 +28,7 @@ func (r *Registry) getEtcdKey(id string) (key string) {
 func (r *Registry) RegisterToEtcd(hostport string) (string, error) {
 	etcdClient, err := utils.GetEtcdClient()
 	if err != nil {
-		log.Fatal(UnavailableClientErr)
+		logging.Fatal("Registration failed", "err", UnavailableClientErr)
 		return "", UnavailableClientErr
 	}
 
@@ -37,15 +38,15 @@ func (r *Registry) RegisterToEtcd(hostport string) (string, error) {
 	r.Key = r.getEtcdKey(id)
 	resp, err := etcdClient.Grant(ctx, int64(TTL))
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Registration failed", "err", err)
 		return "", err
 	}
 
-	log.Printf("Registration key: %s\n", r.Key)
+	slog.Info("Registered to etcd", "key", r.Key)
 	// save couple (id, hostport) to the correct Area-dir on etcd
 	_, err = etcdClient.Put(ctx, r.Key, hostport, clientv3.WithLease(resp.ID))
 	if err != nil {
-		log.Fatal(IdRegistrationErr)
+		logging.Fatal("Registration failed", "err", IdRegistrationErr)
 		return "", IdRegistrationErr
 	}
 
@@ -54,7 +55,7 @@ func (r *Registry) RegisterToEtcd(hostport string) (string, error) {
 	// the key id will be kept alive until a fault will occur
 	keepAliveCh, err := etcdClient.KeepAlive(cancelCtx, resp.ID)
 	if err != nil || keepAliveCh == nil {
-		log.Fatal(KeepAliveErr)
+		logging.Fatal("Registration failed", "err", KeepAliveErr)
 		return "", KeepAliveErr
 	}
 
@@ -79,7 +80,7 @@ func (r *Registry) GetAll(remotes bool) (map[string]string, error) {
 	ctx, _ := context.WithTimeout(context.Background(), 1*time.Second)
 	etcdClient, err := utils.GetEtcdClient()
 	if err != nil {
-		log.Fatal(UnavailableClientErr)
+		logging.Fatal("Registration failed", "err", UnavailableClientErr)
 		return nil, UnavailableClientErr
 	}
 	//retrieve all url of the other servers under my Area
@@ -93,9 +94,9 @@ func (r *Registry) GetAll(remotes bool) (map[string]string, error) {
 		servers[string(s.Key)] = string(s.Value)
 		//audit todo delete the next line
 		if remotes {
-			log.Printf("found remote server at: %s", servers[string(s.Key)])
+			slog.Debug("Found remote server", "url", servers[string(s.Key)])
 		} else {
-			log.Printf("found edge server at: %s", servers[string(s.Key)])
+			slog.Debug("Found edge server", "url", servers[string(s.Key)])
 		}
 	}
 
@@ -108,7 +109,7 @@ func GetCloudNodes(region string) (map[string]string, error) {
 	ctx, _ := context.WithTimeout(context.Background(), 1*time.Second)
 	etcdClient, err := utils.GetEtcdClient()
 	if err != nil {
-		log.Fatal(UnavailableClientErr)
+		logging.Fatal("Registration failed", "err", UnavailableClientErr)
 		return nil, UnavailableClientErr
 	}
 
@@ -131,7 +132,7 @@ func GetCloudNodesInRegion(region string) (map[string]string, error) {
 	ctx, _ := context.WithTimeout(context.Background(), 1*time.Second)
 	etcdClient, err := utils.GetEtcdClient()
 	if err != nil {
-		log.Fatal(UnavailableClientErr)
+		logging.Fatal("Registration failed", "err", UnavailableClientErr)
 		return nil, UnavailableClientErr
 	}
 
@@ -152,7 +153,7 @@ func GetCloudNodesInRegion(region string) (map[string]string, error) {
 func (r *Registry) Deregister() (e error) {
 	etcdClient, err := utils.GetEtcdClient()
 	if err != nil {
-		log.Fatal(UnavailableClientErr)
+		logging.Fatal("Registration failed", "err", UnavailableClientErr)
 		return UnavailableClientErr
 	}
 
@@ -162,6 +163,6 @@ func (r *Registry) Deregister() (e error) {
 		return err
 	}
 
-	log.Println("Deregister : " + r.Key)
+	slog.Info("Deregistered from etcd", "key", r.Key)
 	return nil
 }
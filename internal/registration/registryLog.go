@@ -1,12 +1,14 @@
 package registration
 
 import (
-	"log"
+	"log/slog"
+	"net/url"
 	"reflect"
 	"sort"
 	"time"
 
 	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/logging"
 	"github.com/hexablock/vivaldi"
 )
 
@@ -19,7 +21,7 @@ func InitEdgeMonitoring(r *Registry) (e error) {
 
 	client, err := vivaldi.NewClient(defaultConfig)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Could not create the Vivaldi client", "err", err)
 		return err
 	}
 	Reg.Client = client
@@ -27,6 +29,7 @@ func InitEdgeMonitoring(r *Registry) (e error) {
 	Reg.serversMap = make(map[string]*StatusInformation)
 	Reg.NearbyServersMap = make(map[string]*StatusInformation)
 
+	initStatusProtocol()
 	// start listening for incoming udp connections; use case: edge-nodes request for status infos
 	go UDPStatusServer()
 	//complete monitoring phase at startup
@@ -56,17 +59,21 @@ func monitoring() {
 	defer Reg.RwMtx.Unlock()
 	etcdServerMap, err := Reg.GetAll(false)
 	if err != nil {
-		log.Println(err)
+		slog.Warn("Could not retrieve servers in the area", "err", err)
 		return
 	}
 
 	delete(etcdServerMap, Reg.Key) // not consider myself
-	for key, url := range etcdServerMap {
+	for key, serverUrl := range etcdServerMap {
 		oldInfo, ok := Reg.serversMap[key]
 
-		ip := url[7 : len(url)-5]
+		u, err := url.Parse(serverUrl)
+		if err != nil {
+			slog.Warn("Invalid server URL", "server", key, "url", serverUrl, "err", err)
+			continue
+		}
 		// use udp socket to retrieve infos about the edge-node status and rtt
-		newInfo, rtt := statusInfoRequest(ip)
+		newInfo, rtt := statusInfoRequest(u.Hostname())
 		if newInfo == nil {
 			//unreachable server
 			delete(Reg.serversMap, key)
@@ -121,8 +128,11 @@ func nearbyMonitoring() {
 	for key, info := range Reg.NearbyServersMap {
 		oldInfo, ok := Reg.serversMap[key]
 
-		ip := info.Url[7 : len(info.Url)-5]
-		newInfo, rtt := statusInfoRequest(ip)
+		u, err := url.Parse(info.Url)
+		if err != nil {
+			continue
+		}
+		newInfo, rtt := statusInfoRequest(u.Hostname())
 		if newInfo == nil {
 			//unreachable server
 			delete(Reg.serversMap, key)
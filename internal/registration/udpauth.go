@@ -0,0 +1,216 @@
+package registration
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Status requests and replies exchanged over UDP carry a random nonce and a
+// timestamp, and are authenticated with an HMAC computed with the secret
+// shared by the nodes of the cluster (registry.udp.secret). Replies echo the
+// nonce of the request, so that they cannot be replayed or spoofed.
+
+var InvalidStatusMsgErr = errors.New("invalid status message")
+
+const (
+	nonceSize = 16
+	// maxRequestSize is the max. size of (well-formed) status requests
+	maxRequestSize = 512
+	// maxReplySize is the max. size of status replies
+	maxReplySize = 16384
+	// maxTrackedEntries bounds the nonces and addresses remembered by the
+	// status server
+	maxTrackedEntries = 10000
+)
+
+type statusRequest struct {
+	Nonce     []byte
+	Timestamp int64 // Unix time in nanoseconds
+	MAC       []byte
+}
+
+type statusReply struct {
+	Nonce     []byte
+	Timestamp int64
+	Status    []byte // JSON-encoded StatusInformation
+	MAC       []byte
+}
+
+// udpAuth signs and verifies status messages.
+type udpAuth struct {
+	secret  []byte // messages are not authenticated if empty
+	maxSkew time.Duration
+}
+
+func computeMAC(secret []byte, kind byte, nonce []byte, timestamp int64, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte{kind})
+	mac.Write(nonce)
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(timestamp))
+	mac.Write(ts[:])
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// newRequest returns a signed status request with a fresh nonce.
+func (a *udpAuth) newRequest(now time.Time) (*statusRequest, error) {
+	req := &statusRequest{Nonce: make([]byte, nonceSize), Timestamp: now.UnixNano()}
+	if _, err := rand.Read(req.Nonce); err != nil {
+		return nil, err
+	}
+	if len(a.secret) > 0 {
+		req.MAC = computeMAC(a.secret, 'Q', req.Nonce, req.Timestamp, nil)
+	}
+	return req, nil
+}
+
+// newReply returns a signed reply to a status request.
+func (a *udpAuth) newReply(req *statusRequest, status []byte, now time.Time) *statusReply {
+	reply := &statusReply{Nonce: req.Nonce, Timestamp: now.UnixNano(), Status: status}
+	if len(a.secret) > 0 {
+		reply.MAC = computeMAC(a.secret, 'R', reply.Nonce, reply.Timestamp, reply.Status)
+	}
+	return reply
+}
+
+// check verifies the MAC and the timestamp of a message.
+func (a *udpAuth) check(kind byte, nonce []byte, timestamp int64, payload []byte, mac []byte, now time.Time) error {
+	if len(nonce) != nonceSize {
+		return fmt.Errorf("%w: bad nonce", InvalidStatusMsgErr)
+	}
+	if len(a.secret) > 0 && !hmac.Equal(mac, computeMAC(a.secret, kind, nonce, timestamp, payload)) {
+		return fmt.Errorf("%w: bad MAC", InvalidStatusMsgErr)
+	}
+	if skew := now.Sub(time.Unix(0, timestamp)); math.Abs(float64(skew)) > float64(a.maxSkew) {
+		return fmt.Errorf("%w: timestamp out of range (%v)", InvalidStatusMsgErr, skew)
+	}
+	return nil
+}
+
+// parseRequest decodes and verifies a status request.
+func (a *udpAuth) parseRequest(msg []byte, now time.Time) (*statusRequest, error) {
+	if len(msg) > maxRequestSize {
+		return nil, fmt.Errorf("%w: request too large", InvalidStatusMsgErr)
+	}
+	var req statusRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidStatusMsgErr, err)
+	}
+	if err := a.check('Q', req.Nonce, req.Timestamp, nil, req.MAC, now); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// parseReply decodes and verifies the reply to a status request.
+func (a *udpAuth) parseReply(msg []byte, req *statusRequest, now time.Time) (*StatusInformation, error) {
+	if len(msg) > maxReplySize {
+		return nil, fmt.Errorf("%w: reply too large", InvalidStatusMsgErr)
+	}
+	var reply statusReply
+	if err := json.Unmarshal(msg, &reply); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidStatusMsgErr, err)
+	}
+	if !hmac.Equal(reply.Nonce, req.Nonce) {
+		return nil, fmt.Errorf("%w: unexpected nonce", InvalidStatusMsgErr)
+	}
+	if err := a.check('R', reply.Nonce, reply.Timestamp, reply.Status, reply.MAC, now); err != nil {
+		return nil, err
+	}
+
+	var info StatusInformation
+	if err := json.Unmarshal(reply.Status, &info); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidStatusMsgErr, err)
+	}
+	if !info.Coordinates.IsValid() {
+		return nil, fmt.Errorf("%w: invalid coordinates", InvalidStatusMsgErr)
+	}
+	return &info, nil
+}
+
+// replayFilter remembers the nonces of the requests accepted recently.
+type replayFilter struct {
+	seen   map[string]time.Time // nonce -> expiration
+	window time.Duration
+}
+
+func newReplayFilter(window time.Duration) *replayFilter {
+	return &replayFilter{seen: make(map[string]time.Time), window: window}
+}
+
+// accept returns false if the nonce has already been seen, or if too many
+// nonces are remembered.
+func (f *replayFilter) accept(nonce []byte, now time.Time) bool {
+	if len(f.seen) >= maxTrackedEntries {
+		for n, exp := range f.seen {
+			if now.After(exp) {
+				delete(f.seen, n)
+			}
+		}
+		if len(f.seen) >= maxTrackedEntries {
+			return false
+		}
+	}
+	if exp, ok := f.seen[string(nonce)]; ok && now.Before(exp) {
+		return false
+	}
+	f.seen[string(nonce)] = now.Add(f.window)
+	return true
+}
+
+// addressLimiter limits the rate of requests from each address, allowing
+// bursts of up to one second of requests.
+type addressLimiter struct {
+	rate    float64
+	buckets map[string]*addressBucket
+}
+
+type addressBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newAddressLimiter(rate float64) *addressLimiter {
+	return &addressLimiter{rate: rate, buckets: make(map[string]*addressBucket)}
+}
+
+func (l *addressLimiter) allow(addr string, now time.Time) bool {
+	if l.rate <= 0 {
+		return true
+	}
+	burst := math.Max(1.0, l.rate)
+
+	b, ok := l.buckets[addr]
+	if !ok {
+		if len(l.buckets) >= maxTrackedEntries {
+			// forget addresses whose buckets are full again
+			for a, b := range l.buckets {
+				if now.Sub(b.last).Seconds()*l.rate >= burst {
+					delete(l.buckets, a)
+				}
+			}
+			if len(l.buckets) >= maxTrackedEntries {
+				return false
+			}
+		}
+		b = &addressBucket{tokens: burst, last: now}
+		l.buckets[addr] = b
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+
+	if b.tokens < 1.0 {
+		return false
+	}
+	b.tokens -= 1.0
+	return true
+}
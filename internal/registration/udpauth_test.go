@@ -0,0 +1,86 @@
+package registration
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatusExchange(t *testing.T) {
+	a := &udpAuth{secret: []byte("secret"), maxSkew: 30 * time.Second}
+	now := time.Now()
+
+	req, err := a.newRequest(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := json.Marshal(req)
+	parsedReq, err := a.parseRequest(msg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, _ := json.Marshal(StatusInformation{Url: "https://10.0.0.1:1323", AvailableMemMB: 1024})
+	reply, _ := json.Marshal(a.newReply(parsedReq, status, now))
+	info, err := a.parseReply(reply, req, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AvailableMemMB != 1024 {
+		t.Errorf("unexpected status: %+v", info)
+	}
+
+	// replies to other requests are rejected
+	other, _ := a.newRequest(now)
+	if _, err := a.parseReply(reply, other, now); !errors.Is(err, InvalidStatusMsgErr) {
+		t.Errorf("reply to another request accepted: %v", err)
+	}
+
+	// messages signed with another secret are rejected
+	wrong := &udpAuth{secret: []byte("wrong"), maxSkew: 30 * time.Second}
+	if _, err := wrong.parseRequest(msg, now); !errors.Is(err, InvalidStatusMsgErr) {
+		t.Errorf("request with wrong MAC accepted: %v", err)
+	}
+	if _, err := wrong.parseReply(reply, req, now); !errors.Is(err, InvalidStatusMsgErr) {
+		t.Errorf("reply with wrong MAC accepted: %v", err)
+	}
+
+	// stale and oversized messages are rejected
+	if _, err := a.parseRequest(msg, now.Add(time.Minute)); !errors.Is(err, InvalidStatusMsgErr) {
+		t.Errorf("stale request accepted: %v", err)
+	}
+	if _, err := a.parseRequest(make([]byte, maxRequestSize+1), now); !errors.Is(err, InvalidStatusMsgErr) {
+		t.Errorf("oversized request accepted: %v", err)
+	}
+}
+
+func TestReplayAndRateLimit(t *testing.T) {
+	now := time.Now()
+
+	f := newReplayFilter(time.Minute)
+	nonce := []byte("0123456789abcdef")
+	if !f.accept(nonce, now) {
+		t.Errorf("fresh nonce rejected")
+	}
+	if f.accept(nonce, now.Add(time.Second)) {
+		t.Errorf("replayed nonce accepted")
+	}
+
+	l := newAddressLimiter(2)
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if l.allow("10.0.0.1", now) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("allowed %d requests in a burst, expected 2", allowed)
+	}
+	if !l.allow("10.0.0.2", now) {
+		t.Errorf("request from another address rejected")
+	}
+	if !l.allow("10.0.0.1", now.Add(time.Second)) {
+		t.Errorf("request rejected after refill")
+	}
+}
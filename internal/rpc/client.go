@@ -0,0 +1,40 @@
+package rpc
+
+import (
+	"sync"
+
+	"github.com/grussorusso/serverledge/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var connections = make(map[string]*grpc.ClientConn)
+var connectionsMutex sync.Mutex
+
+// GetClient returns a client for the gRPC API exposed at the given target
+// (host:port). Connections are cached and shared among callers.
+func GetClient(target string) (ServerledgeClient, error) {
+	connectionsMutex.Lock()
+	defer connectionsMutex.Unlock()
+
+	if conn, ok := connections[target]; ok {
+		return NewServerledgeClient(conn), nil
+	}
+
+	creds := insecure.NewCredentials()
+	if utils.TLSEnabled() {
+		tlsConfig, err := utils.ClientTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	connections[target] = conn
+	return NewServerledgeClient(conn), nil
+}
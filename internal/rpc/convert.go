@@ -0,0 +1,185 @@
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/grussorusso/serverledge/internal/client"
+	"github.com/grussorusso/serverledge/internal/function"
+)
+
+// NewInvocationRequest builds a gRPC invocation request for a function.
+func NewInvocationRequest(funcName string, r *client.InvocationRequest) (*InvocationRequest, error) {
+	var params []byte
+	if r.Params != nil {
+		var err error
+		params, err = json.Marshal(r.Params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &InvocationRequest{
+		Function:        funcName,
+		Params:          params,
+		QosClass:        r.QoSClass,
+		QosMaxRespT:     r.QoSMaxRespT,
+		CanDoOffloading: r.CanDoOffloading,
+		Async:           r.Async,
+		Payload:         r.Payload,
+		ContentType:     r.ContentType,
+		Offloaded:       r.Offloaded,
+	}, nil
+}
+
+// ToClientRequest converts a gRPC invocation request into the
+// representation used by the REST API.
+func (x *InvocationRequest) ToClientRequest() (client.InvocationRequest, error) {
+	r := client.InvocationRequest{
+		QoSClass:        x.QosClass,
+		QoSMaxRespT:     x.QosMaxRespT,
+		CanDoOffloading: x.CanDoOffloading,
+		Async:           x.Async,
+		Payload:         x.Payload,
+		ContentType:     x.ContentType,
+		Offloaded:       x.Offloaded,
+	}
+	if len(x.Params) > 0 {
+		if err := json.Unmarshal(x.Params, &r.Params); err != nil {
+			return r, err
+		}
+	}
+
+	return r, nil
+}
+
+// FromExecutionReport converts an execution report into its gRPC representation.
+func FromExecutionReport(report *function.ExecutionReport) *ExecutionReport {
+	return &ExecutionReport{
+		Result:         report.Result,
+		RawResult:      report.RawResult,
+		ContentType:    report.ContentType,
+		ResponseTime:   report.ResponseTime,
+		IsWarmStart:    report.IsWarmStart,
+		Restored:       report.Restored,
+		InitTime:       report.InitTime,
+		OffloadLatency: report.OffloadLatency,
+		Duration:       report.Duration,
+		SchedAction:    report.SchedAction,
+		CpuSeconds:     report.CPUSeconds,
+		PeakMemoryMb:   report.PeakMemoryMB,
+		GbSeconds:      report.GBSeconds,
+	}
+}
+
+// ToExecutionReport converts a gRPC execution report into a function.ExecutionReport.
+func (x *ExecutionReport) ToExecutionReport() function.ExecutionReport {
+	return function.ExecutionReport{
+		Result:         x.GetResult(),
+		RawResult:      x.GetRawResult(),
+		ContentType:    x.GetContentType(),
+		ResponseTime:   x.GetResponseTime(),
+		IsWarmStart:    x.GetIsWarmStart(),
+		Restored:       x.GetRestored(),
+		InitTime:       x.GetInitTime(),
+		OffloadLatency: x.GetOffloadLatency(),
+		Duration:       x.GetDuration(),
+		SchedAction:    x.GetSchedAction(),
+		CPUSeconds:     x.GetCpuSeconds(),
+		PeakMemoryMB:   x.GetPeakMemoryMb(),
+		GBSeconds:      x.GetGbSeconds(),
+	}
+}
+
+// FromFunction converts a function into its gRPC representation.
+func FromFunction(f *function.Function) *Function {
+	return &Function{
+		Name:                      f.Name,
+		Runtime:                   f.Runtime,
+		MemoryMb:                  f.MemoryMB,
+		CpuDemand:                 f.CPUDemand,
+		Handler:                   f.Handler,
+		TarFunctionCode:           f.TarFunctionCode,
+		CustomImage:               f.CustomImage,
+		MaxConcurrencyPerInstance: int32(f.MaxConcurrencyPerInstance),
+		Owner:                     f.Owner,
+		Secrets:                   fromSecretRefs(f.Secrets),
+		Env:                       f.Env,
+		Version:                   f.Version,
+		Isolation:                 fromIsolation(f.Isolation),
+		CodeDigest:                f.CodeDigest,
+		CodeSignature:             f.CodeSignature,
+	}
+}
+
+// ToFunction converts a gRPC function definition into a function.Function.
+func (x *Function) ToFunction() *function.Function {
+	return &function.Function{
+		Name:                      x.Name,
+		Runtime:                   x.Runtime,
+		MemoryMB:                  x.MemoryMb,
+		CPUDemand:                 x.CpuDemand,
+		Handler:                   x.Handler,
+		TarFunctionCode:           x.TarFunctionCode,
+		CustomImage:               x.CustomImage,
+		MaxConcurrencyPerInstance: int(x.MaxConcurrencyPerInstance),
+		Owner:                     x.Owner,
+		Secrets:                   toSecretRefs(x.Secrets),
+		Env:                       x.Env,
+		Version:                   x.Version,
+		Isolation:                 x.Isolation.toIsolation(),
+		CodeDigest:                x.CodeDigest,
+		CodeSignature:             x.CodeSignature,
+	}
+}
+
+func fromSecretRefs(refs []function.SecretRef) []*SecretRef {
+	if len(refs) == 0 {
+		return nil
+	}
+	result := make([]*SecretRef, 0, len(refs))
+	for _, r := range refs {
+		result = append(result, &SecretRef{Name: r.Name, Env: r.Env})
+	}
+	return result
+}
+
+func toSecretRefs(refs []*SecretRef) []function.SecretRef {
+	if len(refs) == 0 {
+		return nil
+	}
+	result := make([]function.SecretRef, 0, len(refs))
+	for _, r := range refs {
+		result = append(result, function.SecretRef{Name: r.Name, Env: r.Env})
+	}
+	return result
+}
+
+func fromIsolation(i *function.Isolation) *Isolation {
+	if i == nil {
+		return nil
+	}
+	return &Isolation{
+		WritableRootfs: i.WritableRootfs,
+		ImageUser:      i.ImageUser,
+		Capabilities:   i.Capabilities,
+		PidsLimit:      i.PidsLimit,
+		Unconfined:     i.Unconfined,
+		Gvisor:         i.GVisor,
+		Network:        i.Network,
+	}
+}
+
+func (x *Isolation) toIsolation() *function.Isolation {
+	if x == nil {
+		return nil
+	}
+	return &function.Isolation{
+		WritableRootfs: x.WritableRootfs,
+		ImageUser:      x.ImageUser,
+		Capabilities:   x.Capabilities,
+		PidsLimit:      x.PidsLimit,
+		Unconfined:     x.Unconfined,
+		GVisor:         x.Gvisor,
+		Network:        x.Network,
+	}
+}
@@ -0,0 +1,400 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ServerledgeClient is the client API for Serverledge service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ServerledgeClient interface {
+	// Invoke runs a function and waits for its result (or returns a request
+	// ID for asynchronous invocations).
+	Invoke(ctx context.Context, in *InvocationRequest, opts ...grpc.CallOption) (*InvocationResponse, error)
+	// InvokeStream serves a stream of invocations over a single connection.
+	// Responses may be sent in a different order than requests; they can be
+	// matched using the Id field.
+	InvokeStream(ctx context.Context, opts ...grpc.CallOption) (Serverledge_InvokeStreamClient, error)
+	// PollAsyncResult retrieves the result of an asynchronous invocation.
+	PollAsyncResult(ctx context.Context, in *PollRequest, opts ...grpc.CallOption) (*InvocationResponse, error)
+	CreateFunction(ctx context.Context, in *Function, opts ...grpc.CallOption) (*CreateResponse, error)
+	// Fields with zero values are left unchanged
+	UpdateFunction(ctx context.Context, in *Function, opts ...grpc.CallOption) (*UpdateResponse, error)
+	DeleteFunction(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	ListFunctions(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*FunctionList, error)
+	GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusInformation, error)
+}
+
+type serverledgeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewServerledgeClient(cc grpc.ClientConnInterface) ServerledgeClient {
+	return &serverledgeClient{cc}
+}
+
+func (c *serverledgeClient) Invoke(ctx context.Context, in *InvocationRequest, opts ...grpc.CallOption) (*InvocationResponse, error) {
+	out := new(InvocationResponse)
+	err := c.cc.Invoke(ctx, "/serverledge.Serverledge/Invoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serverledgeClient) InvokeStream(ctx context.Context, opts ...grpc.CallOption) (Serverledge_InvokeStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Serverledge_ServiceDesc.Streams[0], "/serverledge.Serverledge/InvokeStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serverledgeInvokeStreamClient{stream}
+	return x, nil
+}
+
+type Serverledge_InvokeStreamClient interface {
+	Send(*InvocationRequest) error
+	Recv() (*InvocationResponse, error)
+	grpc.ClientStream
+}
+
+type serverledgeInvokeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *serverledgeInvokeStreamClient) Send(m *InvocationRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *serverledgeInvokeStreamClient) Recv() (*InvocationResponse, error) {
+	m := new(InvocationResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serverledgeClient) PollAsyncResult(ctx context.Context, in *PollRequest, opts ...grpc.CallOption) (*InvocationResponse, error) {
+	out := new(InvocationResponse)
+	err := c.cc.Invoke(ctx, "/serverledge.Serverledge/PollAsyncResult", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serverledgeClient) CreateFunction(ctx context.Context, in *Function, opts ...grpc.CallOption) (*CreateResponse, error) {
+	out := new(CreateResponse)
+	err := c.cc.Invoke(ctx, "/serverledge.Serverledge/CreateFunction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serverledgeClient) UpdateFunction(ctx context.Context, in *Function, opts ...grpc.CallOption) (*UpdateResponse, error) {
+	out := new(UpdateResponse)
+	err := c.cc.Invoke(ctx, "/serverledge.Serverledge/UpdateFunction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serverledgeClient) DeleteFunction(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/serverledge.Serverledge/DeleteFunction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serverledgeClient) ListFunctions(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*FunctionList, error) {
+	out := new(FunctionList)
+	err := c.cc.Invoke(ctx, "/serverledge.Serverledge/ListFunctions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serverledgeClient) GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusInformation, error) {
+	out := new(StatusInformation)
+	err := c.cc.Invoke(ctx, "/serverledge.Serverledge/GetStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ServerledgeServer is the server API for Serverledge service.
+// All implementations must embed UnimplementedServerledgeServer
+// for forward compatibility
+type ServerledgeServer interface {
+	// Invoke runs a function and waits for its result (or returns a request
+	// ID for asynchronous invocations).
+	Invoke(context.Context, *InvocationRequest) (*InvocationResponse, error)
+	// InvokeStream serves a stream of invocations over a single connection.
+	// Responses may be sent in a different order than requests; they can be
+	// matched using the Id field.
+	InvokeStream(Serverledge_InvokeStreamServer) error
+	// PollAsyncResult retrieves the result of an asynchronous invocation.
+	PollAsyncResult(context.Context, *PollRequest) (*InvocationResponse, error)
+	CreateFunction(context.Context, *Function) (*CreateResponse, error)
+	// Fields with zero values are left unchanged
+	UpdateFunction(context.Context, *Function) (*UpdateResponse, error)
+	DeleteFunction(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	ListFunctions(context.Context, *ListRequest) (*FunctionList, error)
+	GetStatus(context.Context, *StatusRequest) (*StatusInformation, error)
+	mustEmbedUnimplementedServerledgeServer()
+}
+
+// UnimplementedServerledgeServer must be embedded to have forward compatible implementations.
+type UnimplementedServerledgeServer struct {
+}
+
+func (UnimplementedServerledgeServer) Invoke(context.Context, *InvocationRequest) (*InvocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Invoke not implemented")
+}
+func (UnimplementedServerledgeServer) InvokeStream(Serverledge_InvokeStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeStream not implemented")
+}
+func (UnimplementedServerledgeServer) PollAsyncResult(context.Context, *PollRequest) (*InvocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PollAsyncResult not implemented")
+}
+func (UnimplementedServerledgeServer) CreateFunction(context.Context, *Function) (*CreateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateFunction not implemented")
+}
+func (UnimplementedServerledgeServer) UpdateFunction(context.Context, *Function) (*UpdateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateFunction not implemented")
+}
+func (UnimplementedServerledgeServer) DeleteFunction(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFunction not implemented")
+}
+func (UnimplementedServerledgeServer) ListFunctions(context.Context, *ListRequest) (*FunctionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFunctions not implemented")
+}
+func (UnimplementedServerledgeServer) GetStatus(context.Context, *StatusRequest) (*StatusInformation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedServerledgeServer) mustEmbedUnimplementedServerledgeServer() {}
+
+// UnsafeServerledgeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ServerledgeServer will
+// result in compilation errors.
+type UnsafeServerledgeServer interface {
+	mustEmbedUnimplementedServerledgeServer()
+}
+
+func RegisterServerledgeServer(s grpc.ServiceRegistrar, srv ServerledgeServer) {
+	s.RegisterService(&Serverledge_ServiceDesc, srv)
+}
+
+func _Serverledge_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServerledgeServer).Invoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/serverledge.Serverledge/Invoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServerledgeServer).Invoke(ctx, req.(*InvocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Serverledge_InvokeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ServerledgeServer).InvokeStream(&serverledgeInvokeStreamServer{stream})
+}
+
+type Serverledge_InvokeStreamServer interface {
+	Send(*InvocationResponse) error
+	Recv() (*InvocationRequest, error)
+	grpc.ServerStream
+}
+
+type serverledgeInvokeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *serverledgeInvokeStreamServer) Send(m *InvocationResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *serverledgeInvokeStreamServer) Recv() (*InvocationRequest, error) {
+	m := new(InvocationRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Serverledge_PollAsyncResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PollRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServerledgeServer).PollAsyncResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/serverledge.Serverledge/PollAsyncResult",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServerledgeServer).PollAsyncResult(ctx, req.(*PollRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Serverledge_CreateFunction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Function)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServerledgeServer).CreateFunction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/serverledge.Serverledge/CreateFunction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServerledgeServer).CreateFunction(ctx, req.(*Function))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Serverledge_UpdateFunction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Function)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServerledgeServer).UpdateFunction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/serverledge.Serverledge/UpdateFunction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServerledgeServer).UpdateFunction(ctx, req.(*Function))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Serverledge_DeleteFunction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServerledgeServer).DeleteFunction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/serverledge.Serverledge/DeleteFunction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServerledgeServer).DeleteFunction(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Serverledge_ListFunctions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServerledgeServer).ListFunctions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/serverledge.Serverledge/ListFunctions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServerledgeServer).ListFunctions(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Serverledge_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServerledgeServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/serverledge.Serverledge/GetStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServerledgeServer).GetStatus(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Serverledge_ServiceDesc is the grpc.ServiceDesc for Serverledge service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Serverledge_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "serverledge.Serverledge",
+	HandlerType: (*ServerledgeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Invoke",
+			Handler:    _Serverledge_Invoke_Handler,
+		},
+		{
+			MethodName: "PollAsyncResult",
+			Handler:    _Serverledge_PollAsyncResult_Handler,
+		},
+		{
+			MethodName: "CreateFunction",
+			Handler:    _Serverledge_CreateFunction_Handler,
+		},
+		{
+			MethodName: "UpdateFunction",
+			Handler:    _Serverledge_UpdateFunction_Handler,
+		},
+		{
+			MethodName: "DeleteFunction",
+			Handler:    _Serverledge_DeleteFunction_Handler,
+		},
+		{
+			MethodName: "ListFunctions",
+			Handler:    _Serverledge_ListFunctions_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Serverledge_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "InvokeStream",
+			Handler:       _Serverledge_InvokeStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "serverledge.proto",
+}
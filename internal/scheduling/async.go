@@ -4,38 +4,35 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/grussorusso/serverledge/internal/function"
 	"github.com/grussorusso/serverledge/utils"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+// ASYNC_RESULT_TTL is the retention time of asynchronous invocation results
+const ASYNC_RESULT_TTL = 1800 * time.Second
+
 func publishAsyncResponse(reqId string, response function.Response) {
-	etcdClient, err := utils.GetEtcdClient()
+	store, err := utils.GetKVStore()
 	if err != nil {
-		log.Fatal("Client not available")
+		slog.Error("Could not publish async result", "reqId", reqId, "err", err)
 		return
 	}
 
 	ctx := context.Background()
 
-	resp, err := etcdClient.Grant(ctx, 1800)
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
-
 	key := fmt.Sprintf("async/%s", reqId)
 	payload, err := json.Marshal(response)
 	if err != nil {
-		log.Printf("Could not marshal response: %v", err)
+		slog.Error("Could not marshal response", "reqId", reqId, "err", err)
 		return
 	}
 
-	_, err = etcdClient.Put(ctx, key, string(payload), clientv3.WithLease(resp.ID))
+	err = store.Put(ctx, key, payload, ASYNC_RESULT_TTL)
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("Could not publish async result", "reqId", reqId, "err", err)
 		return
 	}
 }
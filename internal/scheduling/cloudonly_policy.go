@@ -13,6 +13,6 @@ func (p *CloudOnlyPolicy) OnArrival(r *scheduledRequest) {
 	if r.CanDoOffloading {
 		handleCloudOffload(r)
 	} else {
-		dropRequest(r)
+		dropRequest(r, DROP_REASON_NO_RESOURCES)
 	}
 }
@@ -27,11 +27,11 @@ func (p *Custom1Policy) OnArrival(r *scheduledRequest) {
 		if url != "" {
 			handleOffload(r, url)
 		} else {
-			dropRequest(r)
+			dropRequest(r, DROP_REASON_NO_OFFLOAD_TARGET)
 		}
 	} else if r.CanDoOffloading {
 		handleCloudOffload(r)
 	} else {
-		dropRequest(r)
+		dropRequest(r, DROP_REASON_NO_RESOURCES)
 	}
 }
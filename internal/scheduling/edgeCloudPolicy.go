@@ -23,6 +23,6 @@ func (p *CloudEdgePolicy) OnArrival(r *scheduledRequest) {
 	} else if r.CanDoOffloading {
 		handleCloudOffload(r)
 	} else {
-		dropRequest(r)
+		dropRequest(r, DROP_REASON_NO_RESOURCES)
 	}
 }
@@ -1,8 +1,6 @@
 package scheduling
 
 import (
-	"log"
-
 	"github.com/grussorusso/serverledge/internal/node"
 )
 
@@ -17,21 +15,23 @@ func (p *EdgePolicy) OnCompletion(r *scheduledRequest) {
 }
 
 func (p *EdgePolicy) OnArrival(r *scheduledRequest) {
+	dropReason := DROP_REASON_NO_RESOURCES
 	if r.CanDoOffloading {
 		url := pickEdgeNodeForOffloading(r)
 		if url != "" {
 			handleOffload(r, url)
 			return
 		}
+		dropReason = DROP_REASON_NO_OFFLOAD_TARGET
 	} else {
 		containerID, err := node.AcquireWarmContainer(r.Fun)
 		if err == nil {
-			log.Printf("Using a warm container for: %v", r)
+			r.Logger().Debug("Using a warm container")
 			execLocally(r, containerID, true)
 		} else if handleColdStart(r) {
 			return
 		}
 	}
 
-	dropRequest(r)
+	dropRequest(r, dropReason)
 }
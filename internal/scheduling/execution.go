@@ -4,48 +4,79 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/grussorusso/serverledge/internal/billing"
 	"github.com/grussorusso/serverledge/internal/container"
 	"github.com/grussorusso/serverledge/internal/executor"
+	"github.com/grussorusso/serverledge/internal/node"
+	"github.com/grussorusso/serverledge/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const HANDLER_DIR = "/app"
 
 // Execute serves a request on the specified container.
 func Execute(contID container.ContainerID, r *scheduledRequest) error {
-	//log.Printf("[%s] Executing on container: %v", r, contID)
+	r.Logger().Debug("Executing", "container", contID)
 
 	var req executor.InvocationRequest
 	if r.Fun.Runtime == container.CUSTOM_RUNTIME {
 		req = executor.InvocationRequest{
-			Params: r.Params,
+			Params:      r.Params,
+			Payload:     r.Payload,
+			ContentType: r.ContentType,
 		}
 	} else {
 		cmd := container.RuntimeToInfo[r.Fun.Runtime].InvocationCmd
 		req = executor.InvocationRequest{
-			cmd,
-			r.Params,
-			r.Fun.Handler,
-			HANDLER_DIR,
+			Command:     cmd,
+			Params:      r.Params,
+			Handler:     r.Fun.Handler,
+			HandlerDir:  HANDLER_DIR,
+			Payload:     r.Payload,
+			ContentType: r.ContentType,
 		}
 	}
 
+	ctx, span := telemetry.StartSpan(r.Ctx, "execute",
+		attribute.String("serverledge.function", r.Fun.Name),
+		attribute.String("serverledge.container", contID),
+		attribute.Bool("serverledge.warm_start", r.ExecReport.IsWarmStart))
+	req.Env = telemetry.Env(ctx)
+
+	var statsBefore *container.Stats
+	if billing.Enabled {
+		statsBefore, _ = container.GetStats(contID)
+	}
+
 	t0 := time.Now()
 
 	response, invocationWait, err := container.Execute(contID, &req)
 	if err != nil {
 		// notify scheduler
 		completions <- &completion{scheduledRequest: r, contID: contID}
-		return fmt.Errorf("[%s] Execution failed: %v", r, err)
+		err = fmt.Errorf("[%s] Execution failed: %v", r, err)
+		telemetry.EndSpan(span, err)
+		return err
+	}
+
+	duration := time.Now().Sub(t0).Seconds() - invocationWait.Seconds()
+	if billing.Enabled {
+		accountUsage(r, contID, statsBefore, duration, response.Success)
 	}
 
 	if !response.Success {
 		// notify scheduler
 		completions <- &completion{scheduledRequest: r, contID: contID}
-		return fmt.Errorf("Function execution failed")
+		err = fmt.Errorf("Function execution failed")
+		telemetry.EndSpan(span, err)
+		return err
 	}
+	span.End()
 
 	r.ExecReport.Result = response.Result
-	r.ExecReport.Duration = time.Now().Sub(t0).Seconds() - invocationWait.Seconds()
+	r.ExecReport.RawResult = response.RawResult
+	r.ExecReport.ContentType = response.ContentType
+	r.ExecReport.Duration = duration
 	r.ExecReport.ResponseTime = time.Now().Sub(r.Arrival).Seconds()
 
 	// initializing containers may require invocation retries, adding
@@ -57,3 +88,37 @@ func Execute(contID container.ContainerID, r *scheduledRequest) error {
 
 	return nil
 }
+
+// accountUsage measures the resources used by a request served by a
+// container and produces its billing record. CPU time is measured for the
+// whole container: if the container serves concurrent requests, their usage
+// is not told apart.
+func accountUsage(r *scheduledRequest, contID container.ContainerID, statsBefore *container.Stats, duration float64, success bool) {
+	r.ExecReport.CPUSeconds = 0.0
+	r.ExecReport.PeakMemoryMB = 0.0
+	if statsBefore != nil {
+		if stats, err := container.GetStats(contID); err == nil {
+			r.ExecReport.CPUSeconds = (stats.CPUTime - statsBefore.CPUTime).Seconds()
+			r.ExecReport.PeakMemoryMB = float64(stats.PeakMemoryBytes) / 1048576
+		}
+	}
+	r.ExecReport.GBSeconds = float64(r.Fun.MemoryMB) / 1024 * duration
+
+	billing.Add(&billing.Record{
+		ReqId:        r.ReqId,
+		Function:     r.Fun.Name,
+		Namespace:    r.Fun.Namespace(),
+		Owner:        r.Fun.Owner,
+		Node:         node.NodeIdentifier,
+		Time:         time.Now(),
+		Success:      success,
+		Duration:     duration,
+		InitTime:     r.ExecReport.InitTime,
+		MemoryMB:     r.Fun.MemoryMB,
+		CPUSeconds:   r.ExecReport.CPUSeconds,
+		PeakMemoryMB: r.ExecReport.PeakMemoryMB,
+		GBSeconds:    r.ExecReport.GBSeconds,
+		WarmStart:    r.ExecReport.IsWarmStart,
+		Offloaded:    r.Offloaded,
+	})
+}
@@ -2,16 +2,20 @@ package scheduling
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"time"
 
+	"github.com/grussorusso/serverledge/internal/auth"
 	"github.com/grussorusso/serverledge/internal/client"
 	"github.com/grussorusso/serverledge/internal/function"
 	"github.com/grussorusso/serverledge/internal/registration"
+	"github.com/grussorusso/serverledge/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const SCHED_ACTION_OFFLOAD = "O"
@@ -36,20 +40,57 @@ func pickEdgeNodeForOffloading(r *scheduledRequest) (url string) {
 	return ""
 }
 
+// postOffloadedInvocation sends an invocation request to a remote node,
+// asking for a JSON-encoded response even if the function returns binary
+// output.
+func postOffloadedInvocation(ctx context.Context, url string, invocationBody []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(invocationBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	telemetry.InjectHTTP(ctx, req.Header)
+	auth.SetNodeCredentials(req.Header)
+	return offloadingClient.Do(req)
+}
+
+// startOffloadSpan starts the span of a request offloaded to serverUrl.
+func startOffloadSpan(r *function.Request, serverUrl string) (context.Context, trace.Span) {
+	return telemetry.StartSpan(r.Ctx, "offload",
+		attribute.String("serverledge.function", r.Fun.Name),
+		attribute.String("serverledge.target", serverUrl),
+		attribute.Bool("serverledge.async", r.Async))
+}
+
 func Offload(r *function.Request, serverUrl string) error {
+	ctx, span := startOffloadSpan(r, serverUrl)
+	err := offload(ctx, r, serverUrl)
+	telemetry.EndSpan(span, err)
+	return err
+}
+
+func offload(ctx context.Context, r *function.Request, serverUrl string) error {
+	if offloadingTransport == OFFLOADING_TRANSPORT_GRPC {
+		return OffloadWithGRPC(ctx, r, serverUrl)
+	}
+
 	// Prepare request
-	request := client.InvocationRequest{Params: r.Params, QoSClass: int64(r.Class), QoSMaxRespT: r.MaxRespT}
+	request := client.InvocationRequest{Params: r.Params,
+		QoSClass:    int64(r.Class),
+		QoSMaxRespT: r.MaxRespT,
+		Payload:     r.Payload,
+		ContentType: r.ContentType,
+		Offloaded:   true}
 	invocationBody, err := json.Marshal(request)
 	if err != nil {
-		log.Print(err)
 		return err
 	}
 	sendingTime := time.Now() // used to compute latency later on
-	resp, err := offloadingClient.Post(serverUrl+"/invoke/"+r.Fun.Name, "application/json",
-		bytes.NewBuffer(invocationBody))
+	resp, err := postOffloadedInvocation(ctx, serverUrl+"/invoke/"+r.Fun.Name, invocationBody)
 
 	if err != nil {
-		log.Print(err)
+		r.Logger().Warn("Offloading failed", "target", serverUrl, "err", err)
 		return err
 	}
 	if resp.StatusCode != http.StatusOK {
@@ -73,21 +114,33 @@ func Offload(r *function.Request, serverUrl string) error {
 }
 
 func OffloadAsync(r *function.Request, serverUrl string) error {
+	ctx, span := startOffloadSpan(r, serverUrl)
+	err := offloadAsync(ctx, r, serverUrl)
+	telemetry.EndSpan(span, err)
+	return err
+}
+
+func offloadAsync(ctx context.Context, r *function.Request, serverUrl string) error {
+	if offloadingTransport == OFFLOADING_TRANSPORT_GRPC {
+		return OffloadAsyncWithGRPC(ctx, r, serverUrl)
+	}
+
 	// Prepare request
 	request := client.InvocationRequest{Params: r.Params,
 		QoSClass:    int64(r.Class),
 		QoSMaxRespT: r.MaxRespT,
-		Async:       true}
+		Async:       true,
+		Payload:     r.Payload,
+		ContentType: r.ContentType,
+		Offloaded:   true}
 	invocationBody, err := json.Marshal(request)
 	if err != nil {
-		log.Print(err)
 		return err
 	}
-	resp, err := offloadingClient.Post(serverUrl+"/invoke/"+r.Fun.Name, "application/json",
-		bytes.NewBuffer(invocationBody))
+	resp, err := postOffloadedInvocation(ctx, serverUrl+"/invoke/"+r.Fun.Name, invocationBody)
 
 	if err != nil {
-		log.Print(err)
+		r.Logger().Warn("Offloading failed", "target", serverUrl, "err", err)
 		return err
 	}
 	if resp.StatusCode != http.StatusOK {
@@ -0,0 +1,86 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/auth"
+	"github.com/grussorusso/serverledge/internal/client"
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/rpc"
+	"github.com/grussorusso/serverledge/internal/telemetry"
+)
+
+const OFFLOADING_TRANSPORT_GRPC = "grpc"
+
+// grpcTarget returns the address of the gRPC API of the node reachable at
+// serverUrl. The gRPC port is assumed to be the same on every node.
+func grpcTarget(serverUrl string) (string, error) {
+	u, err := url.Parse(serverUrl)
+	if err != nil {
+		return "", err
+	}
+	port := config.GetInt(config.API_GRPC_PORT, 50051)
+	return net.JoinHostPort(u.Hostname(), fmt.Sprintf("%d", port)), nil
+}
+
+func offloadWithGRPC(ctx context.Context, r *function.Request, serverUrl string, async bool) (*function.ExecutionReport, error) {
+	target, err := grpcTarget(serverUrl)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := rpc.GetClient(target)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := rpc.NewInvocationRequest(r.Fun.Name, &client.InvocationRequest{
+		Params:      r.Params,
+		QoSClass:    int64(r.Class),
+		QoSMaxRespT: r.MaxRespT,
+		Async:       async,
+		Payload:     r.Payload,
+		ContentType: r.ContentType,
+		Offloaded:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cli.Invoke(auth.WithNodeCredentials(telemetry.InjectGRPC(ctx)), request)
+	if err != nil {
+		return nil, err
+	}
+	if async {
+		return nil, nil
+	}
+
+	report := resp.Report.ToExecutionReport()
+	return &report, nil
+}
+
+// OffloadWithGRPC offloads a request to another node through its gRPC API.
+func OffloadWithGRPC(ctx context.Context, r *function.Request, serverUrl string) error {
+	sendingTime := time.Now() // used to compute latency later on
+	report, err := offloadWithGRPC(ctx, r, serverUrl, false)
+	if err != nil {
+		return err
+	}
+	r.ExecReport = *report
+
+	r.ExecReport.OffloadLatency = time.Now().Sub(sendingTime).Seconds() - r.ExecReport.Duration - r.ExecReport.InitTime
+	r.ExecReport.SchedAction = SCHED_ACTION_OFFLOAD
+
+	return nil
+}
+
+// OffloadAsyncWithGRPC offloads an asynchronous request to another node
+// through its gRPC API.
+func OffloadAsyncWithGRPC(ctx context.Context, r *function.Request, serverUrl string) error {
+	_, err := offloadWithGRPC(ctx, r, serverUrl, true)
+	return err
+}
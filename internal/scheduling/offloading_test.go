@@ -0,0 +1,88 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grussorusso/serverledge/internal/client"
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/spf13/viper"
+)
+
+// newRemoteNode starts a fake node that serves every invocation with the
+// given result.
+func newRemoteNode(t *testing.T, result string) (*httptest.Server, chan client.InvocationRequest) {
+	received := make(chan client.InvocationRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req client.InvocationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- req
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(function.Response{
+			Success:         true,
+			ExecutionReport: function.ExecutionReport{Result: result},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, received
+}
+
+func TestOffloadToCloud(t *testing.T) {
+	srv, received := newRemoteNode(t, `"remote"`)
+	viper.Set(config.CLOUD_URL, srv.URL)
+	defer viper.Set(config.CLOUD_URL, nil)
+
+	f := setupScheduler(t, &CloudOnlyPolicy{}, 1024, 2.0)
+	fun := &function.Function{Name: "offloaded", Runtime: "python310", MemoryMB: 128, CPUDemand: 1.0}
+
+	r := newTestRequest(fun)
+	r.Params = map[string]interface{}{"a": 1.0}
+	if err := SubmitRequest(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.ExecReport.SchedAction != SCHED_ACTION_OFFLOAD || r.ExecReport.Result != `"remote"` {
+		t.Errorf("unexpected report: %+v", r.ExecReport)
+	}
+	req := <-received
+	if req.Params["a"] != 1.0 || req.CanDoOffloading {
+		t.Errorf("unexpected offloaded request: %+v", req)
+	}
+	if f.Created() != 0 {
+		t.Errorf("no container should be created locally")
+	}
+}
+
+func TestNoOffloadingAllowed(t *testing.T) {
+	setupScheduler(t, &CloudOnlyPolicy{}, 1024, 2.0)
+	fun := &function.Function{Name: "local", Runtime: "python310", MemoryMB: 128, CPUDemand: 1.0}
+
+	r := newTestRequest(fun)
+	r.CanDoOffloading = false
+	if err := SubmitRequest(r); err == nil {
+		t.Errorf("request should be dropped")
+	}
+}
+
+func TestOffloadingFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	viper.Set(config.CLOUD_URL, srv.URL)
+	defer viper.Set(config.CLOUD_URL, nil)
+
+	setupScheduler(t, &CloudOnlyPolicy{}, 1024, 2.0)
+	fun := &function.Function{Name: "rejected", Runtime: "python310", MemoryMB: 128, CPUDemand: 1.0}
+
+	if err := SubmitRequest(newTestRequest(fun)); err == nil {
+		t.Errorf("expected offloading failure")
+	}
+}
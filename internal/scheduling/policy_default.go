@@ -2,7 +2,7 @@ package scheduling
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 
 	"github.com/grussorusso/serverledge/internal/config"
 	"github.com/grussorusso/serverledge/internal/node"
@@ -15,7 +15,7 @@ type DefaultLocalPolicy struct {
 func (p *DefaultLocalPolicy) Init() {
 	queueCapacity := config.GetInt(config.SCHEDULER_QUEUE_CAPACITY, 0)
 	if queueCapacity > 0 {
-		log.Printf("Configured queue with capacity %d", queueCapacity)
+		slog.Info("Configured queue", "capacity", queueCapacity)
 		p.queue = NewFIFOQueue(queueCapacity)
 	} else {
 		p.queue = nil
@@ -38,34 +38,34 @@ func (p *DefaultLocalPolicy) OnCompletion(completed *scheduledRequest) {
 	containerID, err := node.AcquireWarmContainer(req.Fun)
 	if err == nil {
 		p.queue.Dequeue()
-		log.Printf("[%s] Warm start from the queue (length=%d)", req, p.queue.Len())
+		req.Logger().Debug("Warm start from the queue", "queueLength", p.queue.Len())
 		execLocally(req, containerID, true)
 		return
 	}
 
 	if errors.Is(err, node.NoWarmFoundErr) {
 		if node.AcquireResources(req.Fun.CPUDemand, req.Fun.MemoryMB, true) {
-			log.Printf("[%s] Cold start from the queue", req)
+			req.Logger().Debug("Cold start from the queue")
 			p.queue.Dequeue()
 
 			// This avoids blocking the thread during the cold
 			// start, but also allows us to check for resource
 			// availability before dequeueing
-			go func() {
-				newContainer, err := node.NewContainerWithAcquiredResources(req.Fun)
+			runInBackground(func() {
+				newContainer, err := coldStart(req, true)
 				if err != nil {
-					dropRequest(req)
+					dropRequest(req, DROP_REASON_COLD_START_FAILED)
 				} else {
 					execLocally(req, newContainer, false)
 				}
-			}()
+			})
 			return
 		}
 	} else if errors.Is(err, node.OutOfResourcesErr) {
 	} else {
 		// other error
 		p.queue.Dequeue()
-		dropRequest(req)
+		dropRequest(req, DROP_REASON_ERROR)
 	}
 }
 
@@ -84,7 +84,7 @@ func (p *DefaultLocalPolicy) OnArrival(r *scheduledRequest) {
 		// pass
 	} else {
 		// other error
-		dropRequest(r)
+		dropRequest(r, DROP_REASON_ERROR)
 		return
 	}
 
@@ -93,10 +93,12 @@ func (p *DefaultLocalPolicy) OnArrival(r *scheduledRequest) {
 		p.queue.Lock()
 		defer p.queue.Unlock()
 		if p.queue.Enqueue(r) {
-			log.Printf("[%s] Added to queue (length=%d)", r, p.queue.Len())
+			r.Logger().Debug("Added to queue", "queueLength", p.queue.Len())
 			return
 		}
+		dropRequest(r, DROP_REASON_QUEUE_FULL)
+		return
 	}
 
-	dropRequest(r)
+	dropRequest(r, DROP_REASON_NO_RESOURCES)
 }
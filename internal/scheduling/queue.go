@@ -1,6 +1,11 @@
 package scheduling
 
-import "sync"
+import (
+	"sync"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/metrics"
+)
 
 type queue interface {
 	Enqueue(r *scheduledRequest) bool
@@ -51,9 +56,13 @@ func (q *FIFOQueue) Enqueue(v *scheduledRequest) bool {
 		return false
 	}
 
+	v.enqueued = time.Now()
 	q.data[q.tail] = v
 	q.tail = (q.tail + 1) % q.capacity
 	q.size = q.size + 1
+	if metrics.Enabled {
+		metrics.SetQueueLength(q.size)
+	}
 	return true
 }
 
@@ -65,6 +74,10 @@ func (q *FIFOQueue) Dequeue() *scheduledRequest {
 	v := q.data[q.head]
 	q.head = (q.head + 1) % q.capacity
 	q.size = q.size - 1
+	recordQueueWait(v)
+	if metrics.Enabled {
+		metrics.SetQueueLength(q.size)
+	}
 	return v
 }
 
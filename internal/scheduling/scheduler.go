@@ -3,18 +3,23 @@ package scheduling
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"runtime"
 	"time"
 
 	"github.com/grussorusso/serverledge/internal/metrics"
 	"github.com/grussorusso/serverledge/internal/node"
+	"github.com/grussorusso/serverledge/internal/quota"
 
 	"github.com/grussorusso/serverledge/internal/config"
 
 	"github.com/grussorusso/serverledge/internal/container"
 	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/logging"
+	"github.com/grussorusso/serverledge/internal/telemetry"
+	"github.com/grussorusso/serverledge/utils"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var requests chan *scheduledRequest
@@ -24,57 +29,123 @@ var remoteServerUrl string
 var executionLogEnabled bool
 
 var offloadingClient *http.Client
+var offloadingTransport string
 
-func Run(p Policy) {
-	requests = make(chan *scheduledRequest, 500)
-	completions = make(chan *completion, 500)
+// runInBackground runs work that must not block the scheduler (e.g., cold
+// starts for queued requests). Simulations replace it to run synchronously.
+var runInBackground = func(f func()) { go f() }
 
+func Run(p Policy) {
 	// initialize Resources resources
 	availableCores := runtime.NumCPU()
 	node.Resources.AvailableMemMB = int64(config.GetInt(config.POOL_MEMORY_MB, 1024))
 	node.Resources.AvailableCPUs = config.GetFloat(config.POOL_CPUS, float64(availableCores))
 	node.Resources.ContainerPools = make(map[string]*node.ContainerPool)
-	log.Printf("Current resources: %v", node.Resources)
+	quota.SetCapacity(node.Resources.AvailableCPUs, node.Resources.AvailableMemMB)
+	slog.Info("Current resources", "cpus", node.Resources.AvailableCPUs, "memMB", node.Resources.AvailableMemMB)
 
-	container.InitDockerContainerFactory()
+	if _, err := container.InitContainerFactory(); err != nil {
+		logging.Fatal("Could not initialize the container factory", "err", err)
+	}
 
 	//janitor periodically remove expired warm container
 	node.GetJanitorInstance()
 
+	Start(p)
+}
+
+// Start starts serving requests in background, using the node resources and
+// the container factory that have already been set up (e.g., by Run or by a
+// simulator).
+func Start(p Policy) {
+	requests = make(chan *scheduledRequest, 500)
+	completions = make(chan *completion, 500)
+
 	tr := &http.Transport{
 		MaxIdleConns:        2500,
 		MaxIdleConnsPerHost: 2500,
 		MaxConnsPerHost:     0,
 		IdleConnTimeout:     30 * time.Minute,
 	}
+	if utils.TLSEnabled() {
+		// other nodes are verified with the CA, and this node is
+		// authenticated with its certificate
+		tlsConfig, err := utils.ClientTLSConfig()
+		if err != nil {
+			logging.Fatal("Invalid TLS configuration", "err", err)
+		}
+		tr.TLSClientConfig = tlsConfig
+	}
 	offloadingClient = &http.Client{Transport: tr}
+	offloadingTransport = config.GetString(config.SCHEDULER_OFFLOADING_TRANSPORT, "http")
 
 	// initialize scheduling policy
 	p.Init()
 
 	remoteServerUrl = config.GetString(config.CLOUD_URL, "")
 
-	log.Println("Scheduler started.")
+	slog.Info("Scheduler started", "policy", fmt.Sprintf("%T", p))
+	go schedule(p)
+}
 
+// schedule serves arrivals and completions according to the policy.
+func schedule(p Policy) {
 	var r *scheduledRequest
 	var c *completion
 	for {
 		select {
 		case r = <-requests:
-			go p.OnArrival(r)
+			go arrival(p, r)
 		case c = <-completions:
 			node.ReleaseContainer(c.contID, c.Fun)
+			releaseQuota(c.scheduledRequest)
 			p.OnCompletion(c.scheduledRequest)
 
 			if metrics.Enabled {
 				metrics.AddCompletedInvocation(c.Fun.Name)
 				if c.ExecReport.SchedAction != SCHED_ACTION_OFFLOAD {
 					metrics.AddFunctionDurationValue(c.Fun.Name, c.ExecReport.Duration)
+					metrics.AddStart(c.Fun.Name, c.ExecReport.IsWarmStart, c.ExecReport.InitTime)
 				}
 			}
 		}
 	}
+}
+
+// arrival passes a request to the policy, unless it exceeds the resource
+// quota of its function or namespace.
+func arrival(p Policy, r *scheduledRequest) {
+	if !quota.Acquire(r.Fun) {
+		dropRequest(r, DROP_REASON_QUOTA)
+		return
+	}
+	r.quotaAcquired = true
+	p.OnArrival(r)
+}
+
+// releaseQuota gives back the quota share held by a request, once it has
+// been completed, offloaded or dropped.
+func releaseQuota(r *scheduledRequest) {
+	if r.quotaAcquired {
+		quota.Release(r.Fun)
+		r.quotaAcquired = false
+	}
+}
 
+// CreatePolicy returns the scheduling policy with the given name
+// (e.g., "default", "cloudonly", "edgecloud", "edgeonly", "custom1").
+func CreatePolicy(name string) Policy {
+	if name == "cloudonly" {
+		return &CloudOnlyPolicy{}
+	} else if name == "edgecloud" {
+		return &CloudEdgePolicy{}
+	} else if name == "edgeonly" {
+		return &EdgePolicy{}
+	} else if name == "custom1" {
+		return &Custom1Policy{}
+	} else {
+		return &DefaultLocalPolicy{}
+	}
 }
 
 // SubmitRequest submits a newly arrived request for scheduling and execution
@@ -82,21 +153,16 @@ func SubmitRequest(r *function.Request) error {
 	schedRequest := scheduledRequest{
 		Request:         r,
 		decisionChannel: make(chan schedDecision, 1)}
-	requests <- &schedRequest
-
-	// wait on channel for scheduling action
-	schedDecision, ok := <-schedRequest.decisionChannel
+	schedDecision, ok := submit(&schedRequest)
 	if !ok {
 		return fmt.Errorf("could not schedule the request")
 	}
-	//log.Printf("[%s] Scheduling decision: %v", r, schedDecision)
+	r.Logger().Debug("Scheduling decision", "action", schedDecision.action.String(), "remoteHost", schedDecision.remoteHost)
 
 	var err error
 	if schedDecision.action == DROP {
-		//log.Printf("[%s] Dropping request", r)
 		return node.OutOfResourcesErr
 	} else if schedDecision.action == EXEC_REMOTE {
-		//log.Printf("Offloading request")
 		err = Offload(r, schedDecision.remoteHost)
 		if err != nil {
 			return err
@@ -107,6 +173,10 @@ func SubmitRequest(r *function.Request) error {
 			return err
 		}
 	}
+
+	if metrics.Enabled {
+		metrics.AddResponseTime(r.Class.String(), time.Now().Sub(r.Arrival).Seconds())
+	}
 	return nil
 }
 
@@ -115,20 +185,18 @@ func SubmitAsyncRequest(r *function.Request) {
 	schedRequest := scheduledRequest{
 		Request:         r,
 		decisionChannel: make(chan schedDecision, 1)}
-	requests <- &schedRequest
-
-	// wait on channel for scheduling action
-	schedDecision, ok := <-schedRequest.decisionChannel
+	schedDecision, ok := submit(&schedRequest)
 	if !ok {
 		publishAsyncResponse(r.ReqId, function.Response{Success: false})
 		return
 	}
 
+	r.Logger().Debug("Scheduling decision", "action", schedDecision.action.String(), "remoteHost", schedDecision.remoteHost)
+
 	var err error
 	if schedDecision.action == DROP {
 		publishAsyncResponse(r.ReqId, function.Response{Success: false})
 	} else if schedDecision.action == EXEC_REMOTE {
-		//log.Printf("Offloading request")
 		err = OffloadAsync(r, schedDecision.remoteHost)
 		if err != nil {
 			publishAsyncResponse(r.ReqId, function.Response{Success: false})
@@ -139,13 +207,53 @@ func SubmitAsyncRequest(r *function.Request) {
 			publishAsyncResponse(r.ReqId, function.Response{Success: false})
 		}
 		publishAsyncResponse(r.ReqId, function.Response{Success: true, ExecutionReport: r.ExecReport})
+		if metrics.Enabled {
+			metrics.AddResponseTime(r.Class.String(), time.Now().Sub(r.Arrival).Seconds())
+		}
+	}
+}
+
+// submit passes a request to the scheduler and waits for the scheduling
+// decision.
+func submit(r *scheduledRequest) (schedDecision, bool) {
+	ctx, span := telemetry.StartSpan(r.Ctx, "schedule", attribute.String("serverledge.function", r.Fun.Name))
+	r.ctx = ctx
+	requests <- r
+
+	// wait on channel for scheduling action
+	decision, ok := <-r.decisionChannel
+	span.SetAttributes(attribute.String("serverledge.action", decision.action.String()))
+	span.End()
+	return decision, ok
+}
+
+// recordQueueWait records the time spent by a request in the queue.
+func recordQueueWait(r *scheduledRequest) {
+	telemetry.RecordSpan(r.ctx, "queue", r.enqueued)
+	if metrics.Enabled {
+		metrics.AddQueueWait(time.Now().Sub(r.enqueued).Seconds())
+	}
+}
+
+// coldStart creates a container to serve a request.
+func coldStart(r *scheduledRequest, resourcesAcquired bool) (container.ContainerID, error) {
+	_, span := telemetry.StartSpan(r.ctx, "cold-start", attribute.String("serverledge.function", r.Fun.Name))
+	var contID container.ContainerID
+	var err error
+	if resourcesAcquired {
+		contID, err = node.NewContainerWithAcquiredResources(r.Fun)
+	} else {
+		contID, err = node.NewContainer(r.Fun)
 	}
+	span.SetAttributes(attribute.String("serverledge.container", contID))
+	telemetry.EndSpan(span, err)
+	return contID, err
 }
 
 func handleColdStart(r *scheduledRequest) (isSuccess bool) {
-	newContainer, err := node.NewContainer(r.Fun)
+	newContainer, err := coldStart(r, false)
 	if errors.Is(err, node.OutOfResourcesErr) || err != nil {
-		log.Printf("Cold start failed: %v", err)
+		r.Logger().Warn("Cold start failed", "err", err)
 		return false
 	} else {
 		execLocally(r, newContainer, false)
@@ -153,7 +261,27 @@ func handleColdStart(r *scheduledRequest) (isSuccess bool) {
 	}
 }
 
-func dropRequest(r *scheduledRequest) {
+// Reasons for dropping requests
+const (
+	DROP_REASON_NO_RESOURCES      = "no_resources"
+	DROP_REASON_QUEUE_FULL        = "queue_full"
+	DROP_REASON_NO_OFFLOAD_TARGET = "no_offload_target"
+	DROP_REASON_COLD_START_FAILED = "cold_start_failed"
+	DROP_REASON_ERROR             = "error"
+	DROP_REASON_QUOTA             = "quota"
+	DROP_REASON_RATE_LIMIT        = "rate_limit"
+)
+
+func dropRequest(r *scheduledRequest, reason string) {
+	r.Logger().Debug("Dropping request", "reason", reason)
+	releaseQuota(r)
+	node.Resources.Lock()
+	node.Resources.DropCount++
+	node.Resources.Unlock()
+	if metrics.Enabled {
+		metrics.AddDroppedRequest(r.Fun.Name, reason)
+	}
+
 	r.decisionChannel <- schedDecision{action: DROP}
 }
 
@@ -161,12 +289,17 @@ func execLocally(r *scheduledRequest, c container.ContainerID, warmStart bool) {
 	initTime := time.Now().Sub(r.Arrival).Seconds()
 	r.ExecReport.InitTime = initTime
 	r.ExecReport.IsWarmStart = warmStart
+	r.ExecReport.Restored = !warmStart && node.IsRestored(c, r.Fun)
 
 	decision := schedDecision{action: EXEC_LOCAL, contID: c}
 	r.decisionChannel <- decision
 }
 
 func handleOffload(r *scheduledRequest, serverHost string) {
+	if metrics.Enabled {
+		metrics.AddOffloadedRequest(r.Fun.Name, serverHost)
+	}
+	releaseQuota(r)
 	r.CanDoOffloading = false // the next server can't offload this request
 	r.decisionChannel <- schedDecision{
 		action:     EXEC_REMOTE,
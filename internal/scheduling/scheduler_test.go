@@ -0,0 +1,206 @@
+package scheduling
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/container"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/node"
+	"github.com/grussorusso/serverledge/utils"
+	"github.com/spf13/viper"
+)
+
+// testPolicy forwards events to the policy under test, so that a single
+// scheduler loop can be shared by all the tests.
+type testPolicy struct {
+	sync.RWMutex
+	p Policy
+}
+
+func (tp *testPolicy) Init() {}
+
+func (tp *testPolicy) get() Policy {
+	tp.RLock()
+	defer tp.RUnlock()
+	return tp.p
+}
+
+func (tp *testPolicy) OnArrival(r *scheduledRequest) {
+	tp.get().OnArrival(r)
+}
+
+func (tp *testPolicy) OnCompletion(r *scheduledRequest) {
+	tp.get().OnCompletion(r)
+}
+
+var schedulerOnce sync.Once
+var currentTestPolicy = &testPolicy{}
+
+// setupScheduler starts the scheduler (once) with an in-memory key-value
+// store, and resets the node with a fake container factory.
+func setupScheduler(t testing.TB, p Policy, memMB int64, cpus float64) *container.FakeFactory {
+	schedulerOnce.Do(func() {
+		requests = make(chan *scheduledRequest, 500)
+		completions = make(chan *completion, 500)
+		offloadingClient = &http.Client{}
+		utils.SetKVStore(utils.NewMemoryKVStore())
+		go schedule(currentTestPolicy)
+	})
+
+	f := container.NewFakeFactory()
+	container.SetFactory(f)
+
+	node.Resources.Lock()
+	node.Resources.AvailableMemMB = memMB
+	node.Resources.AvailableCPUs = cpus
+	node.Resources.ContainerPools = make(map[string]*node.ContainerPool)
+	node.Resources.Unlock()
+
+	p.Init()
+	currentTestPolicy.Lock()
+	currentTestPolicy.p = p
+	currentTestPolicy.Unlock()
+
+	return f
+}
+
+func newTestRequest(fun *function.Function) *function.Request {
+	r := &function.Request{
+		Fun:             fun,
+		ReqId:           fun.Name + "-" + time.Now().Format(time.RFC3339Nano),
+		Params:          map[string]interface{}{},
+		Arrival:         time.Now(),
+		CanDoOffloading: true,
+	}
+	r.SetLogger()
+	return r
+}
+
+// waitForCPUs waits until the scheduler has processed completions, releasing
+// the CPUs.
+func waitForCPUs(t testing.TB, cpus float64) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		node.Resources.RLock()
+		available := node.Resources.AvailableCPUs
+		node.Resources.RUnlock()
+		if available == cpus {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("CPUs not released")
+}
+
+func TestColdThenWarmStart(t *testing.T) {
+	f := setupScheduler(t, &DefaultLocalPolicy{}, 1024, 2.0)
+	fun := &function.Function{Name: "coldwarm", Runtime: "python310", MemoryMB: 128, CPUDemand: 1.0}
+
+	r := newTestRequest(fun)
+	if err := SubmitRequest(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.ExecReport.IsWarmStart {
+		t.Errorf("first request should be a cold start")
+	}
+	if r.ExecReport.Result != f.Result {
+		t.Errorf("unexpected result: %s", r.ExecReport.Result)
+	}
+	waitForCPUs(t, 2.0)
+
+	r = newTestRequest(fun)
+	if err := SubmitRequest(r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.ExecReport.IsWarmStart {
+		t.Errorf("second request should be a warm start")
+	}
+	if f.Created() != 1 {
+		t.Errorf("expected 1 container, got %d", f.Created())
+	}
+}
+
+func TestDropWhenOutOfResources(t *testing.T) {
+	setupScheduler(t, &DefaultLocalPolicy{}, 128, 2.0)
+	fun := &function.Function{Name: "toobig", Runtime: "python310", MemoryMB: 256, CPUDemand: 1.0}
+
+	err := SubmitRequest(newTestRequest(fun))
+	if !errors.Is(err, node.OutOfResourcesErr) {
+		t.Fatalf("expected OutOfResourcesErr, got %v", err)
+	}
+}
+
+func TestQueuedRequestServedOnCompletion(t *testing.T) {
+	viper.Set(config.SCHEDULER_QUEUE_CAPACITY, 1)
+	defer viper.Set(config.SCHEDULER_QUEUE_CAPACITY, nil)
+	f := setupScheduler(t, &DefaultLocalPolicy{}, 1024, 1.0)
+	f.ExecutionDelay = 50 * time.Millisecond
+	fun := &function.Function{Name: "queued", Runtime: "python310", MemoryMB: 128, CPUDemand: 1.0}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- SubmitRequest(newTestRequest(fun))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("request failed: %v", err)
+		}
+	}
+	if f.Created() != 1 {
+		t.Errorf("expected 1 container, got %d", f.Created())
+	}
+}
+
+func TestFailedExecution(t *testing.T) {
+	f := setupScheduler(t, &DefaultLocalPolicy{}, 1024, 2.0)
+	f.FailInvocations = true
+	fun := &function.Function{Name: "failing", Runtime: "python310", MemoryMB: 128, CPUDemand: 1.0}
+
+	if err := SubmitRequest(newTestRequest(fun)); err == nil {
+		t.Fatalf("expected failure")
+	}
+	// the container must be released anyway
+	waitForCPUs(t, 2.0)
+}
+
+func TestAsyncResultPublished(t *testing.T) {
+	setupScheduler(t, &DefaultLocalPolicy{}, 1024, 2.0)
+	fun := &function.Function{Name: "async", Runtime: "python310", MemoryMB: 128, CPUDemand: 1.0}
+
+	r := newTestRequest(fun)
+	r.Async = true
+	SubmitAsyncRequest(r)
+
+	store, _ := utils.GetKVStore()
+	if _, found, _ := store.Get(context.Background(), "async/"+r.ReqId); !found {
+		t.Errorf("async result not published")
+	}
+}
+
+func BenchmarkWarmInvocations(b *testing.B) {
+	setupScheduler(b, &DefaultLocalPolicy{}, 4096, 64.0)
+	fun := &function.Function{Name: "bench", Runtime: "python310", MemoryMB: 128, CPUDemand: 0.1}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := SubmitRequest(newTestRequest(fun)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package scheduling
+
+import (
+	"context"
+
+	"github.com/grussorusso/serverledge/internal/container"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/node"
+)
+
+// A SimulatedScheduler passes arrivals and completions to a policy
+// synchronously, instead of running the scheduler goroutine, so that a
+// discrete-event simulator can serve requests in virtual time. Requests are
+// not executed: the simulator is notified of every scheduling decision and
+// reports completions once the simulated execution is over.
+// Cold starts must not block (e.g., the container factory should be a
+// FakeFactory without delays).
+type SimulatedScheduler struct {
+	policy     Policy
+	onDecision func(*SimulatedRequest)
+	pending    []*SimulatedRequest // waiting for a decision (e.g., queued)
+}
+
+// A SimulatedRequest is a request served by a SimulatedScheduler. Its
+// decision is set before the simulator is notified.
+type SimulatedRequest struct {
+	*function.Request
+	Action     action                // DROP, EXEC_LOCAL or EXEC_REMOTE
+	Container  container.ContainerID // if executed locally
+	RemoteHost string                // if offloaded
+
+	scheduled *scheduledRequest
+}
+
+// NewSimulatedScheduler initializes the policy, using the node resources and
+// the container factory that have already been set up. onDecision is called
+// for every scheduling decision, while the policy is being run.
+func NewSimulatedScheduler(p Policy, onDecision func(*SimulatedRequest)) *SimulatedScheduler {
+	runInBackground = func(f func()) { f() }
+	p.Init()
+	return &SimulatedScheduler{policy: p, onDecision: onDecision}
+}
+
+// Arrive passes a newly arrived request to the policy.
+func (s *SimulatedScheduler) Arrive(r *function.Request) *SimulatedRequest {
+	ctx := r.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sr := &SimulatedRequest{
+		Request: r,
+		scheduled: &scheduledRequest{
+			Request:         r,
+			decisionChannel: make(chan schedDecision, 1),
+			ctx:             ctx,
+		},
+	}
+	s.pending = append(s.pending, sr)
+	arrival(s.policy, sr.scheduled)
+	s.collectDecisions()
+	return sr
+}
+
+// Complete notifies the completion of a request. Containers used by requests
+// executed locally are released, and the policy may schedule queued
+// requests.
+func (s *SimulatedScheduler) Complete(r *SimulatedRequest) {
+	if r.Action != EXEC_LOCAL {
+		return
+	}
+	node.ReleaseContainer(r.Container, r.Fun)
+	releaseQuota(r.scheduled)
+	s.policy.OnCompletion(r.scheduled)
+	s.collectDecisions()
+}
+
+// collectDecisions notifies the decisions taken for pending requests, in
+// order of arrival.
+func (s *SimulatedScheduler) collectDecisions() {
+	pending := s.pending[:0]
+	for _, r := range s.pending {
+		select {
+		case d := <-r.scheduled.decisionChannel:
+			r.Action = d.action
+			r.Container = d.contID
+			r.RemoteHost = d.remoteHost
+			s.onDecision(r)
+		default:
+			pending = append(pending, r)
+		}
+	}
+	s.pending = pending
+}
@@ -1,6 +1,9 @@
 package scheduling
 
 import (
+	"context"
+	"time"
+
 	"github.com/grussorusso/serverledge/internal/container"
 	"github.com/grussorusso/serverledge/internal/function"
 )
@@ -10,6 +13,12 @@ type scheduledRequest struct {
 	*function.Request
 	decisionChannel chan schedDecision
 	priority        float64
+	// ctx carries the span of the scheduling decision
+	ctx context.Context
+	// time the request entered the queue, if any
+	enqueued time.Time
+	// whether the request holds a share of the quota of its function
+	quotaAcquired bool
 }
 
 type completion struct {
@@ -35,6 +44,21 @@ const (
 	BEST_EFFORT_EXECUTION        = 3
 )
 
+func (a action) String() string {
+	switch a {
+	case DROP:
+		return "drop"
+	case EXEC_LOCAL:
+		return "local"
+	case EXEC_REMOTE:
+		return "remote"
+	case BEST_EFFORT_EXECUTION:
+		return "best-effort"
+	default:
+		return "unknown"
+	}
+}
+
 type schedulingDecision int64
 
 const (
@@ -0,0 +1,223 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/utils"
+)
+
+var UnknownSecretErr = errors.New("unknown secret")
+var NotConfiguredErr = errors.New("no encryption key configured for secrets")
+
+// Secrets are stored in Etcd encrypted with AES-256-GCM. The key is held by
+// the nodes (and must be the same on every node), so that the values of the
+// secrets are never stored in clear.
+var aead cipher.AEAD
+
+// storedSecret is the representation of a secret in Etcd.
+type storedSecret struct {
+	Nonce      []byte
+	Ciphertext []byte
+	Updated    time.Time
+}
+
+// Info describes a secret, without its value.
+type Info struct {
+	Name    string
+	Updated time.Time
+}
+
+// Init loads the encryption key of the secrets.
+func Init() error {
+	encodedKey := config.GetString(config.SECRETS_KEY, "")
+	if keyFile := config.GetString(config.SECRETS_KEY_FILE, ""); keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("could not read the secrets key: %v", err)
+		}
+		encodedKey = strings.TrimSpace(string(content))
+	}
+	if encodedKey == "" {
+		slog.Info("Secrets disabled: no encryption key configured")
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return fmt.Errorf("invalid secrets key: %v", err)
+	}
+	return setKey(key)
+}
+
+func setKey(key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("invalid secrets key: expected 32 bytes, found %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err = cipher.NewGCM(block)
+	return err
+}
+
+func getEtcdKey(namespace string, name string) string {
+	return fmt.Sprintf("/secrets/%s/%s", namespace, name)
+}
+
+// Set stores the value of a secret in a namespace, replacing the previous
+// value (if any).
+func Set(namespace string, name string, value []byte) error {
+	if aead == nil {
+		return NotConfiguredErr
+	}
+	if err := function.ValidateSecretName(name); err != nil {
+		return err
+	}
+
+	key := getEtcdKey(namespace, name)
+	s := storedSecret{Nonce: make([]byte, aead.NonceSize()), Updated: time.Now()}
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return err
+	}
+	// the key is authenticated as well, so that values cannot be moved to
+	// other secrets
+	s.Ciphertext = aead.Seal(nil, s.Nonce, value, []byte(key))
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return err
+	}
+	return store.Put(context.Background(), key, payload, 0)
+}
+
+// Get returns the value of a secret.
+func Get(namespace string, name string) ([]byte, error) {
+	if aead == nil {
+		return nil, NotConfiguredErr
+	}
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	key := getEtcdKey(namespace, name)
+	payload, found, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	} else if !found {
+		return nil, fmt.Errorf("%w: %s", UnknownSecretErr, name)
+	}
+
+	var s storedSecret
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("could not decrypt secret %s: invalid nonce", name)
+	}
+	value, err := aead.Open(nil, s.Nonce, s.Ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt secret %s: %v", name, err)
+	}
+	return value, nil
+}
+
+// List returns the secrets of a namespace, sorted by name.
+func List(namespace string) ([]Info, error) {
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	prefix := getEtcdKey(namespace, "")
+	values, err := store.GetWithPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Info, 0, len(values))
+	for key, payload := range values {
+		var s storedSecret
+		if err := json.Unmarshal(payload, &s); err != nil {
+			continue
+		}
+		list = append(list, Info{Name: strings.TrimPrefix(key, prefix), Updated: s.Updated})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Delete removes a secret, returning false if it did not exist.
+func Delete(namespace string, name string) (bool, error) {
+	store, err := utils.GetKVStore()
+	if err != nil {
+		return false, err
+	}
+	return store.Delete(context.Background(), getEtcdKey(namespace, name))
+}
+
+// Validate checks that the secrets referenced by a function exist in its
+// namespace.
+func Validate(f *function.Function) error {
+	if len(f.Secrets) == 0 {
+		return nil
+	}
+	existing, err := List(f.Namespace())
+	if err != nil {
+		return err
+	}
+
+	envs := make(map[string]bool)
+	for _, ref := range f.Secrets {
+		if err := ref.Validate(); err != nil {
+			return err
+		}
+		if envs[ref.EnvName()] {
+			return fmt.Errorf("%w: duplicate environment variable '%s'", function.InvalidSecretErr, ref.EnvName())
+		}
+		envs[ref.EnvName()] = true
+
+		i := sort.Search(len(existing), func(i int) bool { return existing[i].Name >= ref.Name })
+		if i == len(existing) || existing[i].Name != ref.Name {
+			return fmt.Errorf("%w: %s", UnknownSecretErr, ref.Name)
+		}
+	}
+	return nil
+}
+
+// Env returns the environment variables ("NAME=value") exposing the secrets
+// of a function to its containers. The result must never be logged.
+func Env(f *function.Function) ([]string, error) {
+	env := make([]string, 0, len(f.Secrets))
+	for _, ref := range f.Secrets {
+		value, err := Get(f.Namespace(), ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, ref.EnvName()+"="+string(value))
+	}
+	return env, nil
+}
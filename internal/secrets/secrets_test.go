@@ -0,0 +1,55 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/utils"
+)
+
+func TestSecrets(t *testing.T) {
+	store := utils.NewMemoryKVStore()
+	utils.SetKVStore(store)
+	if err := setKey(bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { aead = nil }()
+
+	if err := Set("team-a", "db-password", []byte("s3cr3t")); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := Get("team-a", "db-password"); err != nil || string(value) != "s3cr3t" {
+		t.Fatalf("unexpected value: %s (%v)", value, err)
+	}
+
+	// values are encrypted and bound to their key
+	stored, _, _ := store.Get(context.Background(), getEtcdKey("team-a", "db-password"))
+	if bytes.Contains(stored, []byte("s3cr3t")) {
+		t.Errorf("secret stored in clear")
+	}
+	store.Put(context.Background(), getEtcdKey("team-b", "db-password"), stored, 0)
+	if _, err := Get("team-b", "db-password"); err == nil {
+		t.Errorf("secret moved to another namespace decrypted")
+	}
+
+	f := &function.Function{Name: "team-a/f", Secrets: []function.SecretRef{{Name: "db-password"}}}
+	if err := Validate(f); err != nil {
+		t.Fatal(err)
+	}
+	if env, err := Env(f); err != nil || len(env) != 1 || env[0] != "DB_PASSWORD=s3cr3t" {
+		t.Errorf("unexpected environment: %v (%v)", env, err)
+	}
+
+	// secrets of other namespaces are not visible
+	f = &function.Function{Name: "team-c/f", Secrets: []function.SecretRef{{Name: "db-password"}}}
+	if err := Validate(f); !errors.Is(err, UnknownSecretErr) {
+		t.Errorf("secret of another namespace accepted: %v", err)
+	}
+	f = &function.Function{Name: "team-a/f", Secrets: []function.SecretRef{{Name: "db-password", Env: "1X"}}}
+	if err := Validate(f); !errors.Is(err, function.InvalidSecretErr) {
+		t.Errorf("invalid variable accepted: %v", err)
+	}
+}
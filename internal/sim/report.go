@@ -0,0 +1,171 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+// Outcome of a request.
+type Outcome int
+
+const (
+	Completed Outcome = iota
+	Dropped
+	Failed
+)
+
+// A Result describes how a request has been served.
+type Result struct {
+	Function     string
+	Outcome      Outcome
+	ResponseTime float64 // seconds
+	ColdStart    bool
+	Offloaded    bool
+}
+
+// Stats summarizes a set of response times (seconds).
+type Stats struct {
+	Mean float64
+	P50  float64
+	P95  float64
+	P99  float64
+	Max  float64
+}
+
+// Summary aggregates results, for all the functions or for a single one.
+type Summary struct {
+	Requests     int
+	Completed    int
+	Dropped      int
+	Failed       int
+	ColdStarts   int
+	Offloaded    int
+	ResponseTime Stats
+
+	responseTimes []float64
+}
+
+// A Report summarizes the results of a run.
+type Report struct {
+	Summary
+	Functions map[string]*Summary
+}
+
+// NewReport aggregates the results of a run.
+func NewReport(results []Result) *Report {
+	report := &Report{Functions: make(map[string]*Summary)}
+	for _, r := range results {
+		fs, ok := report.Functions[r.Function]
+		if !ok {
+			fs = &Summary{}
+			report.Functions[r.Function] = fs
+		}
+		report.add(r)
+		fs.add(r)
+	}
+
+	report.computeStats()
+	for _, fs := range report.Functions {
+		fs.computeStats()
+	}
+	return report
+}
+
+func (s *Summary) add(r Result) {
+	s.Requests++
+	switch r.Outcome {
+	case Dropped:
+		s.Dropped++
+	case Failed:
+		s.Failed++
+	case Completed:
+		s.Completed++
+		s.responseTimes = append(s.responseTimes, r.ResponseTime)
+		if r.ColdStart {
+			s.ColdStarts++
+		}
+		if r.Offloaded {
+			s.Offloaded++
+		}
+	}
+}
+
+func (s *Summary) computeStats() {
+	n := len(s.responseTimes)
+	if n == 0 {
+		return
+	}
+	sort.Float64s(s.responseTimes)
+
+	sum := 0.0
+	for _, rt := range s.responseTimes {
+		sum += rt
+	}
+	s.ResponseTime = Stats{
+		Mean: sum / float64(n),
+		P50:  percentile(s.responseTimes, 0.50),
+		P95:  percentile(s.responseTimes, 0.95),
+		P99:  percentile(s.responseTimes, 0.99),
+		Max:  s.responseTimes[n-1],
+	}
+}
+
+// percentile returns the p-th percentile of sorted values (nearest rank).
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0.0
+	}
+	return float64(n) / float64(total)
+}
+
+// ColdStartRate is the fraction of completed requests served through a cold start.
+func (s *Summary) ColdStartRate() float64 {
+	return rate(s.ColdStarts, s.Completed)
+}
+
+// DropRate is the fraction of dropped requests.
+func (s *Summary) DropRate() float64 {
+	return rate(s.Dropped, s.Requests)
+}
+
+// OffloadRate is the fraction of completed requests served by other nodes.
+func (s *Summary) OffloadRate() float64 {
+	return rate(s.Offloaded, s.Completed)
+}
+
+// Print writes the report as a table.
+func (r *Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Function\tRequests\tCompleted\tDropped\tFailed\tCold\tOffloaded\tMean RT\tP50\tP95\tP99\tMax\t")
+
+	names := make([]string, 0, len(r.Functions))
+	for name := range r.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		printSummary(tw, name, r.Functions[name])
+	}
+	printSummary(tw, "TOTAL", &r.Summary)
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nDrop rate: %.2f%%  Cold start rate: %.2f%%  Offload rate: %.2f%%\n",
+		100*r.DropRate(), 100*r.ColdStartRate(), 100*r.OffloadRate())
+}
+
+func printSummary(w io.Writer, name string, s *Summary) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t\n",
+		name, s.Requests, s.Completed, s.Dropped, s.Failed, s.ColdStarts, s.Offloaded,
+		s.ResponseTime.Mean, s.ResponseTime.P50, s.ResponseTime.P95, s.ResponseTime.P99, s.ResponseTime.Max)
+}
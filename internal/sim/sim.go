@@ -0,0 +1,282 @@
+package sim
+
+import (
+	"container/heap"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/grussorusso/serverledge/internal/api"
+	"github.com/grussorusso/serverledge/internal/config"
+	"github.com/grussorusso/serverledge/internal/container"
+	"github.com/grussorusso/serverledge/internal/function"
+	"github.com/grussorusso/serverledge/internal/node"
+	"github.com/grussorusso/serverledge/internal/registration"
+	"github.com/grussorusso/serverledge/internal/scheduling"
+	"github.com/grussorusso/serverledge/utils"
+)
+
+// Addresses of the simulated remote nodes
+const (
+	cloudHost = "sim-cloud"
+	edgeHost  = "sim-edge"
+)
+
+// Config describes the simulated node and its environment. All the times
+// are expressed in seconds.
+type Config struct {
+	Policy string
+	// Resources of the simulated node
+	MemoryMB int64
+	CPUs     float64
+	// Defaults for functions and requests that do not specify them
+	FunctionMemoryMB  int64
+	FunctionCPUDemand float64
+	Duration          float64
+	ColdStart         float64
+	// Expiration time of idle containers
+	ContainerExpiration int
+	QueueCapacity       int
+	// Round-trip latency to the cloud and to the edge neighbor; a negative
+	// value disables the corresponding node
+	CloudLatency float64
+	EdgeLatency  float64
+	// Resources advertised by the edge neighbor
+	EdgeMemoryMB int64
+	EdgeCPUs     float64
+}
+
+// DefaultConfig returns the default simulation parameters.
+func DefaultConfig() Config {
+	return Config{
+		Policy:              "default",
+		MemoryMB:            4096,
+		CPUs:                4,
+		FunctionMemoryMB:    128,
+		FunctionCPUDemand:   0.0,
+		Duration:            0.1,
+		ColdStart:           0.5,
+		ContainerExpiration: 600,
+		QueueCapacity:       0,
+		CloudLatency:        0.05,
+		EdgeLatency:         -1,
+		EdgeMemoryMB:        4096,
+		EdgeCPUs:            4,
+	}
+}
+
+// An event is an arrival or the completion of a request.
+type event struct {
+	time    float64 // seconds since the beginning of the simulation
+	seq     int     // events at the same time are processed in order of creation
+	arrival int     // index of the arrival, for arrival events
+	request *scheduling.SimulatedRequest
+}
+
+// eventQueue is a priority queue of events, ordered by time.
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].time != q[j].time {
+		return q[i].time < q[j].time
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x any) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// simulator holds the state of a run.
+type simulator struct {
+	conf      Config
+	arrivals  []Arrival
+	functions map[string]*function.Function
+	scheduler *scheduling.SimulatedScheduler
+	clock     *node.ManualClock
+	start     time.Time
+	now       float64 // current simulated time
+	events    eventQueue
+	seq       int
+	results   []Result
+	// index of the arrival of each request
+	requests map[*function.Request]int
+}
+
+// schedule adds an event to the queue.
+func (s *simulator) schedule(e *event) {
+	e.seq = s.seq
+	s.seq++
+	heap.Push(&s.events, e)
+}
+
+// advance moves the simulated time forward, removing the containers that
+// expired in the meantime.
+func (s *simulator) advance(t float64) {
+	s.now = t
+	s.clock.Advance(s.start.Add(time.Duration(t * float64(time.Second))).Sub(s.clock.Now()))
+	node.DeleteExpiredContainer()
+}
+
+// executionTime returns the execution time of a request.
+func (s *simulator) executionTime(a *Arrival) float64 {
+	if a.Duration > 0 {
+		return a.Duration
+	}
+	return s.conf.Duration
+}
+
+// setup configures the node, the scheduler and the simulated remote nodes.
+func (s *simulator) setup() {
+	config.Set(config.CONTAINER_EXPIRATION_TIME, s.conf.ContainerExpiration)
+	config.Set(config.SCHEDULER_QUEUE_CAPACITY, s.conf.QueueCapacity)
+	utils.SetKVStore(utils.NewMemoryKVStore())
+
+	// cold starts and executions are simulated by the event queue
+	container.SetFactory(container.NewFakeFactory())
+
+	node.Resources.Lock()
+	node.Resources.AvailableMemMB = s.conf.MemoryMB
+	node.Resources.AvailableCPUs = s.conf.CPUs
+	node.Resources.DropCount = 0
+	node.Resources.ContainerPools = make(map[string]*node.ContainerPool)
+	node.Resources.Unlock()
+	s.start = time.Unix(0, 0)
+	s.clock = node.NewManualClock(s.start)
+	node.SetClock(s.clock)
+
+	config.Set(config.CLOUD_URL, "")
+	if s.conf.CloudLatency >= 0 {
+		config.Set(config.CLOUD_URL, cloudHost)
+	}
+
+	registration.Reg = &registration.Registry{NearbyServersMap: make(map[string]*registration.StatusInformation)}
+	if s.conf.EdgeLatency >= 0 {
+		registration.Reg.NearbyServersMap[edgeHost] = &registration.StatusInformation{
+			Url:                     edgeHost,
+			AvailableWarmContainers: make(map[string]int),
+			AvailableMemMB:          s.conf.EdgeMemoryMB,
+			AvailableCPUs:           s.conf.EdgeCPUs,
+		}
+	}
+
+	s.scheduler = scheduling.NewSimulatedScheduler(scheduling.CreatePolicy(s.conf.Policy), s.onDecision)
+}
+
+// getFunction returns the simulated function invoked by an arrival.
+func (s *simulator) getFunction(a *Arrival) *function.Function {
+	if fun, ok := s.functions[a.Function]; ok {
+		return fun
+	}
+
+	fun := &function.Function{
+		Name:      a.Function,
+		Runtime:   "python310",
+		MemoryMB:  s.conf.FunctionMemoryMB,
+		CPUDemand: s.conf.FunctionCPUDemand,
+	}
+	if a.MemoryMB > 0 {
+		fun.MemoryMB = a.MemoryMB
+	}
+	if a.CPUDemand > 0 {
+		fun.CPUDemand = a.CPUDemand
+	}
+	s.functions[a.Function] = fun
+	return fun
+}
+
+// arrive submits a request to the scheduler.
+func (s *simulator) arrive(i int) {
+	a := &s.arrivals[i]
+	fun := s.getFunction(a)
+	r := &function.Request{
+		ReqId:           fmt.Sprintf("%s-%d", fun.Name, i),
+		Fun:             fun,
+		Params:          a.Params,
+		Arrival:         time.Now(),
+		CanDoOffloading: true,
+	}
+	r.SetLogger()
+	r.Class = api.DecodeServiceClass(a.Class)
+	r.MaxRespT = a.MaxRespT
+	if r.MaxRespT <= 0 {
+		r.MaxRespT = -1
+	}
+
+	s.requests[r] = i
+	s.results[i] = Result{Function: fun.Name}
+	s.scheduler.Arrive(r)
+}
+
+// onDecision schedules the completion of a request, according to the
+// scheduling decision.
+func (s *simulator) onDecision(r *scheduling.SimulatedRequest) {
+	i := s.requests[r.Request]
+	result := &s.results[i]
+	end := s.now + s.executionTime(&s.arrivals[i])
+	switch r.Action {
+	case scheduling.DROP:
+		result.Outcome = Dropped
+		delete(s.requests, r.Request)
+		return
+	case scheduling.EXEC_REMOTE:
+		result.Offloaded = true
+		if r.RemoteHost == edgeHost {
+			end += s.conf.EdgeLatency
+		} else {
+			end += s.conf.CloudLatency
+		}
+	default:
+		if !r.ExecReport.IsWarmStart {
+			result.ColdStart = true
+			end += s.conf.ColdStart
+		}
+	}
+
+	result.Outcome = Completed
+	result.ResponseTime = end - s.arrivals[i].Time
+	s.schedule(&event{time: end, request: r})
+}
+
+// Run replays the arrivals through the scheduling policy, returning the
+// outcome of every request. The simulation runs in virtual time: events
+// (i.e., arrivals and completions) are processed in order, without waiting,
+// and runs with the same configuration and trace have the same results.
+func Run(conf Config, arrivals []Arrival) ([]Result, error) {
+	s := &simulator{
+		conf:      conf,
+		arrivals:  arrivals,
+		functions: make(map[string]*function.Function),
+		results:   make([]Result, len(arrivals)),
+		requests:  make(map[*function.Request]int),
+	}
+	s.setup()
+
+	for i := range arrivals {
+		s.schedule(&event{time: arrivals[i].Time, arrival: i})
+	}
+
+	started := time.Now()
+	for s.events.Len() > 0 {
+		e := heap.Pop(&s.events).(*event)
+		s.advance(e.time)
+		if e.request == nil {
+			s.arrive(e.arrival)
+		} else {
+			delete(s.requests, e.request.Request)
+			s.scheduler.Complete(e.request)
+		}
+	}
+
+	log.Printf("Simulated %d requests (%.1f s) in %v\n", len(arrivals), s.now, time.Since(started))
+	return s.results, nil
+}
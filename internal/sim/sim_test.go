@@ -0,0 +1,90 @@
+package sim
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	conf := DefaultConfig()
+	conf.MemoryMB = 256
+	conf.FunctionMemoryMB = 128
+	conf.Duration = 1.0
+	conf.ColdStart = 0.5
+	conf.CloudLatency = -1
+	return conf
+}
+
+func checkResult(t *testing.T, i int, got, expected Result) {
+	if got.Outcome != expected.Outcome || got.ColdStart != expected.ColdStart || got.Offloaded != expected.Offloaded ||
+		math.Abs(got.ResponseTime-expected.ResponseTime) > 1e-9 {
+		t.Errorf("request %d: expected %+v, got %+v", i, expected, got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	arrivals := []Arrival{
+		{Time: 0, Function: "f"},
+		{Time: 0, Function: "f"},
+		{Time: 0, Function: "f"},
+		{Time: 2, Function: "f", Duration: 0.2},
+		{Time: 1000, Function: "f"},
+	}
+
+	started := time.Now()
+	results, err := Run(testConfig(), arrivals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("simulation not run in virtual time: %v", elapsed)
+	}
+
+	expected := []Result{
+		{Function: "f", Outcome: Completed, ResponseTime: 1.5, ColdStart: true},
+		{Function: "f", Outcome: Completed, ResponseTime: 1.5, ColdStart: true},
+		{Function: "f", Outcome: Dropped},
+		{Function: "f", Outcome: Completed, ResponseTime: 0.2},
+		// idle containers expire after 600 s
+		{Function: "f", Outcome: Completed, ResponseTime: 1.5, ColdStart: true},
+	}
+	for i := range expected {
+		checkResult(t, i, results[i], expected[i])
+	}
+
+	again, err := Run(testConfig(), arrivals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, again) {
+		t.Errorf("results not reproducible:\n%+v\n%+v", results, again)
+	}
+}
+
+func TestRunWithQueueAndOffloading(t *testing.T) {
+	arrivals := []Arrival{
+		{Time: 0, Function: "f"},
+		{Time: 0, Function: "f"},
+		{Time: 0, Function: "f"},
+	}
+
+	conf := testConfig()
+	conf.QueueCapacity = 1
+	results, err := Run(conf, arrivals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the queued request is served by the first released container
+	checkResult(t, 2, results[2], Result{Outcome: Completed, ResponseTime: 2.5})
+
+	conf = testConfig()
+	conf.Policy = "edgecloud"
+	conf.CloudLatency = 0.05
+	results, err = Run(conf, arrivals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, 2, results[2], Result{Outcome: Completed, ResponseTime: 1.05, Offloaded: true})
+}
@@ -0,0 +1,125 @@
+package sim
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An Arrival is an invocation request in a trace.
+type Arrival struct {
+	// Time of arrival (seconds since the beginning of the trace)
+	Time     float64 `json:"time"`
+	Function string  `json:"function"`
+	// Execution time (seconds); 0 to use the default one
+	Duration float64 `json:"duration,omitempty"`
+	// Function memory (MB) and CPU demand; 0 to use the default ones
+	MemoryMB  int64   `json:"memory,omitempty"`
+	CPUDemand float64 `json:"cpu,omitempty"`
+	// QoS class (low, performance, availability) and max. response time
+	Class    string  `json:"class,omitempty"`
+	MaxRespT float64 `json:"max_resp_t,omitempty"`
+	// Function parameters
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// ReadTrace reads a trace file, returning arrivals sorted by time.
+// Files with extension .csv are parsed as Azure Functions traces (i.e.,
+// number of invocations per minute for each function); other files are read
+// as JSON Lines, with an Arrival per line.
+func ReadTrace(path string) ([]Arrival, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var arrivals []Arrival
+	if strings.HasSuffix(path, ".csv") {
+		arrivals, err = ReadAzureTrace(f)
+	} else {
+		arrivals, err = ReadJSONTrace(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(arrivals, func(i, j int) bool { return arrivals[i].Time < arrivals[j].Time })
+	return arrivals, nil
+}
+
+// ReadJSONTrace reads arrivals encoded as JSON Lines.
+func ReadJSONTrace(r io.Reader) ([]Arrival, error) {
+	arrivals := make([]Arrival, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var a Arrival
+		if err := json.Unmarshal([]byte(text), &a); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if a.Function == "" {
+			return nil, fmt.Errorf("line %d: missing function", line)
+		}
+		arrivals = append(arrivals, a)
+	}
+
+	return arrivals, scanner.Err()
+}
+
+// ReadAzureTrace reads an invocation trace in the format of the Azure
+// Functions dataset: each row contains HashOwner, HashApp, HashFunction,
+// Trigger and the number of invocations in each minute. Invocations are
+// evenly spread within each minute.
+func ReadAzureTrace(r io.Reader) ([]Arrival, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	const firstMinuteColumn = 4
+	if len(header) <= firstMinuteColumn || header[2] != "HashFunction" {
+		return nil, fmt.Errorf("unexpected header: %v", header)
+	}
+
+	arrivals := make([]Arrival, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		function := record[2]
+		for i := firstMinuteColumn; i < len(record); i++ {
+			count, err := strconv.Atoi(record[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid count for %s: %v", function, err)
+			}
+
+			minuteStart := float64(i-firstMinuteColumn) * 60.0
+			for j := 0; j < count; j++ {
+				arrivals = append(arrivals, Arrival{
+					Time:     minuteStart + (float64(j)+0.5)*60.0/float64(count),
+					Function: function,
+				})
+			}
+		}
+	}
+
+	return arrivals, nil
+}
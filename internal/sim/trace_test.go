@@ -0,0 +1,69 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadJSONTrace(t *testing.T) {
+	trace := `{"time": 0.5, "function": "a", "duration": 0.2, "memory": 256}
+
+# comment
+{"time": 1.0, "function": "b", "class": "performance"}
+`
+	arrivals, err := ReadJSONTrace(strings.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arrivals) != 2 {
+		t.Fatalf("expected 2 arrivals, got %d", len(arrivals))
+	}
+	if a := arrivals[0]; a.Function != "a" || a.Time != 0.5 || a.Duration != 0.2 || a.MemoryMB != 256 {
+		t.Errorf("unexpected arrival: %+v", a)
+	}
+	if arrivals[1].Class != "performance" {
+		t.Errorf("unexpected class: %s", arrivals[1].Class)
+	}
+
+	if _, err := ReadJSONTrace(strings.NewReader(`{"time": 1.0}`)); err == nil {
+		t.Errorf("expected error for missing function")
+	}
+}
+
+func TestReadAzureTrace(t *testing.T) {
+	trace := "HashOwner,HashApp,HashFunction,Trigger,1,2\n" +
+		"o,a,f,http,2,0\n" +
+		"o,a,g,timer,0,1\n"
+	arrivals, err := ReadAzureTrace(strings.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arrivals) != 3 {
+		t.Fatalf("expected 3 arrivals, got %d", len(arrivals))
+	}
+	if arrivals[0].Time != 15.0 || arrivals[1].Time != 45.0 || arrivals[0].Function != "f" {
+		t.Errorf("unexpected arrivals: %+v", arrivals[:2])
+	}
+	if arrivals[2].Time != 90.0 || arrivals[2].Function != "g" {
+		t.Errorf("unexpected arrival: %+v", arrivals[2])
+	}
+}
+
+func TestReport(t *testing.T) {
+	results := []Result{
+		{Function: "a", Outcome: Completed, ResponseTime: 1.0, ColdStart: true},
+		{Function: "a", Outcome: Completed, ResponseTime: 2.0},
+		{Function: "a", Outcome: Completed, ResponseTime: 3.0, Offloaded: true},
+		{Function: "b", Outcome: Dropped},
+	}
+	report := NewReport(results)
+	if report.Requests != 4 || report.Completed != 3 || report.Dropped != 1 {
+		t.Errorf("unexpected summary: %+v", report.Summary)
+	}
+	if report.ResponseTime.Mean != 2.0 || report.ResponseTime.P50 != 2.0 || report.ResponseTime.Max != 3.0 {
+		t.Errorf("unexpected response time: %+v", report.ResponseTime)
+	}
+	if report.DropRate() != 0.25 || report.Functions["a"].ColdStarts != 1 || report.Functions["a"].Offloaded != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
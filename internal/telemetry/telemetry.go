@@ -0,0 +1,200 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/grussorusso/serverledge/internal/config"
+)
+
+const (
+	EXPORTER_OTLP   = "otlp"
+	EXPORTER_STDOUT = "stdout"
+	EXPORTER_FILE   = "file"
+)
+
+var tracer = otel.Tracer("github.com/grussorusso/serverledge")
+
+// propagator encodes trace context as W3C Trace Context headers. It is used
+// even if tracing is disabled, so that traces started by clients or other
+// nodes are not broken.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Init configures the tracing system according to the configuration.
+// It returns a function that flushes pending spans and stops exporting.
+func Init(nodeID string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+	if !config.GetBool(config.TRACING_ENABLED, false) {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("serverledge"),
+		semconv.ServiceInstanceID(nodeID)))
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := config.GetFloat(config.TRACING_SAMPLING_RATIO, 1.0)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))))
+	otel.SetTracerProvider(tp)
+
+	log.Printf("Tracing enabled (exporter: %s)\n", config.GetString(config.TRACING_EXPORTER, EXPORTER_OTLP))
+	return tp.Shutdown, nil
+}
+
+func newExporter() (sdktrace.SpanExporter, error) {
+	exporterType := config.GetString(config.TRACING_EXPORTER, EXPORTER_OTLP)
+	switch exporterType {
+	case EXPORTER_OTLP:
+		opts := []otlptracehttp.Option{}
+		if endpoint := config.GetString(config.TRACING_OTLP_ENDPOINT, ""); endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	case EXPORTER_STDOUT:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case EXPORTER_FILE:
+		fileName := config.GetString(config.TRACING_FILE, "serverledge-traces.json")
+		f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("could not open trace file: %v", err)
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", exporterType)
+	}
+}
+
+// StartSpan starts a new span as a child of the span in ctx (if any).
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordSpan records a span that started at start and ends now.
+func RecordSpan(ctx context.Context, name string, start time.Time, attrs ...attribute.KeyValue) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracer.Start(ctx, name, trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	span.End()
+}
+
+// RecordError marks a span as failed because of err.
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// EndSpan ends a span, recording err (if any).
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		RecordError(span, err)
+	}
+	span.End()
+}
+
+// InjectHTTP adds the trace context in ctx to HTTP headers.
+func InjectHTTP(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// ExtractHTTP returns a context carrying the trace context found in HTTP
+// headers, if any.
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// metadataCarrier adapts gRPC metadata to propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectGRPC returns a context for outgoing gRPC calls carrying the trace
+// context in ctx.
+func InjectGRPC(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	propagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// ExtractGRPC returns a context derived from the one of an incoming gRPC
+// call (e.g., carrying the authenticated principal), with the trace context
+// found in its metadata, if any.
+func ExtractGRPC(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return propagator.Extract(ctx, metadataCarrier(md))
+}
+
+// Env returns the trace context in ctx as environment variables
+// (i.e., TRACEPARENT and TRACESTATE), to be passed to functions.
+func Env(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+
+	env := make(map[string]string, len(carrier))
+	for k, v := range carrier {
+		env[strings.ToUpper(k)] = v
+	}
+	return env
+}
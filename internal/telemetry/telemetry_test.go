@@ -0,0 +1,62 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+func testContext(t *testing.T) context.Context {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestHTTPPropagation(t *testing.T) {
+	header := http.Header{}
+	InjectHTTP(testContext(t), header)
+	if header.Get("traceparent") != testTraceparent {
+		t.Fatalf("unexpected traceparent: %q", header.Get("traceparent"))
+	}
+
+	ctx := ExtractHTTP(context.Background(), header)
+	if sc := trace.SpanContextFromContext(ctx); sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || !sc.IsRemote() {
+		t.Errorf("unexpected span context: %+v", sc)
+	}
+}
+
+func TestGRPCPropagation(t *testing.T) {
+	out := InjectGRPC(testContext(t))
+	md, _ := metadata.FromOutgoingContext(out)
+
+	type key struct{}
+	in := metadata.NewIncomingContext(context.WithValue(context.Background(), key{}, "value"), md)
+	ctx := ExtractGRPC(in)
+	sc := trace.SpanContextFromContext(ctx)
+	if sc.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("unexpected span context: %+v", sc)
+	}
+	if ctx.Value(key{}) != "value" {
+		t.Errorf("values of the incoming context not preserved")
+	}
+}
+
+func TestEnv(t *testing.T) {
+	env := Env(testContext(t))
+	if env["TRACEPARENT"] != testTraceparent {
+		t.Errorf("unexpected env: %v", env)
+	}
+	if Env(context.Background()) != nil {
+		t.Errorf("no env expected without a span")
+	}
+}
@@ -6,5 +6,4 @@ set -e
 
 bin/serverledge-cli create -f sieve --memory 128 --src examples/sieve.js --runtime nodejs17ng --handler "sieve.js" && sleep 1
 
-echo '{"Params":{},"QoSClass":0}' > /tmp/json
-ab -l -p /tmp/json -T application/json -c 5 -n 25000 http://127.0.0.1:1323/invoke/sieve | tee ab_output.txt
+bin/serverledge-cli bench -f sieve --mode closed --concurrency 5 --requests 25000 -o bench_output.jsonl | tee bench_output.txt
@@ -22,10 +22,24 @@ func GetEtcdClient() (*clientv3.Client, error) {
 	}
 
 	etcdHost := config.GetString(config.ETCD_ADDRESS, "localhost:2379")
-	cli, err := clientv3.New(clientv3.Config{
+	etcdConfig := clientv3.Config{
 		Endpoints:   []string{etcdHost},
 		DialTimeout: 1 * time.Second,
-	})
+	}
+
+	// TLS is used if a CA or a client certificate is configured
+	certFile := config.GetString(config.ETCD_TLS_CERT, "")
+	keyFile := config.GetString(config.ETCD_TLS_KEY, "")
+	caFile := config.GetString(config.ETCD_TLS_CA, "")
+	if certFile != "" || caFile != "" {
+		tlsConfig, err := NewClientTLSConfig(certFile, keyFile, caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid TLS configuration for etcd: %v", err)
+		}
+		etcdConfig.TLS = tlsConfig
+	}
+
+	cli, err := clientv3.New(etcdConfig)
 	if err != nil {
 		return nil, fmt.Errorf("Could not connect to etcd: %v", err)
 	}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// A KVStore is a key-value store shared by the nodes (e.g., to store function
+// definitions and the results of asynchronous invocations). Etcd is used,
+// unless another store is set through SetKVStore (e.g., in tests).
+type KVStore interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	GetWithPrefix(ctx context.Context, prefix string) (map[string][]byte, error)
+	// Put stores a value; if ttl > 0, the key expires after ttl.
+	Put(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes a key, returning false if it did not exist.
+	Delete(ctx context.Context, key string) (bool, error)
+}
+
+var kvStore KVStore
+var kvStoreMutex sync.Mutex
+
+// GetKVStore returns the key-value store.
+func GetKVStore() (KVStore, error) {
+	kvStoreMutex.Lock()
+	defer kvStoreMutex.Unlock()
+
+	if kvStore != nil {
+		return kvStore, nil
+	}
+
+	cli, err := GetEtcdClient()
+	if err != nil {
+		return nil, err
+	}
+	kvStore = &etcdKVStore{cli}
+	return kvStore, nil
+}
+
+// SetKVStore replaces the key-value store.
+func SetKVStore(store KVStore) {
+	kvStoreMutex.Lock()
+	defer kvStoreMutex.Unlock()
+	kvStore = store
+}
+
+type etcdKVStore struct {
+	cli *clientv3.Client
+}
+
+func (s *etcdKVStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	resp, err := s.cli.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(resp.Kvs) < 1 {
+		return nil, false, nil
+	}
+	return resp.Kvs[0].Value, true, nil
+}
+
+func (s *etcdKVStore) GetWithPrefix(ctx context.Context, prefix string) (map[string][]byte, error) {
+	resp, err := s.cli.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]byte, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		values[string(kv.Key)] = kv.Value
+	}
+	return values, nil
+}
+
+func (s *etcdKVStore) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		_, err := s.cli.Put(ctx, key, string(value))
+		return err
+	}
+
+	lease, err := s.cli.Grant(ctx, int64(ttl.Seconds()))
+	if err != nil {
+		return err
+	}
+	_, err = s.cli.Put(ctx, key, string(value), clientv3.WithLease(lease.ID))
+	return err
+}
+
+func (s *etcdKVStore) Delete(ctx context.Context, key string) (bool, error) {
+	resp, err := s.cli.Delete(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return resp.Deleted > 0, nil
+}
+
+// MemoryKVStore is an in-memory KVStore, which can replace Etcd in tests and
+// single-node setups.
+type MemoryKVStore struct {
+	sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value      []byte
+	expiration time.Time // zero if the key does not expire
+}
+
+func NewMemoryKVStore() *MemoryKVStore {
+	return &MemoryKVStore{entries: make(map[string]memoryEntry)}
+}
+
+func (e memoryEntry) expired() bool {
+	return !e.expiration.IsZero() && time.Now().After(e.expiration)
+}
+
+func (s *MemoryKVStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || entry.expired() {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (s *MemoryKVStore) GetWithPrefix(ctx context.Context, prefix string) (map[string][]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+	values := make(map[string][]byte)
+	for key, entry := range s.entries {
+		if strings.HasPrefix(key, prefix) && !entry.expired() {
+			values[key] = entry.value
+		}
+	}
+	return values, nil
+}
+
+func (s *MemoryKVStore) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	entry := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expiration = time.Now().Add(ttl)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *MemoryKVStore) Delete(ctx context.Context, key string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	entry, ok := s.entries[key]
+	delete(s.entries, key)
+	return ok && !entry.expired(), nil
+}
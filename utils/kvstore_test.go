@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryKVStore(t *testing.T) {
+	s := NewMemoryKVStore()
+	ctx := context.Background()
+
+	s.Put(ctx, "/function/a", []byte("A"), 0)
+	s.Put(ctx, "/function/b", []byte("B"), 0)
+	s.Put(ctx, "async/1", []byte("R"), 10*time.Millisecond)
+
+	if v, found, _ := s.Get(ctx, "/function/a"); !found || string(v) != "A" {
+		t.Errorf("unexpected value: %s", v)
+	}
+	if values, _ := s.GetWithPrefix(ctx, "/function"); len(values) != 2 {
+		t.Errorf("expected 2 values, got %d", len(values))
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, found, _ := s.Get(ctx, "async/1"); found {
+		t.Errorf("key not expired")
+	}
+
+	if deleted, _ := s.Delete(ctx, "/function/a"); !deleted {
+		t.Errorf("key not deleted")
+	}
+	if deleted, _ := s.Delete(ctx, "/function/a"); deleted {
+		t.Errorf("key deleted twice")
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/grussorusso/serverledge/internal/config"
+)
+
+// Possible values of config.TLS_CLIENT_AUTH
+const (
+	TLS_CLIENT_AUTH_NONE     = "none"
+	TLS_CLIENT_AUTH_OPTIONAL = "optional"
+	TLS_CLIENT_AUTH_REQUIRED = "required"
+)
+
+// Nodes are expected to have certificates issued by the same CA, which they
+// use both as servers (API) and as clients (e.g., offloading requests).
+
+// TLSEnabled returns true if the APIs of the nodes are served over TLS.
+func TLSEnabled() bool {
+	return config.GetBool(config.TLS_ENABLED, false)
+}
+
+// NodeClientAuthEnabled returns true if the API servers verify the client
+// certificates of other nodes (mutual TLS).
+func NodeClientAuthEnabled() bool {
+	return TLSEnabled() && config.GetString(config.TLS_CA, "") != "" &&
+		config.GetString(config.TLS_CLIENT_AUTH, TLS_CLIENT_AUTH_OPTIONAL) != TLS_CLIENT_AUTH_NONE
+}
+
+// NodeUrl returns the URL of the REST API exposed by a node.
+func NodeUrl(host string, port int) string {
+	scheme := "http"
+	if TLSEnabled() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// ServerTLSConfig returns the TLS configuration of the API servers. If a CA
+// is configured, clients are asked for a certificate issued by the CA,
+// which identifies them as nodes.
+func ServerTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.GetString(config.TLS_CERT, ""), config.GetString(config.TLS_KEY, ""))
+	if err != nil {
+		return nil, fmt.Errorf("could not load the node certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	caFile := config.GetString(config.TLS_CA, "")
+	if caFile == "" {
+		return tlsConfig, nil
+	}
+	if tlsConfig.ClientCAs, err = LoadCertPool(caFile); err != nil {
+		return nil, err
+	}
+	switch mode := config.GetString(config.TLS_CLIENT_AUTH, TLS_CLIENT_AUTH_OPTIONAL); mode {
+	case TLS_CLIENT_AUTH_NONE:
+		tlsConfig.ClientAuth = tls.NoClientCert
+	case TLS_CLIENT_AUTH_OPTIONAL:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case TLS_CLIENT_AUTH_REQUIRED:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown TLS client authentication mode: %s", mode)
+	}
+	return tlsConfig, nil
+}
+
+// ClientTLSConfig returns the TLS configuration for connections to other
+// nodes, which present this node's certificate.
+func ClientTLSConfig() (*tls.Config, error) {
+	return NewClientTLSConfig(config.GetString(config.TLS_CERT, ""), config.GetString(config.TLS_KEY, ""),
+		config.GetString(config.TLS_CA, ""))
+}
+
+// NewClientTLSConfig returns a TLS configuration for clients. Servers are
+// verified with the given CA (or the system CAs, if empty), and the client
+// certificate is presented if given.
+func NewClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load the client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// LoadCertPool reads PEM-encoded CA certificates from a file.
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CA certificates: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid CA certificates in %s", caFile)
+	}
+	return pool, nil
+}